@@ -0,0 +1,483 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/infrastructure/mfile"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/infrastructure/repository"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/usecase"
+)
+
+// runCommand サブコマンドを実行する
+func runCommand(args []string) error {
+	switch args[0] {
+	case "export":
+		return runExport(args[1:])
+	case "import":
+		return runImport(args[1:])
+	case "check-model":
+		return runCheckModel(args[1:])
+	case "retime":
+		return runRetime(args[1:])
+	case "warp":
+		return runWarp(args[1:])
+	case "crossfade":
+		return runCrossfade(args[1:])
+	case "mirror":
+		return runMirror(args[1:])
+	case "compare":
+		return runCompare(args[1:])
+	default:
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+}
+
+// runCheckModel mat5 check-model [-boneMapping mapping.json] [-rootMotion] [-insertBones] <model.pmx>
+func runCheckModel(args []string) error {
+	fs := flag.NewFlagSet("check-model", flag.ContinueOnError)
+	boneMapping := fs.String("boneMapping", "", "bone name override file path")
+	useRootMotion := fs.Bool("rootMotion", false, "check root bone for root motion")
+	insertBones := fs.Bool("insertBones", false, "check after inserting missing bones")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+
+	if len(args) < 1 {
+		return fmt.Errorf("usage: mat5 check-model [-boneMapping mapping.json] [-rootMotion] [-insertBones] <model.pmx>")
+	}
+
+	model, err := loadModel(args[0])
+	if err != nil {
+		return err
+	}
+
+	overrides, err := usecase.LoadRetargetOverrides(*boneMapping)
+	if err != nil {
+		return err
+	}
+
+	_, retargetedModel, err := usecase.NewRetarget(model, overrides)
+	if err != nil {
+		return err
+	}
+
+	if *insertBones {
+		if err := usecase.AugmentModel(retargetedModel); err != nil {
+			return err
+		}
+	}
+
+	report := usecase.CheckModel(retargetedModel, *useRootMotion)
+	report.Log()
+	if !report.Passed() {
+		return fmt.Errorf("model is not compatible: %s", args[0])
+	}
+
+	mlog.I("Model is compatible: %s", args[0])
+	return nil
+}
+
+// runExport mat5 export <format> ...
+func runExport(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: mat5 export <csv|gltf|vpd> ...")
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "csv":
+		return runExportCsv(args[1:])
+	case "gltf", "glb":
+		return runExportGltf(args[1:])
+	case "vpd":
+		return runExportVpd(args[1:])
+	default:
+		return fmt.Errorf("unknown export format: %s", args[0])
+	}
+}
+
+// runExportCsv mat5 export csv <input.vmd> [output.csv]
+func runExportCsv(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: mat5 export csv <input.vmd> [output.csv]")
+	}
+
+	inputPath := args[0]
+	outputPath := replaceExt(inputPath, ".csv")
+	if len(args) > 1 {
+		outputPath = args[1]
+	}
+
+	motion, err := loadMotion(inputPath)
+	if err != nil {
+		return err
+	}
+
+	return repository.NewVmdCsvRepository().Save(outputPath, motion, false)
+}
+
+// runExportGltf mat5 export gltf [-scale 0.08] <model.pmx> [motion.vmd] [output.glb|output.gltf]
+func runExportGltf(args []string) error {
+	fs := flag.NewFlagSet("export gltf", flag.ContinueOnError)
+	scale := fs.Float64("scale", repository.GLTF_DEFAULT_SCALE, "MMD unit to meter scale")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+
+	if len(args) < 1 {
+		return fmt.Errorf("usage: mat5 export gltf [-scale 0.08] <model.pmx> [motion.vmd] [output.glb]")
+	}
+
+	model, err := loadModel(args[0])
+	if err != nil {
+		return err
+	}
+
+	var motion *vmd.VmdMotion
+	outputPath := replaceExt(args[0], ".glb")
+	for _, arg := range args[1:] {
+		switch strings.ToLower(filepath.Ext(arg)) {
+		case ".vmd", ".vpd":
+			if motion, err = loadMotion(arg); err != nil {
+				return err
+			}
+		default:
+			outputPath = arg
+		}
+	}
+
+	return usecase.ExportGltf(model, motion, outputPath, *scale)
+}
+
+// runExportVpd mat5 export vpd [-frames 0,30,60] [-interval 30] <input.vmd> [outputDir]
+func runExportVpd(args []string) error {
+	fs := flag.NewFlagSet("export vpd", flag.ContinueOnError)
+	frameList := fs.String("frames", "", "comma separated frame numbers (default: detected key poses)")
+	interval := fs.Int("interval", usecase.KEY_POSE_INTERVAL, "minimum frames between detected key poses")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+
+	if len(args) < 1 {
+		return fmt.Errorf("usage: mat5 export vpd [-frames 0,30,60] [-interval 30] <input.vmd> [outputDir]")
+	}
+
+	frames := make([]int, 0)
+	for _, value := range strings.Split(*frameList, ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		fno, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid frame number: %s", value)
+		}
+		frames = append(frames, fno)
+	}
+
+	motion, err := loadMotion(args[0])
+	if err != nil {
+		return err
+	}
+
+	outputDir := filepath.Dir(args[0])
+	if len(args) > 1 {
+		outputDir = args[1]
+		if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+
+	paths, err := usecase.ExportVpd(motion, frames, *interval, outputDir)
+	for _, path := range paths {
+		mlog.I("Output Pose: %s", path)
+	}
+
+	return err
+}
+
+// runRetime mat5 retime -fps 60 <input.vmd> [output.vmd]
+func runRetime(args []string) error {
+	fs := flag.NewFlagSet("retime", flag.ContinueOnError)
+	fps := fs.Float64("fps", 0, "fps of the input motion")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+
+	if len(args) < 1 || *fps <= 0 {
+		return fmt.Errorf("usage: mat5 retime -fps 60 <input.vmd> [output.vmd]")
+	}
+
+	inputPath := args[0]
+	outputPath := replaceExt(inputPath, fmt.Sprintf("_%.0ffps.vmd", vmd.VMD_FPS))
+	if len(args) > 1 {
+		outputPath = args[1]
+	}
+
+	motion, err := loadMotion(inputPath)
+	if err != nil {
+		return err
+	}
+
+	retimeMotion, err := usecase.Retime(motion, *fps)
+	if err != nil {
+		return err
+	}
+	retimeMotion.SetPath(outputPath)
+
+	return repository.NewVmdRepository(true).Save(outputPath, retimeMotion, false)
+}
+
+// runWarp mat5 warp (-rate 2 | -map 0:0,100:100,200:300) <input.vmd> [output.vmd]
+func runWarp(args []string) error {
+	fs := flag.NewFlagSet("warp", flag.ContinueOnError)
+	rate := fs.Float64("rate", 0, "playback speed (2 plays twice as fast)")
+	mapping := fs.String("map", "", "comma separated old:new frame pairs of piecewise-linear time warp")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+
+	if len(args) < 1 || (*rate <= 0) == (*mapping == "") {
+		return fmt.Errorf("usage: mat5 warp (-rate 2 | -map 0:0,100:100,200:300) <input.vmd> [output.vmd]")
+	}
+
+	warp, err := parseTimeWarp(*rate, *mapping)
+	if err != nil {
+		return err
+	}
+
+	inputPath := args[0]
+	outputPath := replaceExt(inputPath, "_warp.vmd")
+	if len(args) > 1 {
+		outputPath = args[1]
+	}
+
+	motion, err := loadMotion(inputPath)
+	if err != nil {
+		return err
+	}
+
+	warpMotion, err := usecase.Warp(motion, warp)
+	if err != nil {
+		return err
+	}
+	warpMotion.SetPath(outputPath)
+
+	return repository.NewVmdRepository(true).Save(outputPath, warpMotion, false)
+}
+
+// runCrossfade mat5 crossfade -start 100 -end 130 [-easing ease-in-out] <from.vmd> <to.vmd> [output.vmd]
+// 撮り直しを途中に差し込む場合は、元→撮り直し、撮り直し→元 の順に2回実行する
+func runCrossfade(args []string) error {
+	fs := flag.NewFlagSet("crossfade", flag.ContinueOnError)
+	start := fs.Int("start", -1, "first frame of the transition window")
+	end := fs.Int("end", -1, "last frame of the transition window")
+	easing := fs.String("easing", usecase.DEFAULT_EASING, "easing of the transition (linear, ease-in, ease-out, ease-in-out)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+
+	if len(args) < 2 || *start < 0 || *end <= *start {
+		return fmt.Errorf("usage: mat5 crossfade -start 100 -end 130 [-easing ease-in-out] <from.vmd> <to.vmd> [output.vmd]")
+	}
+
+	outputPath := replaceExt(args[0], "_crossfade.vmd")
+	if len(args) > 2 {
+		outputPath = args[2]
+	}
+
+	from, err := loadMotion(args[0])
+	if err != nil {
+		return err
+	}
+	to, err := loadMotion(args[1])
+	if err != nil {
+		return err
+	}
+
+	blendMotion, err := usecase.Crossfade(from, to, *start, *end, *easing)
+	if err != nil {
+		return err
+	}
+	blendMotion.SetPath(outputPath)
+
+	return repository.NewVmdRepository(true).Save(outputPath, blendMotion, false)
+}
+
+// runMirror mat5 mirror <input.vmd> [output.vmd]
+func runMirror(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: mat5 mirror <input.vmd> [output.vmd]")
+	}
+
+	outputPath := replaceExt(args[0], "_mirror.vmd")
+	if len(args) > 1 {
+		outputPath = args[1]
+	}
+
+	motion, err := loadMotion(args[0])
+	if err != nil {
+		return err
+	}
+
+	mirrorMotion, err := usecase.Mirror(motion)
+	if err != nil {
+		return err
+	}
+	mirrorMotion.SetPath(outputPath)
+
+	return repository.NewVmdRepository(true).Save(outputPath, mirrorMotion, false)
+}
+
+// runCompare mat5 compare -model x.pmx [-csv output] [-worst 10] <a.vmd> <b.vmd>
+// フラグはファイルの後ろに置いてもよい(mat5 compare a.vmd b.vmd --model x.pmx)
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	modelPath := fs.String("model", "", "model path used to deform both motions")
+	csvPath := fs.String("csv", "", "output csv path prefix (default: <a>_compare)")
+	worst := fs.Int("worst", usecase.COMPARE_WORST_FRAMES, "number of worst frames to show")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) < 2 || *modelPath == "" {
+		return fmt.Errorf("usage: mat5 compare -model x.pmx [-csv output] [-worst 10] <a.vmd> <b.vmd>")
+	}
+
+	model, err := loadModel(*modelPath)
+	if err != nil {
+		return err
+	}
+	motionA, err := loadMotion(args[0])
+	if err != nil {
+		return err
+	}
+	motionB, err := loadMotion(args[1])
+	if err != nil {
+		return err
+	}
+
+	diff := usecase.CompareMotions(model, motionA, motionB)
+	usecase.LogMotionDiff(diff, *worst)
+
+	outputPath := *csvPath
+	if outputPath == "" {
+		outputPath = replaceExt(args[0], "_compare")
+	}
+	paths, err := usecase.SaveMotionDiffCsv(diff, strings.TrimSuffix(outputPath, ".csv"))
+	for _, path := range paths {
+		mlog.I("Output Csv: %s", path)
+	}
+
+	return err
+}
+
+// parseInterspersed 位置引数の前後どちらにあるフラグも解析し、位置引数を返す
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	positionals := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positionals, nil
+		}
+		positionals = append(positionals, args[0])
+		args = args[1:]
+	}
+}
+
+// parseTimeWarp 一定の速さ、または "元:変換後" のフレームの組のカンマ区切りからタイムワープを作る
+func parseTimeWarp(rate float64, mapping string) (*vmd.TimeWarp, error) {
+	if rate > 0 {
+		return vmd.NewRateTimeWarp(rate), nil
+	}
+
+	oldFrames := make([]float64, 0)
+	newFrames := make([]float64, 0)
+	for _, value := range strings.Split(mapping, ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		oldValue, newValue, ok := strings.Cut(value, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid frame pair: %s", value)
+		}
+		oldFrame, err := strconv.ParseFloat(strings.TrimSpace(oldValue), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid frame pair: %s", value)
+		}
+		newFrame, err := strconv.ParseFloat(strings.TrimSpace(newValue), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid frame pair: %s", value)
+		}
+		oldFrames = append(oldFrames, oldFrame)
+		newFrames = append(newFrames, newFrame)
+	}
+
+	return vmd.NewTimeWarp(oldFrames, newFrames)
+}
+
+// runImport mat5 import <format> <input> [output.vmd]
+func runImport(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: mat5 import csv <input.csv> [output.vmd]")
+	}
+
+	format, inputPath := strings.ToLower(args[0]), args[1]
+	outputPath := replaceExt(inputPath, ".vmd")
+	if len(args) > 2 {
+		outputPath = args[2]
+	}
+
+	switch format {
+	case "csv":
+		data, err := repository.NewVmdCsvRepository().Load(inputPath)
+		if err != nil {
+			return err
+		}
+		motion := data.(*vmd.VmdMotion)
+		motion.SetPath(outputPath)
+
+		mlog.I("Import %d bone frames, %d morph frames", motion.BoneFrames.Length(), motion.MorphFrames.Length())
+
+		return repository.NewVmdRepository(true).Save(outputPath, motion, false)
+	default:
+		return fmt.Errorf("unknown import format: %s", format)
+	}
+}
+
+func replaceExt(path, ext string) string {
+	dir, name, _ := mfile.SplitPath(path)
+	return fmt.Sprintf("%s%s%s", dir, name, ext)
+}
+
+func loadMotion(path string) (*vmd.VmdMotion, error) {
+	data, err := repository.NewVmdVpdRepository(true).Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return data.(*vmd.VmdMotion), nil
+}
+
+func loadModel(path string) (*pmx.PmxModel, error) {
+	data, err := repository.NewPmxRepository(true).Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return data.(*pmx.PmxModel), nil
+}
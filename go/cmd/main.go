@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mjson"
 	"github.com/miu200521358/mmd-auto-trace-5/pkg/usecase"
 	"github.com/miu200521358/mmd-auto-trace-5/pkg/utils"
 )
@@ -13,11 +14,37 @@ import (
 var logLevel string
 var modelPath string
 var dirPath string
+var jointMapping string
+var useRootMotion bool
+var boneMapping string
+var insertBones bool
+var saveModel bool
+var legD bool
+var reduce bool
+var reducePositionError float64
+var reduceDegreeError float64
+var fps float64
+var evaluate bool
+var evaluateThreshold float64
+var evaluateReprojectionThreshold float64
 
 func init() {
 	flag.StringVar(&logLevel, "logLevel", "INFO", "set log level")
 	flag.StringVar(&modelPath, "modelPath", "", "set model path")
 	flag.StringVar(&dirPath, "dirPath", "", "set directory path")
+	flag.StringVar(&jointMapping, "jointMapping", mjson.DEFAULT_JOINT_MAPPING, "set joint mapping name or definition file path")
+	flag.Float64Var(&fps, "fps", usecase.DEFAULT_FPS, "set source fps of tracking json (overridden by <clip>.fps or fps in json)")
+	flag.BoolVar(&useRootMotion, "rootMotion", false, "put long-range travel on root bone")
+	flag.BoolVar(&insertBones, "insertBones", false, "insert missing bones into a copy of the model before conversion")
+	flag.BoolVar(&saveModel, "saveModel", false, "save the model with inserted bones alongside the motion")
+	flag.BoolVar(&legD, "legD", false, "write leg rotations to D-bones (足D/ひざD/足首D) with leg IK turned off")
+	flag.BoolVar(&reduce, "reduce", false, "also output a motion with keyframes reduced by fitting interpolation curves")
+	flag.Float64Var(&reducePositionError, "reducePositionError", usecase.REDUCE_POSITION_ERROR, "set max position error of reduced motion")
+	flag.Float64Var(&reduceDegreeError, "reduceDegreeError", usecase.REDUCE_DEGREE_ERROR, "set max rotation error (degrees) of reduced motion")
+	flag.BoolVar(&evaluate, "evaluate", false, "evaluate deformed bone positions against tracked joints and write a report csv")
+	flag.Float64Var(&evaluateThreshold, "evaluateThreshold", usecase.EVALUATE_ERROR_THRESHOLD, "set MPJPE above which frames are flagged for cleanup")
+	flag.Float64Var(&evaluateReprojectionThreshold, "evaluateReprojectionThreshold", usecase.EVALUATE_REPROJECTION_THRESHOLD, "set reprojection error (pixels) against 2D joints above which frames are flagged for cleanup")
+	flag.StringVar(&boneMapping, "boneMapping", "", "set bone name override file path (json: {\"standard bone name\": \"model bone name\"})")
 	flag.Parse()
 
 	switch logLevel {
@@ -29,12 +56,66 @@ func init() {
 }
 
 func main() {
+	if len(flag.Args()) > 0 {
+		// サブコマンド
+		if err := runCommand(flag.Args()); err != nil {
+			mlog.E("%v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if modelPath == "" || dirPath == "" {
 		err := fmt.Errorf("modelPath and dirPath must be provided")
 		mlog.E("%v", err)
 		os.Exit(1)
 	}
 
+	mapping, err := mjson.LoadJointMapping(jointMapping)
+	if err != nil {
+		mlog.E("Failed to load joint mapping", err)
+		os.Exit(1)
+	}
+
+	model, err := loadModel(modelPath)
+	if err != nil {
+		mlog.E("Failed to read pmx", err)
+		return
+	}
+
+	overrides, err := usecase.LoadRetargetOverrides(boneMapping)
+	if err != nil {
+		mlog.E("Failed to load bone mapping", err)
+		return
+	}
+
+	// 標準ボーン名以外のモデルでも変換できるように、標準ボーン名に置き換えたモデルで計算する
+	retarget, model, err := usecase.NewRetarget(model, overrides)
+	if err != nil {
+		mlog.E("Failed to retarget model", err)
+		return
+	}
+
+	if insertBones {
+		if err := usecase.AugmentModel(model); err != nil {
+			mlog.E("Failed to insert bones", err)
+			return
+		}
+	}
+
+	mlog.I("Check model ================")
+	report := usecase.CheckModel(model, useRootMotion)
+	report.Log()
+	if !report.Passed() {
+		mlog.E("Model is not compatible", fmt.Errorf("required bones are missing: %s", modelPath))
+		os.Exit(1)
+	}
+
+	if legD && !usecase.HasLegD(model) {
+		mlog.W("Model has no leg D-bones, output leg rotations to normal leg bones")
+		legD = false
+	}
+
 	jsonDirPath := fmt.Sprintf("%s/json", dirPath)
 
 	if _, err := os.Stat(jsonDirPath); os.IsNotExist(err) {
@@ -51,9 +132,13 @@ func main() {
 
 	allNum := len(allFrames)
 
+	for i, frames := range allFrames {
+		usecase.Resample(frames, fps, i+1, allNum)
+	}
+
 	mlog.I("[%d] Calculation Center Z ===========================", allNum)
 
-	minY, maxZ := usecase.CalcMinYZ(allFrames)
+	minY, maxZ := usecase.CalcMinYZ(allFrames, mapping)
 	vmdDirPath := fmt.Sprintf("%s/vmd", dirPath)
 
 	err = os.MkdirAll(vmdDirPath, os.ModePerm)
@@ -62,6 +147,14 @@ func main() {
 		return
 	}
 
+	if insertBones && saveModel {
+		if path, err := usecase.SaveAugmentedModel(model, retarget, vmdDirPath); err != nil {
+			mlog.E("Failed to save pmx", err)
+		} else {
+			mlog.I("Output Model: %s", path)
+		}
+	}
+
 	for i, frames := range allFrames {
 		motionNum := i + 1
 
@@ -72,18 +165,68 @@ func main() {
 
 		mlog.I("[%d/%d] Convert Motion ===========================", motionNum, allNum)
 
-		moveMotion := usecase.Move(frames, motionNum, allNum, minY, maxZ)
+		usecase.FixBoneLength(frames, mapping, motionNum, allNum)
+
+		scale := usecase.CalcScale(frames, mapping, model, motionNum, allNum)
+
+		moveMotion := usecase.Move(frames, mapping, scale, motionNum, allNum, minY, maxZ)
 
 		if mlog.IsDebug() {
 			utils.WriteVmdMotions(frames, moveMotion, vmdDirPath, "_1move", "Move", motionNum, allNum)
 		}
 
-		rotateMotion := usecase.Rotate(moveMotion, modelPath, motionNum, allNum)
+		rotateMotion := usecase.Rotate(moveMotion, model, motionNum, allNum)
+
+		usecase.BendToes(moveMotion, rotateMotion, model, motionNum, allNum)
 
 		if mlog.IsDebug() {
 			utils.WriteVmdMotions(frames, rotateMotion, vmdDirPath, "_2rotate", "Rotate", motionNum, allNum)
 		}
 
+		limitMotion := usecase.LimitJoints(rotateMotion, model, motionNum, allNum)
+
+		if mlog.IsDebug() {
+			utils.WriteVmdMotions(frames, limitMotion, vmdDirPath, "_3limit", "Limit", motionNum, allNum)
+		}
+
+		usecase.DistributeTwist(limitMotion, model, motionNum, allNum)
+
+		// 可動域の制限・捩り分散で書き換えた回転も含めて、最後に連続性を修復する
+		usecase.FixContinuity(limitMotion, motionNum, allNum)
+
+		rootMotion := usecase.SplitRootMotion(limitMotion, model, useRootMotion, motionNum, allNum)
+
+		if mlog.IsDebug() {
+			utils.WriteVmdMotions(frames, rootMotion, vmdDirPath, "_4root", "Root", motionNum, allNum)
+		}
+
+		if legD {
+			legDMotion := usecase.ConvertLegD(rootMotion, model, motionNum, allNum)
+			usecase.VerifyLegD(rootMotion, legDMotion, model, motionNum, allNum)
+			rootMotion = legDMotion
+		}
+
+		// 評価は出力するモーションで行う。リターゲットはボーン名を置き換えるだけで変形は変わらないので、
+		// 標準ボーン名のモデルとモーションで評価する
+		if evaluate {
+			trackingError := usecase.EvaluateTracking(frames, mapping, model, rootMotion, scale, minY, maxZ, motionNum, allNum)
+			usecase.LogTrackingError(trackingError, evaluateThreshold, evaluateReprojectionThreshold, motionNum, allNum)
+			if path, err := usecase.SaveTrackingReport(trackingError, frames, evaluateThreshold, evaluateReprojectionThreshold, vmdDirPath); err != nil {
+				mlog.E("Failed to save evaluation report", err)
+			} else {
+				mlog.I("Output Evaluation: %s", path)
+			}
+		}
+
+		retargetMotion := usecase.RetargetMotion(rootMotion, retarget, motionNum, allNum)
+
+		utils.WriteVmdMotions(frames, retargetMotion, vmdDirPath, "", "Output", motionNum, allNum)
+
+		if reduce {
+			reduceMotion := usecase.Reduce(retargetMotion, reducePositionError, reduceDegreeError, motionNum, allNum)
+			utils.WriteVmdMotions(frames, reduceMotion, vmdDirPath, "_reduce", "Reduce", motionNum, allNum)
+		}
+
 		// legIkMotion := usecase.ConvertLegIk(rotateMotion, modelPath, motionNum, allNum)
 
 		// if mlog.IsDebug() {
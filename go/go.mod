@@ -11,6 +11,7 @@ require (
 	github.com/tiendc/go-deepcopy v1.7.2
 	golang.org/x/exp v0.0.0-20251219203646-944ab1f22d93
 	golang.org/x/text v0.33.0
+	gonum.org/v1/gonum v0.17.0
 )
 
 require (
@@ -26,5 +27,4 @@ require (
 	github.com/rivo/uniseg v0.4.7 // indirect
 	golang.org/x/sys v0.39.0 // indirect
 	golang.org/x/tools v0.40.0 // indirect
-	gonum.org/v1/gonum v0.17.0 // indirect
 )
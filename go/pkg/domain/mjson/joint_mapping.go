@@ -0,0 +1,296 @@
+package mjson
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+)
+
+// DEFAULT_JOINT_MAPPING 既定の関節定義名
+const DEFAULT_JOINT_MAPPING = "smpl"
+
+//go:embed joint_mappings/*
+var jointMappingFiles embed.FS
+
+// JointDefinition トラッカーの関節定義
+type JointDefinition struct {
+	Name    string   `json:"name"`              // 関節名
+	Aliases []string `json:"aliases,omitempty"` // 別名(キーポイント番号など)
+	Parent  string   `json:"parent,omitempty"`  // 親関節名(骨格の長さ補正に使用)
+	Bone    string   `json:"bone,omitempty"`    // 対応するボーン名(空の場合、合成元としてのみ使用)
+}
+
+// SyntheticBoneDefinition 他の関節・ボーンから合成するボーンの定義
+type SyntheticBoneDefinition struct {
+	Bone    string    `json:"bone"`              // 合成するボーン名
+	Sources []string  `json:"sources"`           // 合成元の関節名もしくは合成済みのボーン名
+	Weights []float64 `json:"weights,omitempty"` // 合成元の重み(省略時は均等)
+}
+
+// JointMapping トラッカーの関節からボーンへの対応定義
+type JointMapping struct {
+	Name       string                     `json:"name"`
+	FlipAxes   []string                   `json:"flip_axes"`   // 反転する軸(x, y, z)
+	RootJoints []string                   `json:"root_joints"` // 基準位置とする関節(複数の場合は中間)
+	Joints     []*JointDefinition         `json:"joints"`
+	Synthetics []*SyntheticBoneDefinition `json:"synthetics"`
+	jointNames map[string]string          // 関節名・別名 -> 関節名
+	jointOrder []*JointDefinition         // 親から順に並べた関節
+	flip       *mmath.MVec3
+}
+
+// JointMappingNames 組み込みの関節定義名一覧
+func JointMappingNames() []string {
+	entries, err := jointMappingFiles.ReadDir("joint_mappings")
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())))
+	}
+	return names
+}
+
+// LoadJointMapping 組み込みの関節定義名、もしくは定義ファイルのパスから関節定義を読み込む
+func LoadJointMapping(nameOrPath string) (*JointMapping, error) {
+	if nameOrPath == "" {
+		nameOrPath = DEFAULT_JOINT_MAPPING
+	}
+
+	data, err := jointMappingFiles.ReadFile(fmt.Sprintf("joint_mappings/%s.json", strings.ToLower(nameOrPath)))
+	if err != nil {
+		if data, err = os.ReadFile(nameOrPath); err != nil {
+			return nil, fmt.Errorf("joint mapping not found: %s (builtin: %s)",
+				nameOrPath, strings.Join(JointMappingNames(), ", "))
+		}
+	}
+
+	return NewJointMappingByJson(data)
+}
+
+// NewJointMappingByJson JSONから関節定義を生成する
+func NewJointMappingByJson(data []byte) (*JointMapping, error) {
+	mapping := &JointMapping{}
+	if err := json.Unmarshal(data, mapping); err != nil {
+		return nil, err
+	}
+
+	if err := mapping.Setup(); err != nil {
+		return nil, err
+	}
+
+	return mapping, nil
+}
+
+// Setup 関節名の索引を作成し、定義の整合性を確認する
+func (mapping *JointMapping) Setup() error {
+	mapping.jointNames = make(map[string]string)
+	for _, joint := range mapping.Joints {
+		mapping.jointNames[joint.Name] = joint.Name
+		for _, alias := range joint.Aliases {
+			mapping.jointNames[alias] = joint.Name
+		}
+	}
+
+	mapping.flip = &mmath.MVec3{X: 1, Y: 1, Z: 1}
+	for _, axis := range mapping.FlipAxes {
+		switch strings.ToLower(axis) {
+		case "x":
+			mapping.flip.X = -1
+		case "y":
+			mapping.flip.Y = -1
+		case "z":
+			mapping.flip.Z = -1
+		default:
+			return fmt.Errorf("joint mapping %s: unknown flip axis %s", mapping.Name, axis)
+		}
+	}
+
+	// 合成元は関節名か、それより前に定義されたボーン名であること
+	boneNames := make(map[string]bool)
+	for _, joint := range mapping.Joints {
+		if joint.Bone != "" {
+			boneNames[joint.Bone] = true
+		}
+	}
+	for _, synthetic := range mapping.Synthetics {
+		if len(synthetic.Sources) == 0 {
+			return fmt.Errorf("joint mapping %s: %s has no sources", mapping.Name, synthetic.Bone)
+		}
+		if len(synthetic.Weights) > 0 && len(synthetic.Weights) != len(synthetic.Sources) {
+			return fmt.Errorf("joint mapping %s: %s weights count mismatch", mapping.Name, synthetic.Bone)
+		}
+		for _, source := range synthetic.Sources {
+			if _, ok := mapping.jointNames[source]; !ok && !boneNames[source] {
+				return fmt.Errorf("joint mapping %s: %s source %s is not defined", mapping.Name, synthetic.Bone, source)
+			}
+		}
+		boneNames[synthetic.Bone] = true
+	}
+
+	if err := mapping.setupJointOrder(); err != nil {
+		return err
+	}
+
+	for _, root := range mapping.RootJoints {
+		if _, ok := mapping.jointNames[root]; !ok {
+			return fmt.Errorf("joint mapping %s: root joint %s is not defined", mapping.Name, root)
+		}
+	}
+
+	return nil
+}
+
+// setupJointOrder 親関節が子関節より先に来るように並べる
+func (mapping *JointMapping) setupJointOrder() error {
+	joints := make(map[string]*JointDefinition)
+	for _, joint := range mapping.Joints {
+		joints[joint.Name] = joint
+	}
+
+	mapping.jointOrder = make([]*JointDefinition, 0, len(mapping.Joints))
+	visited := make(map[string]bool)
+	visiting := make(map[string]bool)
+
+	var visit func(joint *JointDefinition) error
+	visit = func(joint *JointDefinition) error {
+		if visited[joint.Name] {
+			return nil
+		}
+		if visiting[joint.Name] {
+			return fmt.Errorf("joint mapping %s: parent of %s is circular", mapping.Name, joint.Name)
+		}
+		visiting[joint.Name] = true
+
+		if joint.Parent != "" {
+			parent, ok := joints[joint.Parent]
+			if !ok {
+				return fmt.Errorf("joint mapping %s: parent %s of %s is not defined", mapping.Name, joint.Parent, joint.Name)
+			}
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+
+		visited[joint.Name] = true
+		mapping.jointOrder = append(mapping.jointOrder, joint)
+		return nil
+	}
+
+	for _, joint := range mapping.Joints {
+		if err := visit(joint); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// JointsByHierarchy 親関節が子関節より先に来る順で関節定義を返す
+func (mapping *JointMapping) JointsByHierarchy() []*JointDefinition {
+	return mapping.jointOrder
+}
+
+// JointName 関節名もしくは別名から関節名を取得する
+func (mapping *JointMapping) JointName(key string) (string, bool) {
+	name, ok := mapping.jointNames[key]
+	return name, ok
+}
+
+// Flipped 軸反転を適用した位置を返す
+func (mapping *JointMapping) Flipped(pos Position) *mmath.MVec3 {
+	return &mmath.MVec3{X: pos.X * mapping.flip.X, Y: pos.Y * mapping.flip.Y, Z: pos.Z * mapping.flip.Z}
+}
+
+// RootPosition 基準関節の位置を返す(軸反転前)
+func (mapping *JointMapping) RootPosition(joints map[string]Position) (Position, bool) {
+	if len(mapping.RootJoints) == 0 {
+		return Position{}, false
+	}
+
+	positions := make(map[string]Position)
+	for key, pos := range joints {
+		if name, ok := mapping.jointNames[key]; ok {
+			positions[name] = pos
+		}
+	}
+
+	root := Position{}
+	for _, name := range mapping.RootJoints {
+		pos, ok := positions[name]
+		if !ok {
+			return Position{}, false
+		}
+		root.X += pos.X
+		root.Y += pos.Y
+		root.Z += pos.Z
+	}
+	n := float64(len(mapping.RootJoints))
+
+	return Position{X: root.X / n, Y: root.Y / n, Z: root.Z / n}, true
+}
+
+// BonePositions 関節位置をボーン位置に変換し、合成ボーンも計算する
+// convert で各関節位置を変換(軸反転・オフセット・スケール等)する
+func (mapping *JointMapping) BonePositions(
+	joints map[string]Position, convert func(pos Position) *mmath.MVec3,
+) map[string]*mmath.MVec3 {
+	jointPositions := make(map[string]*mmath.MVec3)
+	for key, pos := range joints {
+		if name, ok := mapping.jointNames[key]; ok {
+			jointPositions[name] = convert(pos)
+		}
+	}
+
+	bonePositions := make(map[string]*mmath.MVec3)
+	for _, joint := range mapping.Joints {
+		if pos, ok := jointPositions[joint.Name]; ok && joint.Bone != "" {
+			bonePositions[joint.Bone] = pos
+		}
+	}
+
+	for _, synthetic := range mapping.Synthetics {
+		if pos := synthetic.position(bonePositions, jointPositions); pos != nil {
+			bonePositions[synthetic.Bone] = pos
+		}
+	}
+
+	return bonePositions
+}
+
+// position 合成元の加重平均。合成元が欠けている場合はnil
+func (synthetic *SyntheticBoneDefinition) position(
+	bonePositions, jointPositions map[string]*mmath.MVec3,
+) *mmath.MVec3 {
+	pos := mmath.NewMVec3()
+	totalWeight := 0.0
+
+	for i, source := range synthetic.Sources {
+		sourcePos, ok := bonePositions[source]
+		if !ok {
+			if sourcePos, ok = jointPositions[source]; !ok {
+				return nil
+			}
+		}
+
+		weight := 1.0
+		if len(synthetic.Weights) > 0 {
+			weight = synthetic.Weights[i]
+		}
+		pos.Add(sourcePos.MuledScalar(weight))
+		totalWeight += weight
+	}
+
+	if totalWeight == 0 {
+		return nil
+	}
+
+	return pos.DivedScalar(totalWeight)
+}
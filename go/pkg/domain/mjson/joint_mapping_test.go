@@ -0,0 +1,108 @@
+package mjson
+
+import (
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+)
+
+func TestLoadJointMapping_Builtin(t *testing.T) {
+	names := JointMappingNames()
+	if len(names) != 4 {
+		t.Errorf("Expected 4 builtin mappings, got %v", names)
+	}
+
+	for _, name := range names {
+		mapping, err := LoadJointMapping(name)
+		if err != nil {
+			t.Fatalf("Expected error to be nil for %s, got %q", name, err)
+		}
+		if mapping.Name != name {
+			t.Errorf("Expected name %s, got %s", name, mapping.Name)
+		}
+	}
+}
+
+func TestJointMapping_BonePositions(t *testing.T) {
+	mapping, err := LoadJointMapping("coco_wholebody")
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	// キーポイント番号でも関節名でも受け付ける
+	joints := map[string]Position{
+		"11":             {X: 1, Y: 0, Z: 0},
+		"right_hip":      {X: -1, Y: 0, Z: 0},
+		"left_shoulder":  {X: 2, Y: -4, Z: 0},
+		"right_shoulder": {X: -2, Y: -4, Z: 0},
+	}
+
+	bonePositions := mapping.BonePositions(joints, func(pos Position) *mmath.MVec3 {
+		return mapping.Flipped(pos)
+	})
+
+	expected := map[string]*mmath.MVec3{
+		"左足":   {X: 1, Y: 0, Z: 0},
+		"上半身":  {X: 0, Y: 0, Z: 0},
+		"首":    {X: 0, Y: 4, Z: 0},
+		"上半身2": {X: 0, Y: 2, Z: 0},
+		"下半身先": {X: 0, Y: 0, Z: 0},
+		"下半身":  {X: 0, Y: 0, Z: 0},
+	}
+	for boneName, expectedPos := range expected {
+		actual, ok := bonePositions[boneName]
+		if !ok {
+			t.Errorf("Expected %s to be calculated", boneName)
+			continue
+		}
+		if !actual.NearEquals(expectedPos, 1e-8) {
+			t.Errorf("Expected %s position %v, got %v", boneName, expectedPos, actual)
+		}
+	}
+
+	// 合成元が欠けている場合は出力しない
+	if _, ok := bonePositions["頭"]; ok {
+		t.Errorf("Expected 頭 not to be calculated")
+	}
+}
+
+func TestJointMapping_JointsByHierarchy(t *testing.T) {
+	for _, name := range JointMappingNames() {
+		mapping, err := LoadJointMapping(name)
+		if err != nil {
+			t.Fatalf("Expected error to be nil for %s, got %q", name, err)
+		}
+
+		visited := make(map[string]bool)
+		for _, joint := range mapping.JointsByHierarchy() {
+			if joint.Parent != "" && !visited[joint.Parent] {
+				t.Errorf("Expected %s parent %s to come first in %s", joint.Name, joint.Parent, name)
+			}
+			visited[joint.Name] = true
+		}
+		if len(visited) != len(mapping.Joints) {
+			t.Errorf("Expected %d joints in %s, got %d", len(mapping.Joints), name, len(visited))
+		}
+	}
+}
+
+func TestNewJointMappingByJson_CircularParent(t *testing.T) {
+	_, err := NewJointMappingByJson([]byte(`{
+		"name": "test",
+		"joints": [{"name": "a", "parent": "b"}, {"name": "b", "parent": "a"}]
+	}`))
+	if err == nil {
+		t.Errorf("Expected error for circular parent")
+	}
+}
+
+func TestNewJointMappingByJson_UndefinedSource(t *testing.T) {
+	_, err := NewJointMappingByJson([]byte(`{
+		"name": "test",
+		"joints": [{"name": "a", "bone": "A"}],
+		"synthetics": [{"bone": "B", "sources": ["A", "c"]}]
+	}`))
+	if err == nil {
+		t.Errorf("Expected error for undefined source")
+	}
+}
@@ -26,5 +26,6 @@ type Frame struct {
 
 type Frames struct {
 	Path   string
+	Fps    float64       `json:"fps"` // 元動画のフレームレート(無い場合は0)
 	Frames map[int]Frame `json:"frames"`
 }
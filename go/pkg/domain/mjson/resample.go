@@ -0,0 +1,107 @@
+package mjson
+
+import (
+	"maps"
+	"math"
+	"slices"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+)
+
+// Resample sourceFps のフレーム番号を targetFps のフレーム番号に置き換え、間の値を線形補間する
+// 前後どちらかのフレームが欠けている(トラッキングできていない)時間は補間せず、欠けたままにする
+func (frames *Frames) Resample(sourceFps, targetFps float64) {
+	if sourceFps <= 0 || targetFps <= 0 || mmath.NearEquals(sourceFps, targetFps, 1e-6) || len(frames.Frames) == 0 {
+		return
+	}
+
+	fnos := slices.Sorted(maps.Keys(frames.Frames))
+	ratio := targetFps / sourceFps
+
+	resampledFrames := make(map[int]Frame)
+	for fno := int(math.Ceil(float64(fnos[0]) * ratio)); fno <= int(float64(fnos[len(fnos)-1])*ratio); fno++ {
+		sourceTime := float64(fno) / ratio
+		prevFno := int(math.Floor(sourceTime))
+		t := sourceTime - float64(prevFno)
+
+		prevFrame, ok := frames.Frames[prevFno]
+		if !ok {
+			continue
+		}
+		if t < 1e-6 {
+			resampledFrames[fno] = prevFrame
+			continue
+		}
+
+		nextFrame, ok := frames.Frames[prevFno+1]
+		if !ok {
+			continue
+		}
+		resampledFrames[fno] = lerpFrame(prevFrame, nextFrame, t)
+	}
+
+	frames.Frames = resampledFrames
+	frames.Fps = targetFps
+}
+
+// lerpFrame 2つのフレームの値を線形補間したフレーム
+func lerpFrame(prev, next Frame, t float64) Frame {
+	frame := Frame{
+		Confidential:  mmath.Lerp(prev.Confidential, next.Confidential, t),
+		Camera:        lerpPosition(prev.Camera, next.Camera, t),
+		Joint3D:       lerpPositions(prev.Joint3D, next.Joint3D, t),
+		GlobalJoint3D: lerpPositions(prev.GlobalJoint3D, next.GlobalJoint3D, t),
+		Joint2D:       lerpPositions(prev.Joint2D, next.Joint2D, t),
+	}
+
+	if len(prev.TrackedBBox) == len(next.TrackedBBox) {
+		frame.TrackedBBox = make([]float64, len(prev.TrackedBBox))
+		for i := range prev.TrackedBBox {
+			frame.TrackedBBox[i] = mmath.Lerp(prev.TrackedBBox[i], next.TrackedBBox[i], t)
+		}
+	} else {
+		frame.TrackedBBox = prev.TrackedBBox
+	}
+
+	if prev.Mediapipe != nil {
+		frame.Mediapipe = make(map[string]PositionVisibility, len(prev.Mediapipe))
+		for name, prevPos := range prev.Mediapipe {
+			nextPos, ok := next.Mediapipe[name]
+			if !ok {
+				continue
+			}
+			frame.Mediapipe[name] = PositionVisibility{
+				X:          mmath.Lerp(prevPos.X, nextPos.X, t),
+				Y:          mmath.Lerp(prevPos.Y, nextPos.Y, t),
+				Z:          mmath.Lerp(prevPos.Z, nextPos.Z, t),
+				Visibility: mmath.Lerp(prevPos.Visibility, nextPos.Visibility, t),
+				Presence:   mmath.Lerp(prevPos.Presence, nextPos.Presence, t),
+			}
+		}
+	}
+
+	return frame
+}
+
+// lerpPositions 両方のフレームにある関節の位置を線形補間する
+func lerpPositions(prev, next map[string]Position, t float64) map[string]Position {
+	if prev == nil {
+		return nil
+	}
+
+	positions := make(map[string]Position, len(prev))
+	for name, prevPos := range prev {
+		if nextPos, ok := next[name]; ok {
+			positions[name] = lerpPosition(prevPos, nextPos, t)
+		}
+	}
+	return positions
+}
+
+func lerpPosition(prev, next Position, t float64) Position {
+	return Position{
+		X: mmath.Lerp(prev.X, next.X, t),
+		Y: mmath.Lerp(prev.Y, next.Y, t),
+		Z: mmath.Lerp(prev.Z, next.Z, t),
+	}
+}
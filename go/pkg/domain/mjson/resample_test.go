@@ -0,0 +1,54 @@
+package mjson
+
+import (
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+)
+
+func newResampleFrames(fnos ...int) *Frames {
+	frames := &Frames{Frames: make(map[int]Frame)}
+	for _, fno := range fnos {
+		frames.Frames[fno] = Frame{
+			Confidential: 1,
+			Joint3D:      map[string]Position{"pelvis": {X: float64(fno), Y: 1, Z: 2}},
+		}
+	}
+	return frames
+}
+
+func TestFrames_Resample(t *testing.T) {
+	// 60fps → 30fps は1フレームおきになり、欠けたフレームは欠けたまま
+	frames := newResampleFrames(0, 1, 2, 3, 5, 6)
+	frames.Resample(60, 30)
+
+	if len(frames.Frames) != 3 {
+		t.Errorf("Expected 3 frames, got %d", len(frames.Frames))
+	}
+	for fno, x := range map[int]float64{0: 0, 1: 2, 3: 6} {
+		if frame, ok := frames.Frames[fno]; !ok {
+			t.Errorf("Expected frame %d to be contained", fno)
+		} else if frame.Joint3D["pelvis"].X != x {
+			t.Errorf("[%d] Expected x to be %v, got %v", fno, x, frame.Joint3D["pelvis"].X)
+		}
+	}
+	if _, ok := frames.Frames[2]; ok {
+		t.Errorf("Expected missing frame 2 to be skipped")
+	}
+	if frames.Fps != 30 {
+		t.Errorf("Expected fps to be 30, got %v", frames.Fps)
+	}
+
+	// 24fps → 30fps は前後のフレームから補間する
+	frames = newResampleFrames(0, 1, 2, 3, 4)
+	frames.Resample(24, 30)
+
+	if len(frames.Frames) != 6 {
+		t.Errorf("Expected 6 frames, got %d", len(frames.Frames))
+	}
+	for fno := 0; fno <= 5; fno++ {
+		if x := frames.Frames[fno].Joint3D["pelvis"].X; !mmath.NearEquals(x, float64(fno)*0.8, 1e-6) {
+			t.Errorf("[%d] Expected x to be %v, got %v", fno, float64(fno)*0.8, x)
+		}
+	}
+}
@@ -119,14 +119,10 @@ func tryCurveNormalize(c0, c1, c2, c3 *MVec2, decreasing bool) *Curve {
 		p1, p2 = p2, p1
 	}
 
+	// 範囲外の制御点は範囲内に収める(近似の精度は呼び出し側で検算する)
 	curve := &Curve{
-		Start: *p1.MuledScalar(CURVE_MAX).Round(),
-		End:   *p2.MuledScalar(CURVE_MAX).Round(),
-	}
-
-	if curve.Start.X < 0 || curve.Start.X > CURVE_MAX || curve.Start.Y < 0 || curve.Start.Y > CURVE_MAX ||
-		curve.End.X < 0 || curve.End.X > CURVE_MAX || curve.End.Y < 0 || curve.End.Y > CURVE_MAX {
-		return nil
+		Start: *p1.MuledScalar(CURVE_MAX).Round().Clamp(CurveMin, CurveMax),
+		End:   *p2.MuledScalar(CURVE_MAX).Round().Clamp(CurveMin, CurveMax),
 	}
 
 	return curve
@@ -162,7 +158,7 @@ func Evaluate(curve *Curve, start, now, end float32) (x, y, t float64) {
 	t = newton(x1, x2, x, 0.5, 1e-15, 1e-20)
 	s := 1.0 - t
 
-	y = (3.0 * (math.Pow(s, 2.0)) * t * y1) + (3.0 * s * (math.Pow(t, 2.0)) * y2) + math.Pow(t, 3.0)
+	y = (3.0 * s * s * t * y1) + (3.0 * s * t * t * y2) + t*t*t
 
 	return x, y, t
 }
@@ -170,7 +166,7 @@ func Evaluate(curve *Curve, start, now, end float32) (x, y, t float64) {
 // 解を求める関数
 func newtonFuncF(x1, x2, x, t float64) float64 {
 	t1 := 1.0 - t
-	return 3.0*(math.Pow(t1, 2.0))*t*x1 + 3.0*t1*(math.Pow(t, 2.0))*x2 + math.Pow(t, 3.0) - x
+	return 3.0*t1*t1*t*x1 + 3.0*t1*t*t*x2 + t*t*t - x
 }
 
 // Newton法（方程式の関数項、探索の開始点、微小量、誤差範囲、最大反復回数）
@@ -367,8 +363,9 @@ func optimizePoints(xCoords, yCoords []float64, P0, P1, P2, P3 MVec2) (controlPo
 	method := &optimize.BFGS{}
 
 	// 最適化を実行して最適なP1とP2を見つける
+	// 収束しきらなかった場合も、それまでに見つかった最良の値を使う(近似の精度は呼び出し側で検算する)
 	result, err := optimize.Minimize(problem, initial, settings, method)
-	if err != nil {
+	if result == nil {
 		return controlPoints{}, err
 	}
 
@@ -386,8 +383,8 @@ func calculateError(xCoords, yCoords []float64, P1, P2 MVec2) float64 {
 	for i, x := range xCoords {
 		t := newton(P1.X, P2.X, x, 0.5, 1e-15, 1e-20)
 		s := 1.0 - t
-		y := (3.0 * (math.Pow(s, 2.0)) * t * P1.Y) + (3.0 * s * (math.Pow(t, 2.0)) * P2.Y) + math.Pow(t, 3.0)
-		totalError += math.Pow(yCoords[i]-y, 2)
+		y := (3.0 * s * s * t * P1.Y) + (3.0 * s * t * t * P2.Y) + t*t*t
+		totalError += (yCoords[i] - y) * (yCoords[i] - y)
 	}
 	return totalError
 }
@@ -0,0 +1,42 @@
+package mmath
+
+import "math"
+
+// GaussianSmoothed ガウシアンフィルタで平滑化した値を返す
+// 端では範囲内の重みだけで正規化するので、値が端に引っ張られない
+func GaussianSmoothed(values []float64, sigma float64) []float64 {
+	frames := make([]float64, len(values))
+	for i := range frames {
+		frames[i] = float64(i)
+	}
+	return GaussianSmoothedByFrames(frames, values, sigma)
+}
+
+// GaussianSmoothedByFrames フレーム番号を時間軸としてガウシアンフィルタで平滑化した値を返す
+// キーフレームの間隔が不揃い・欠けている場合も、フレーム差に応じた重みで平滑化する(frames は昇順)
+func GaussianSmoothedByFrames(frames, values []float64, sigma float64) []float64 {
+	smoothed := make([]float64, len(values))
+	if sigma <= 0 {
+		copy(smoothed, values)
+		return smoothed
+	}
+
+	radius := sigma * 3
+	start := 0
+	for i := range values {
+		for frames[i]-frames[start] > radius {
+			start++
+		}
+		sum := 0.0
+		totalWeight := 0.0
+		for j := start; j < len(values) && frames[j]-frames[i] <= radius; j++ {
+			d := frames[j] - frames[i]
+			weight := math.Exp(-d * d / (2 * sigma * sigma))
+			sum += values[j] * weight
+			totalWeight += weight
+		}
+		smoothed[i] = sum / totalWeight
+	}
+
+	return smoothed
+}
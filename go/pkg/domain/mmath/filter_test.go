@@ -0,0 +1,68 @@
+package mmath
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGaussianSmoothed(t *testing.T) {
+	// 直線はそのまま残る
+	values := make([]float64, 20)
+	for i := range values {
+		values[i] = float64(i)
+	}
+	smoothed := GaussianSmoothed(values, 1.5)
+	for i := 5; i < len(values)-5; i++ {
+		if math.Abs(smoothed[i]-values[i]) > 1e-8 {
+			t.Errorf("Expected %v at %d, got %v", values[i], i, smoothed[i])
+		}
+	}
+
+	// 突発的な値は抑えられる
+	spike := []float64{0, 0, 0, 0, 10, 0, 0, 0, 0}
+	smoothed = GaussianSmoothed(spike, 1.0)
+	if smoothed[4] >= 5 || smoothed[4] <= 0 {
+		t.Errorf("Expected spike to be reduced, got %v", smoothed[4])
+	}
+	if smoothed[3] <= 0 || smoothed[3] != smoothed[5] {
+		t.Errorf("Expected spike to spread symmetrically, got %v", smoothed)
+	}
+
+	// sigmaが0以下の場合はそのまま
+	smoothed = GaussianSmoothed(spike, 0)
+	for i := range spike {
+		if smoothed[i] != spike[i] {
+			t.Errorf("Expected %v at %d, got %v", spike[i], i, smoothed[i])
+		}
+	}
+}
+
+func TestGaussianSmoothedByFrames(t *testing.T) {
+	// 連続したフレームでは、sigma の3倍までの範囲の重みで平滑化する
+	spike := []float64{0, 0, 0, 0, 10, 0, 0, 0, 0}
+	frames := make([]float64, len(spike))
+	for i := range frames {
+		frames[i] = float64(i)
+	}
+	expected := []float64{0, 0.0470818825, 0.5424605801, 2.4203622938, 3.9905027965,
+		2.4203622938, 0.5424605801, 0.0470818825, 0}
+	smoothed := GaussianSmoothedByFrames(frames, spike, 1.0)
+	for i := range spike {
+		if math.Abs(smoothed[i]-expected[i]) > 1e-8 {
+			t.Errorf("Expected %v at %d, got %v", expected[i], i, smoothed[i])
+		}
+	}
+
+	// 欠けたフレームがある場合は、フレーム差に応じた重みになる
+	smoothed = GaussianSmoothedByFrames([]float64{0, 2, 3}, []float64{0, 10, 0}, 1.0)
+	if expected := 10 * math.Exp(-2) / (1 + math.Exp(-2) + math.Exp(-4.5)); math.Abs(smoothed[0]-expected) > 1e-8 {
+		t.Errorf("Expected %v at 0, got %v", expected, smoothed[0])
+	}
+
+	// 離れたフレームの値は混ざらない
+	values := []float64{0, 0, 10, 10}
+	smoothed = GaussianSmoothedByFrames([]float64{0, 1, 100, 101}, values, 1.0)
+	if smoothed[1] > 1e-8 || math.Abs(smoothed[2]-10) > 1e-8 {
+		t.Errorf("Expected distant frames not to be mixed, got %v", smoothed)
+	}
+}
@@ -168,6 +168,15 @@ func (quat *MQuaternion) ToMMDDegrees() *MVec3 {
 	}
 }
 
+// NewMQuaternionFromMMDDegreesは、MMD表示のオイラー角（度）から四元数を返します。
+// ToMMDDegrees の逆変換（YXZ順）です
+func NewMQuaternionFromMMDDegrees(xPitch, yHead, zRoll float64) *MQuaternion {
+	qy := NewMQuaternionFromAxisAnglesRotate(MVec3UnitY, DegToRad(-yHead))
+	qx := NewMQuaternionFromAxisAnglesRotate(MVec3UnitX, DegToRad(xPitch))
+	qz := NewMQuaternionFromAxisAnglesRotate(MVec3UnitZ, DegToRad(-zRoll))
+	return qy.Muled(qx).Muled(qz)
+}
+
 // Vec4は四元数をvec4.Tに変換する
 func (quat *MQuaternion) Vec4() *MVec4 {
 	return &MVec4{quat.X, quat.Y, quat.Z, quat.W}
@@ -649,6 +658,50 @@ func (quat *MQuaternion) SeparateTwistByAxis(globalAxis *MVec3) (twistQQ *MQuate
 	return twistQQ, yzQQ
 }
 
+// SignedTwistRadianは、捩り軸回りの符号付き回転角度(-π～π)を返します。
+func (quat *MQuaternion) SignedTwistRadian(twistAxis *MVec3) float64 {
+	axis, angle := quat.ToAxisAngle()
+	if axis.Dot(twistAxis) < 0 {
+		angle = -angle
+	}
+	if angle > math.Pi {
+		angle -= 2 * math.Pi
+	} else if angle < -math.Pi {
+		angle += 2 * math.Pi
+	}
+	return angle
+}
+
+// ClampedSwingTwistは、クォータニオンをスイング(捩り軸以外)と捩りに分解し、それぞれを範囲内に収めます。
+// 角度はラジアン。範囲外で調整した場合は true を返します。
+func (quat *MQuaternion) ClampedSwingTwist(twistAxis *MVec3, swingMax, twistMin, twistMax float64) (*MQuaternion, bool) {
+	axis := twistAxis.Normalized()
+	twistQQ, swingQQ := quat.SeparateTwistByAxis(axis)
+
+	// 同じ回転で角度が小さくなる向きに揃える
+	if swingQQ.W < 0 {
+		swingQQ = swingQQ.Negated()
+	}
+	swingAngle := swingQQ.ToRadian()
+	twistAngle := twistQQ.SignedTwistRadian(axis)
+
+	isClamped := false
+	if swingAngle > swingMax {
+		swingQQ = NewMQuaternion().Slerp(swingQQ, swingMax/swingAngle)
+		isClamped = true
+	}
+	if twistAngle < twistMin || twistAngle > twistMax {
+		twistAngle = Clamped(twistAngle, twistMin, twistMax)
+		isClamped = true
+	}
+
+	if !isClamped {
+		return quat.Copy(), false
+	}
+
+	return swingQQ.Muled(NewMQuaternionFromAxisAnglesRotate(axis, twistAngle)).Normalize(), true
+}
+
 // SeparateByAxisは、グローバル軸に基づいてクォータニオンを3つのクォータニオン(x, y, z)に分割します。
 // x: 捩れ成分, y: Z成分, z: X成分
 // MMDの合成順は「YXZ」
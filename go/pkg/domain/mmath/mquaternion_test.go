@@ -261,6 +261,15 @@ func TestMQuaternionToEulerAnglesDegrees(t *testing.T) {
 	}
 }
 
+func TestNewMQuaternionFromMMDDegrees(t *testing.T) {
+	for _, degrees := range []*MVec3{{0, 0, 0}, {10, 20, 30}, {-45, 60, -10}, {80, -30, 120}} {
+		result := NewMQuaternionFromMMDDegrees(degrees.X, degrees.Y, degrees.Z).ToMMDDegrees()
+		if !result.NearEquals(degrees, 1e-6) {
+			t.Errorf("NewMQuaternionFromMMDDegrees failed. Expected %v, got %v", degrees, result)
+		}
+	}
+}
+
 func TestMQuaternionMultiply(t *testing.T) {
 	expected1 := NewMQuaternionByValues(
 		0.6594130183457979, 0.11939693791117263, -0.24571599091322077, 0.7003873887093154)
@@ -569,3 +578,37 @@ func TestFindSlerpT(t *testing.T) {
 		}
 	}
 }
+
+func TestMQuaternion_ClampedSwingTwist(t *testing.T) {
+	axis := &MVec3{X: 0, Y: -1, Z: 0}
+
+	// 範囲内はそのまま
+	quat := NewMQuaternionFromAxisAnglesRotate(&MVec3{X: 1, Y: 0, Z: 0}, DegToRad(-30))
+	clamped, isClamped := quat.ClampedSwingTwist(axis, DegToRad(45), DegToRad(-10), DegToRad(10))
+	if isClamped || !clamped.NearEquals(quat, 1e-8) {
+		t.Errorf("Expected %v not to be clamped, got %v", quat, clamped)
+	}
+
+	// スイングが最大角度に収まる
+	quat = NewMQuaternionFromAxisAnglesRotate(&MVec3{X: 1, Y: 0, Z: 0}, DegToRad(-90))
+	clamped, isClamped = quat.ClampedSwingTwist(axis, DegToRad(45), DegToRad(-10), DegToRad(10))
+	expected := NewMQuaternionFromAxisAnglesRotate(&MVec3{X: 1, Y: 0, Z: 0}, DegToRad(-45))
+	if !isClamped || !clamped.NearEquals(expected, 1e-8) {
+		t.Errorf("Expected swing %v, got %v", expected, clamped)
+	}
+
+	// 捩りが範囲内に収まる(スイングは維持)
+	swing := NewMQuaternionFromAxisAnglesRotate(&MVec3{X: 0, Y: 0, Z: 1}, DegToRad(20))
+	twist := NewMQuaternionFromAxisAnglesRotate(axis, DegToRad(170))
+	quat = swing.Muled(twist)
+	clamped, isClamped = quat.ClampedSwingTwist(axis, DegToRad(45), DegToRad(-10), DegToRad(10))
+	expected = swing.Muled(NewMQuaternionFromAxisAnglesRotate(axis, DegToRad(10)))
+	if !isClamped || !clamped.NearEquals(expected, 1e-8) {
+		t.Errorf("Expected twist %v, got %v", expected, clamped)
+	}
+
+	twistAngle := NewMQuaternionFromAxisAnglesRotate(axis, DegToRad(-120)).SignedTwistRadian(axis)
+	if math.Abs(twistAngle-DegToRad(-120)) > 1e-8 {
+		t.Errorf("Expected twist angle %v, got %v", DegToRad(-120), twistAngle)
+	}
+}
@@ -0,0 +1,157 @@
+package pmx
+
+import (
+	"slices"
+)
+
+// MisparentedBone 親ボーンが準標準の構成と異なるボーン
+type MisparentedBone struct {
+	Name                string   // ボーン名
+	ParentName          string   // 実際の親ボーン名
+	ExpectedParentNames []string // 親ボーンとして想定しているボーン名
+}
+
+// BoneCheckResult 準標準ボーン構成との比較結果
+type BoneCheckResult struct {
+	MissingBones     []string           // モデルに無い準標準ボーン
+	ExtraBones       []string           // 準標準ボーン以外のボーン
+	MisparentedBones []*MisparentedBone // 親ボーンが異なるボーン
+	MissingIkChains  []string           // IKが無い・IKの構成が異なるIKボーン
+}
+
+// ikChainConfig IKボーンのターゲットとリンク
+type ikChainConfig struct {
+	Name   StandardBoneName
+	Target StandardBoneName
+	Links  []StandardBoneName
+}
+
+var ikChainConfigs = []*ikChainConfig{
+	{Name: LEG_IK, Target: ANKLE, Links: []StandardBoneName{KNEE, LEG}},
+	{Name: TOE_IK, Target: TOE, Links: []StandardBoneName{ANKLE}},
+}
+
+// CheckStandardBones モデルのボーン構成を準標準ボーン構成と比較する
+func CheckStandardBones(model *PmxModel) *BoneCheckResult {
+	result := &BoneCheckResult{
+		MissingBones:     make([]string, 0),
+		ExtraBones:       make([]string, 0),
+		MisparentedBones: make([]*MisparentedBone, 0),
+		MissingIkChains:  make([]string, 0),
+	}
+
+	for _, name := range retargetStandardNames() {
+		config, ok := GetStandardBoneConfigs()[name]
+		if !ok || !config.IsStandard {
+			continue
+		}
+		for _, direction := range name.Directions() {
+			if boneName := name.StringFromDirection(direction); !model.Bones.ContainsByName(boneName) {
+				result.MissingBones = append(result.MissingBones, boneName)
+			}
+		}
+	}
+
+	model.Bones.ForEach(func(index int, bone *Bone) bool {
+		config := BoneConfigFromName(bone.Name())
+		if config == nil {
+			if !isRetargetExtraBone(bone.Name()) {
+				result.ExtraBones = append(result.ExtraBones, bone.Name())
+			}
+			return true
+		}
+
+		if misparented := checkParent(model, bone, config); misparented != nil {
+			result.MisparentedBones = append(result.MisparentedBones, misparented)
+		}
+		return true
+	})
+
+	for _, ikConfig := range ikChainConfigs {
+		for _, direction := range ikConfig.Name.Directions() {
+			ikName := ikConfig.Name.StringFromDirection(direction)
+			if !isValidIkChain(model, ikConfig, direction) {
+				result.MissingIkChains = append(result.MissingIkChains, ikName)
+			}
+		}
+	}
+
+	return result
+}
+
+// checkParent 想定している親ボーンのいずれかが、祖先ボーンに含まれているか
+// (肩C や 髪・スカート等の中間ボーンを挟んでいても問題としない)
+func checkParent(model *PmxModel, bone *Bone, config *BoneConfig) *MisparentedBone {
+	expectedParentNames := make([]string, 0)
+	for _, parentName := range config.ParentBoneNames {
+		name := parentName.StringFromDirection(bone.Direction())
+		if model.Bones.ContainsByName(name) {
+			expectedParentNames = append(expectedParentNames, name)
+		}
+	}
+	if len(expectedParentNames) == 0 {
+		return nil
+	}
+
+	// 親子が循環していても止まるように、ボーン数までしか遡らない
+	parent, err := model.Bones.Get(bone.ParentIndex)
+	for range model.Bones.Length() {
+		if err != nil {
+			break
+		}
+		if slices.Contains(expectedParentNames, parent.Name()) {
+			return nil
+		}
+		parent, err = model.Bones.Get(parent.ParentIndex)
+	}
+
+	parentName := ""
+	if parent, err := model.Bones.Get(bone.ParentIndex); err == nil {
+		parentName = parent.Name()
+	}
+
+	return &MisparentedBone{
+		Name:                bone.Name(),
+		ParentName:          parentName,
+		ExpectedParentNames: expectedParentNames,
+	}
+}
+
+// isValidIkChain IKボーンがあり、ターゲットとリンクが想定通りであるか
+func isValidIkChain(model *PmxModel, ikConfig *ikChainConfig, direction BoneDirection) bool {
+	ikBone, err := model.Bones.GetByName(ikConfig.Name.StringFromDirection(direction))
+	if err != nil || !ikBone.IsIK() || ikBone.Ik == nil {
+		return false
+	}
+
+	targetBone, err := model.Bones.GetByName(ikConfig.Target.StringFromDirection(direction))
+	if err != nil || ikBone.Ik.BoneIndex != targetBone.Index() {
+		return false
+	}
+
+	linkIndexes := make([]int, len(ikBone.Ik.Links))
+	for i, link := range ikBone.Ik.Links {
+		linkIndexes[i] = link.BoneIndex
+	}
+
+	for _, linkName := range ikConfig.Links {
+		linkBone, err := model.Bones.GetByName(linkName.StringFromDirection(direction))
+		if err != nil || !slices.Contains(linkIndexes, linkBone.Index()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isRetargetExtraBone 変換処理が参照する準標準以外のボーンか
+func isRetargetExtraBone(name string) bool {
+	for _, extraName := range retargetExtraBoneNames {
+		for _, direction := range extraName.Directions() {
+			if extraName.StringFromDirection(direction) == name {
+				return true
+			}
+		}
+	}
+	return false
+}
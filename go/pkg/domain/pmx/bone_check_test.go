@@ -0,0 +1,75 @@
+package pmx
+
+import (
+	"slices"
+	"testing"
+)
+
+func newCheckTestModel() *PmxModel {
+	model := NewPmxModel("")
+
+	for _, names := range [][]string{
+		{"全ての親", ""},
+		{"センター", "全ての親"},
+		{"上半身", "センター"},
+		{"髪", "上半身"},
+		{"首", "髪"},
+		{"下半身", "センター"},
+		{"左足", "全ての親"},
+		{"左ひざ", "左足"},
+		{"左足首", "左ひざ"},
+		{"左足ＩＫ", "全ての親"},
+	} {
+		bone := NewBoneByName(names[0])
+		bone.ParentIndex = -1
+		if parent, err := model.Bones.GetByName(names[1]); err == nil {
+			bone.ParentIndex = parent.Index()
+		}
+		model.Bones.Append(bone)
+	}
+
+	ikBone, _ := model.Bones.GetByName("左足ＩＫ")
+	ikBone.BoneFlag |= BONE_FLAG_IS_IK
+	ikBone.Ik = NewIk()
+	ankle, _ := model.Bones.GetByName("左足首")
+	ikBone.Ik.BoneIndex = ankle.Index()
+	knee, _ := model.Bones.GetByName("左ひざ")
+	link := NewIkLink()
+	link.BoneIndex = knee.Index()
+	ikBone.Ik.Links = append(ikBone.Ik.Links, link)
+
+	model.Bones.Setup()
+
+	return model
+}
+
+func TestCheckStandardBones(t *testing.T) {
+	result := CheckStandardBones(newCheckTestModel())
+
+	for _, name := range []string{"上半身2", "頭", "右足"} {
+		if !slices.Contains(result.MissingBones, name) {
+			t.Errorf("Expected %s to be missing, got %v", name, result.MissingBones)
+		}
+	}
+	if slices.Contains(result.MissingBones, "上半身") {
+		t.Errorf("Expected 上半身 not to be missing, got %v", result.MissingBones)
+	}
+
+	if !slices.Equal(result.ExtraBones, []string{"髪"}) {
+		t.Errorf("Expected extra bones to be [髪], got %v", result.ExtraBones)
+	}
+
+	// 首は髪を挟んで上半身の子なので問題なし、左足は下半身ではなく全ての親の子
+	misparentedNames := make([]string, 0)
+	for _, bone := range result.MisparentedBones {
+		misparentedNames = append(misparentedNames, bone.Name)
+	}
+	if !slices.Equal(misparentedNames, []string{"左足"}) {
+		t.Errorf("Expected misparented bones to be [左足], got %v", misparentedNames)
+	}
+
+	// 左足ＩＫ は 左足 がリンクに無い
+	if !slices.Contains(result.MissingIkChains, "左足ＩＫ") || !slices.Contains(result.MissingIkChains, "右足ＩＫ") {
+		t.Errorf("Expected 左足ＩＫ and 右足ＩＫ to be missing, got %v", result.MissingIkChains)
+	}
+}
@@ -70,6 +70,18 @@ func (d BoneDirection) Sign() float64 {
 	return 0.0
 }
 
+// Opposite 左右反対の方向(体幹は体幹のまま)
+func (d BoneDirection) Opposite() BoneDirection {
+	switch d {
+	case BONE_DIRECTION_LEFT:
+		return BONE_DIRECTION_RIGHT
+	case BONE_DIRECTION_RIGHT:
+		return BONE_DIRECTION_LEFT
+	}
+
+	return d
+}
+
 type BoneCategory int
 
 const (
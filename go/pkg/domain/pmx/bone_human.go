@@ -3,6 +3,7 @@ package pmx
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/merr"
@@ -764,6 +765,12 @@ func (bones *Bones) CreateWristTail(direction BoneDirection) (*Bone, error) {
 
 	// 親ボーン
 	bone.ParentIndex = bones.findParentIndexByConfig(WRIST_TAIL, direction)
+	if bone.ParentIndex < 0 {
+		return nil, merr.NewParentNotFoundError(
+			WRIST_TAIL.StringFromDirection(direction),
+			fmt.Sprintf("parent bone not found: %s", []string{WRIST.StringFromDirection(direction)}),
+		)
+	}
 
 	return bone, nil
 }
@@ -1677,35 +1684,21 @@ func (bones *Bones) findParentIndexByConfig(boneName StandardBoneName, direction
 func (bones *Bones) InsertShortageOverrideBones() error {
 
 	// 体幹系
-	for _, funcs := range [][]func() (*Bone, error){
-		{bones.GetTrunkRoot, bones.CreateTrunkRoot},
-		{bones.GetLegCenter, bones.CreateLegCenter},
-		{bones.GetNeckRoot, bones.CreateNeckRoot},
+	for _, funcs := range []struct {
+		get    func() (*Bone, error)
+		create func() (*Bone, error)
+		isTail bool // 末端ボーンか(子ボーンを付け替えない)
+	}{
+		{bones.GetGroove, bones.CreateGroove, false},
+		{bones.GetTrunkRoot, bones.CreateTrunkRoot, false},
+		{bones.GetLegCenter, bones.CreateLegCenter, false},
+		{bones.GetUpper2, bones.CreateUpper2, false},
+		{bones.GetNeckRoot, bones.CreateNeckRoot, false},
+		{bones.GetUpper3, bones.CreateUpper3, false},
+		{bones.GetLowerTail, bones.CreateLowerTail, true},
 	} {
-		getFunc := funcs[0]
-		createFunc := funcs[1]
-
-		if bone, err := getFunc(); err != nil && merr.IsNameNotFoundError(err) && bone == nil {
-			if bone, err := createFunc(); err == nil && bone != nil {
-				if err := bones.Insert(bone); err != nil {
-					return err
-				} else {
-					// 追加したボーンの親ボーンを、同じく親ボーンに設定しているボーンの親ボーンを追加ボーンに置き換える
-					bones.ForEach(func(i int, b *Bone) bool {
-						if b.ParentIndex == bone.ParentIndex && b.Index() != bone.Index() &&
-							b.EffectIndex != bone.Index() && bone.EffectIndex != b.Index() &&
-							((strings.Contains(bone.Name(), "上") && !strings.Contains(b.Name(), "下") &&
-								!strings.Contains(b.Name(), "左") && !strings.Contains(b.Name(), "右")) ||
-								(strings.Contains(bone.Name(), "下") && !strings.Contains(b.Name(), "上") &&
-									!strings.Contains(b.Name(), "左") && !strings.Contains(b.Name(), "右"))) {
-							b.ParentIndex = bone.Index()
-						}
-						return true
-					})
-					// セットアップしなおし
-					bones.Setup()
-				}
-			} else {
+		if bone, err := funcs.get(); err != nil && merr.IsNameNotFoundError(err) && bone == nil {
+			if err := bones.insertShortageBone(funcs.create, funcs.isTail); err != nil {
 				return err
 			}
 		} else if err != nil {
@@ -1726,37 +1719,22 @@ func (bones *Bones) InsertShortageOverrideBones() error {
 // InsertSystemTailBones システム用不足ボーン作成
 func (bones *Bones) InsertSystemTailBones() error {
 
-	// 体幹系
+	// 末端系
 	for _, funcs := range [][]func(direction BoneDirection) (*Bone, error){
 		{bones.GetToeT, bones.CreateToeT},
+		{bones.GetToeP, bones.CreateToeP},
+		{bones.GetToeC, bones.CreateToeC},
+		{bones.GetToe, bones.CreateToe},
 		{bones.GetHeel, bones.CreateHeel},
 		{bones.GetWristTail, bones.CreateWristTail},
+		{bones.GetIndexTip, bones.CreateIndexTip},
 	} {
 		getFunc := funcs[0]
 		createFunc := funcs[1]
 
 		for _, direction := range []BoneDirection{BONE_DIRECTION_LEFT, BONE_DIRECTION_RIGHT} {
 			if bone, err := getFunc(direction); err != nil && merr.IsNameNotFoundError(err) && bone == nil {
-				if bone, err := createFunc(direction); err == nil && bone != nil {
-					if err := bones.Insert(bone); err != nil {
-						return err
-					} else {
-						// 追加したボーンの親ボーンを、同じく親ボーンに設定しているボーンの親ボーンを追加ボーンに置き換える
-						bones.ForEach(func(i int, b *Bone) bool {
-							if b.ParentIndex == bone.ParentIndex && b.Index() != bone.Index() &&
-								b.EffectIndex != bone.Index() && bone.EffectIndex != b.Index() &&
-								((strings.Contains(bone.Name(), "上") && !strings.Contains(b.Name(), "下") &&
-									!strings.Contains(b.Name(), "左") && !strings.Contains(b.Name(), "右")) ||
-									(strings.Contains(bone.Name(), "下") && !strings.Contains(b.Name(), "上") &&
-										!strings.Contains(b.Name(), "左") && !strings.Contains(b.Name(), "右"))) {
-								b.ParentIndex = bone.Index()
-							}
-							return true
-						})
-						// セットアップしなおし
-						bones.Setup()
-					}
-				} else {
+				if err := bones.insertShortageBone(func() (*Bone, error) { return createFunc(direction) }, true); err != nil {
 					return err
 				}
 			} else if err != nil {
@@ -1767,3 +1745,51 @@ func (bones *Bones) InsertSystemTailBones() error {
 
 	return nil
 }
+
+// insertShortageBone 不足ボーンを作成して追加する
+// 親ボーンが無く作成できない場合は追加しない
+func (bones *Bones) insertShortageBone(createFunc func() (*Bone, error), isTail bool) error {
+	bone, err := createFunc()
+	if err != nil {
+		if merr.IsParentNotFoundError(err) {
+			return nil
+		}
+		return err
+	} else if bone == nil {
+		return nil
+	}
+
+	if err := bones.Insert(bone); err != nil {
+		return err
+	}
+
+	if !isTail {
+		// 追加したボーンの親ボーンを、同じく親ボーンに設定しているボーンの親ボーンを追加ボーンに置き換える
+		bones.ForEach(func(i int, b *Bone) bool {
+			if b.ParentIndex == bone.ParentIndex && b.Index() != bone.Index() && !b.IsIK() &&
+				b.EffectIndex != bone.Index() && bone.EffectIndex != b.Index() &&
+				(bones.isShortageChildByName(bone, b) || bones.isShortageChildByConfig(bone, b)) {
+				b.ParentIndex = bone.Index()
+			}
+			return true
+		})
+	}
+
+	// セットアップしなおし
+	bones.Setup()
+
+	return nil
+}
+
+// isShortageChildByName 名前から、追加したボーンの子ボーンとするか判定する
+func (bones *Bones) isShortageChildByName(bone, b *Bone) bool {
+	return (strings.Contains(bone.Name(), "上") && !strings.Contains(b.Name(), "下") &&
+		!strings.Contains(b.Name(), "左") && !strings.Contains(b.Name(), "右")) ||
+		(strings.Contains(bone.Name(), "下") && !strings.Contains(b.Name(), "上") &&
+			!strings.Contains(b.Name(), "左") && !strings.Contains(b.Name(), "右"))
+}
+
+// isShortageChildByConfig ボーン設定の親ボーン候補に、追加したボーンが含まれているか判定する
+func (bones *Bones) isShortageChildByConfig(bone, b *Bone) bool {
+	return slices.Contains(b.ConfigParentBoneNames(), bone.Name())
+}
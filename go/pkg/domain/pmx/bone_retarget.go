@@ -0,0 +1,339 @@
+package pmx
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/width"
+)
+
+// retargetExtraBoneNames 準標準ボーン以外で、変換処理が参照するボーン名
+var retargetExtraBoneNames = []StandardBoneName{
+	LOWER_TAIL,
+	UPPER3,
+	INDEX_TIP,
+	TOE,
+}
+
+// boneAliases 標準ボーン名の別名
+// {d}: 左/右, {e}: L/R, {E}: Left/Right (英語名・VRoid・Unity Humanoid 等)
+var boneAliases = map[StandardBoneName][]string{
+	ROOT:        {"master", "all parent", "root"},
+	CENTER:      {"center", "centre"},
+	GROOVE:      {"groove", "グルーヴ"},
+	WAIST:       {"waist"},
+	LOWER:       {"lower body", "J_Bip_C_Hips", "Hips"},
+	UPPER:       {"upper body", "J_Bip_C_Spine", "Spine"},
+	UPPER2:      {"upper body2", "J_Bip_C_Chest", "Chest"},
+	UPPER3:      {"upper body3", "J_Bip_C_UpperChest", "UpperChest"},
+	NECK:        {"neck", "J_Bip_C_Neck"},
+	HEAD:        {"head", "J_Bip_C_Head"},
+	EYES:        {"eyes"},
+	EYE:         {"eye_{e}", "J_Adj_{e}_FaceEye", "{E}Eye"},
+	SHOULDER_P:  {"shoulderP_{e}"},
+	SHOULDER:    {"shoulder_{e}", "J_Bip_{e}_Shoulder", "{E}Shoulder"},
+	ARM:         {"arm_{e}", "J_Bip_{e}_UpperArm", "{E}UpperArm"},
+	ARM_TWIST:   {"arm twist_{e}", "arm_twist_{e}", "{d}腕捩れ"},
+	ELBOW:       {"elbow_{e}", "J_Bip_{e}_LowerArm", "{E}LowerArm"},
+	WRIST_TWIST: {"wrist twist_{e}", "wrist_twist_{e}", "{d}手捩れ"},
+	WRIST:       {"wrist_{e}", "J_Bip_{e}_Hand", "{E}Hand"},
+	THUMB0:      {"thumb0_{e}", "J_Bip_{e}_Thumb1", "{E}ThumbProximal"},
+	THUMB1:      {"thumb1_{e}", "J_Bip_{e}_Thumb2", "{E}ThumbIntermediate"},
+	THUMB2:      {"thumb2_{e}", "J_Bip_{e}_Thumb3", "{E}ThumbDistal"},
+	INDEX1:      {"fore1_{e}", "index1_{e}", "J_Bip_{e}_Index1", "{E}IndexProximal"},
+	INDEX2:      {"fore2_{e}", "index2_{e}", "J_Bip_{e}_Index2", "{E}IndexIntermediate"},
+	INDEX3:      {"fore3_{e}", "index3_{e}", "J_Bip_{e}_Index3", "{E}IndexDistal"},
+	MIDDLE1:     {"middle1_{e}", "J_Bip_{e}_Middle1", "{E}MiddleProximal"},
+	MIDDLE2:     {"middle2_{e}", "J_Bip_{e}_Middle2", "{E}MiddleIntermediate"},
+	MIDDLE3:     {"middle3_{e}", "J_Bip_{e}_Middle3", "{E}MiddleDistal"},
+	RING1:       {"third1_{e}", "ring1_{e}", "J_Bip_{e}_Ring1", "{E}RingProximal"},
+	RING2:       {"third2_{e}", "ring2_{e}", "J_Bip_{e}_Ring2", "{E}RingIntermediate"},
+	RING3:       {"third3_{e}", "ring3_{e}", "J_Bip_{e}_Ring3", "{E}RingDistal"},
+	PINKY1:      {"little1_{e}", "pinky1_{e}", "J_Bip_{e}_Little1", "{E}LittleProximal"},
+	PINKY2:      {"little2_{e}", "pinky2_{e}", "J_Bip_{e}_Little2", "{E}LittleIntermediate"},
+	PINKY3:      {"little3_{e}", "pinky3_{e}", "J_Bip_{e}_Little3", "{E}LittleDistal"},
+	LEG:         {"leg_{e}", "J_Bip_{e}_UpperLeg", "{E}UpperLeg"},
+	KNEE:        {"knee_{e}", "J_Bip_{e}_LowerLeg", "{E}LowerLeg"},
+	ANKLE:       {"ankle_{e}", "J_Bip_{e}_Foot", "{E}Foot"},
+	TOE_EX:      {"toe_ex_{e}", "leg_ex_{e}", "J_Bip_{e}_ToeBase", "{E}Toes"},
+	TOE:         {"toe_{e}"},
+	LEG_D:       {"leg_{e}_D", "legD_{e}"},
+	KNEE_D:      {"knee_{e}_D", "kneeD_{e}"},
+	ANKLE_D:     {"ankle_{e}_D", "ankleD_{e}"},
+	LEG_IK:      {"leg IK_{e}", "leg_IK_{e}", "{d}足IK"},
+	TOE_IK:      {"toe IK_{e}", "toe_IK_{e}", "{d}つま先IK"},
+}
+
+// BoneRetarget 標準ボーン名と任意モデルのボーン名の対応
+type BoneRetarget struct {
+	standardToModel map[string]string
+	modelToStandard map[string]string
+}
+
+// NewBoneRetarget モデルのボーンを標準ボーン名に対応付ける
+// 優先順は 上書き指定 > 日本語名 > 表記揺れ(全角半角等) > 英語名・別名 > 階層からの推定
+func NewBoneRetarget(model *PmxModel, overrides map[string]string) *BoneRetarget {
+	retarget := &BoneRetarget{
+		standardToModel: make(map[string]string),
+		modelToStandard: make(map[string]string),
+	}
+
+	for standardName, modelName := range overrides {
+		if model.Bones.ContainsByName(modelName) {
+			retarget.set(standardName, modelName)
+		}
+	}
+
+	// 正規化した名前(日本語名・英語名) -> ボーン名。英語名より日本語名を優先する
+	normalizedNames := make(map[string]string)
+	for _, nameFunc := range []func(bone *Bone) string{(*Bone).Name, (*Bone).EnglishName} {
+		model.Bones.ForEach(func(index int, bone *Bone) bool {
+			key := normalizeBoneName(nameFunc(bone))
+			if _, ok := normalizedNames[key]; key != "" && !ok {
+				normalizedNames[key] = bone.Name()
+			}
+			return true
+		})
+	}
+
+	standardNames := retargetStandardNames()
+
+	for _, name := range standardNames {
+		for _, direction := range name.Directions() {
+			standardName := name.StringFromDirection(direction)
+			if model.Bones.ContainsByName(standardName) {
+				retarget.set(standardName, standardName)
+			}
+		}
+	}
+
+	for _, name := range standardNames {
+		for _, direction := range name.Directions() {
+			candidates := []string{name.StringFromDirection(direction)}
+			for _, alias := range boneAliases[name] {
+				candidates = append(candidates, expandBoneAlias(alias, direction))
+			}
+
+			for _, candidate := range candidates {
+				// 対応済みのボーンに当たった場合は、次の候補を探す
+				if modelName, ok := normalizedNames[normalizeBoneName(candidate)]; ok &&
+					retarget.set(name.StringFromDirection(direction), modelName) {
+					break
+				}
+			}
+		}
+	}
+
+	// 親子が分かっているボーンの間にあるボーンを推定する
+	for range 3 {
+		if !retarget.resolveByHierarchy(model) {
+			break
+		}
+	}
+
+	return retarget
+}
+
+// set 未対応の標準ボーン名・モデルのボーン名同士の場合のみ対応付ける(対応付けたかを返す)
+func (retarget *BoneRetarget) set(standardName, modelName string) bool {
+	if _, ok := retarget.standardToModel[standardName]; ok {
+		return false
+	}
+	if _, ok := retarget.modelToStandard[modelName]; ok {
+		return false
+	}
+	retarget.standardToModel[standardName] = modelName
+	retarget.modelToStandard[modelName] = standardName
+	return true
+}
+
+// resolveByHierarchy 親ボーンと末端ボーンが対応済みで、その間に1本だけ未対応ボーンがある場合に対応付ける
+func (retarget *BoneRetarget) resolveByHierarchy(model *PmxModel) bool {
+	isResolved := false
+
+	for _, name := range retargetStandardNames() {
+		config, ok := GetStandardBoneConfigs()[name]
+		if !ok {
+			continue
+		}
+
+		for _, direction := range name.Directions() {
+			standardName := name.StringFromDirection(direction)
+			if _, ok := retarget.standardToModel[standardName]; ok {
+				continue
+			}
+
+			parentBone := retarget.firstBone(model, [][]StandardBoneName{config.ParentBoneNames}, direction)
+			childBone := retarget.firstBone(model, config.ChildBoneNames, direction)
+			if parentBone == nil || childBone == nil {
+				continue
+			}
+
+			// 末端から親まで遡り、間にあるボーンを集める
+			betweenBones := make([]*Bone, 0)
+			bone := childBone
+			for bone != nil && bone.ParentIndex >= 0 && bone.ParentIndex != parentBone.Index() {
+				bone, _ = model.Bones.Get(bone.ParentIndex)
+				betweenBones = append(betweenBones, bone)
+			}
+			if bone == nil || bone.ParentIndex != parentBone.Index() || len(betweenBones) != 1 {
+				continue
+			}
+			candidate := betweenBones[0]
+			if _, ok := retarget.modelToStandard[candidate.Name()]; ok {
+				continue
+			}
+
+			retarget.set(standardName, candidate.Name())
+			isResolved = true
+		}
+	}
+
+	return isResolved
+}
+
+// firstBone 候補の中で最初に対応済みのモデルのボーン
+func (retarget *BoneRetarget) firstBone(model *PmxModel, candidates [][]StandardBoneName, direction BoneDirection) *Bone {
+	for _, names := range candidates {
+		for _, name := range names {
+			if modelName, ok := retarget.standardToModel[name.StringFromDirection(direction)]; ok {
+				if bone, err := model.Bones.GetByName(modelName); err == nil {
+					return bone
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ModelName 標準ボーン名に対応するモデルのボーン名
+func (retarget *BoneRetarget) ModelName(standardName string) (string, bool) {
+	modelName, ok := retarget.standardToModel[standardName]
+	return modelName, ok
+}
+
+// StandardName モデルのボーン名に対応する標準ボーン名
+func (retarget *BoneRetarget) StandardName(modelName string) (string, bool) {
+	standardName, ok := retarget.modelToStandard[modelName]
+	return standardName, ok
+}
+
+// Names 標準ボーン名 -> モデルのボーン名
+func (retarget *BoneRetarget) Names() map[string]string {
+	names := make(map[string]string, len(retarget.standardToModel))
+	for standardName, modelName := range retarget.standardToModel {
+		names[standardName] = modelName
+	}
+	return names
+}
+
+// RetargetedModel ボーン名を標準ボーン名に置き換えたモデルを返す
+func (retarget *BoneRetarget) RetargetedModel(model *PmxModel) (*PmxModel, error) {
+	return renamedModel(model, retarget.modelToStandard)
+}
+
+// RestoredModel 標準ボーン名をモデルのボーン名に戻したモデルを返す(追加したボーンは標準ボーン名のまま)
+func (retarget *BoneRetarget) RestoredModel(model *PmxModel) (*PmxModel, error) {
+	return renamedModel(model, retarget.standardToModel)
+}
+
+// renamedModel ボーン名を置き換えたモデルを返す
+// ボーンのみをコピーし、頂点・材質等は元のモデルと共有する(剛体等は循環参照があり deepcopy できない)
+// ボーンは末尾に追加されるため、共有している頂点のウェイト等のボーンINDEXはずれない
+// 置き換えた名前が他のボーン名と重なる場合(上書き指定で、同じ名前のボーンが別にある場合等)はエラー
+func renamedModel(model *PmxModel, names map[string]string) (*PmxModel, error) {
+	renamedBoneNames := make(map[string]string, model.Bones.Length())
+	var err error
+	model.Bones.ForEach(func(index int, bone *Bone) bool {
+		name := bone.Name()
+		if renamedName, ok := names[name]; ok {
+			name = renamedName
+		}
+		if originalName, ok := renamedBoneNames[name]; ok {
+			err = fmt.Errorf("bone name %s is duplicated after renaming (%s, %s)", name, originalName, bone.Name())
+			return false
+		}
+		renamedBoneNames[name] = bone.Name()
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	copied := NewPmxModel(model.Path())
+	copied.SetName(model.Name())
+	copied.SetEnglishName(model.EnglishName())
+	copied.Signature = model.Signature
+	copied.Version = model.Version
+	copied.ExtendedUVCount = model.ExtendedUVCount
+	copied.VertexCountType = model.VertexCountType
+	copied.TextureCountType = model.TextureCountType
+	copied.MaterialCountType = model.MaterialCountType
+	copied.BoneCountType = model.BoneCountType
+	copied.MorphCountType = model.MorphCountType
+	copied.RigidBodyCountType = model.RigidBodyCountType
+	copied.Comment = model.Comment
+	copied.EnglishComment = model.EnglishComment
+	copied.Vertices = model.Vertices
+	copied.Faces = model.Faces
+	copied.Textures = model.Textures
+	copied.Materials = model.Materials
+	copied.Morphs = model.Morphs
+	copied.DisplaySlots = model.DisplaySlots
+	copied.RigidBodies = model.RigidBodies
+	copied.Joints = model.Joints
+
+	model.Bones.ForEach(func(index int, bone *Bone) bool {
+		copiedBone := bone.Copy().(*Bone)
+		if name, ok := names[bone.Name()]; ok {
+			copiedBone.SetName(name)
+		}
+		if err = copied.Bones.Append(copiedBone); err != nil {
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	copied.Bones.Setup()
+
+	return copied, nil
+}
+
+// retargetStandardNames 対応付け対象の標準ボーン名
+func retargetStandardNames() []StandardBoneName {
+	names := make([]StandardBoneName, 0, len(GetStandardBoneConfigs())+len(retargetExtraBoneNames))
+	for name := range GetStandardBoneConfigs() {
+		names = append(names, name)
+	}
+	names = append(names, retargetExtraBoneNames...)
+
+	// 対応付けの結果が実行ごとに変わらないように並べる
+	slices.Sort(names)
+	return names
+}
+
+// Directions 左右ボーンの場合は左右、体幹ボーンの場合はそのまま
+func (s StandardBoneName) Directions() []BoneDirection {
+	if strings.Contains(string(s), BONE_DIRECTION_PREFIX) {
+		return []BoneDirection{BONE_DIRECTION_LEFT, BONE_DIRECTION_RIGHT}
+	}
+	return []BoneDirection{BONE_DIRECTION_TRUNK}
+}
+
+// expandBoneAlias 別名の方向表記を展開する
+func expandBoneAlias(alias string, direction BoneDirection) string {
+	e, E := "L", "Left"
+	if direction == BONE_DIRECTION_RIGHT {
+		e, E = "R", "Right"
+	}
+	return strings.NewReplacer(BONE_DIRECTION_PREFIX, string(direction), "{e}", e, "{E}", E).Replace(alias)
+}
+
+// normalizeBoneName 全角半角・大文字小文字・区切り文字の違いを無視した名前
+func normalizeBoneName(name string) string {
+	name = strings.ToLower(width.Narrow.String(name))
+	return strings.NewReplacer(" ", "", "_", "", "-", "", ".", "").Replace(name)
+}
@@ -0,0 +1,114 @@
+package pmx
+
+import (
+	"testing"
+)
+
+func newRetargetTestModel() *PmxModel {
+	model := NewPmxModel("")
+
+	for _, names := range [][]string{
+		{"センター", "center"},
+		{"J_Bip_C_Hips", ""},
+		{"J_Bip_C_Spine", ""},
+		{"Chest2", ""},
+		{"J_Bip_C_Neck", ""},
+		{"上半身２", ""},
+		{"ひざ_L", "knee_L"},
+	} {
+		bone := NewBoneByName(names[0])
+		bone.SetEnglishName(names[1])
+		bone.ParentIndex = model.Bones.Length() - 1
+		model.Bones.Append(bone)
+	}
+	model.Bones.Setup()
+
+	return model
+}
+
+func TestNewBoneRetarget(t *testing.T) {
+	model := newRetargetTestModel()
+	retarget := NewBoneRetarget(model, map[string]string{"左ひざ": "ひざ_L", "頭": "not exists"})
+
+	expected := map[string]string{
+		"センター": "センター",         // 日本語名
+		"上半身2":  "上半身２",         // 全角半角
+		"下半身":   "J_Bip_C_Hips",  // VRoid
+		"上半身":   "J_Bip_C_Spine", // VRoid
+		"首":     "J_Bip_C_Neck",  // VRoid
+		"左ひざ":   "ひざ_L",          // 上書き指定
+	}
+	for standardName, expectedName := range expected {
+		if actual, ok := retarget.ModelName(standardName); !ok || actual != expectedName {
+			t.Errorf("Expected %s to be %s, got %s", standardName, expectedName, actual)
+		}
+	}
+
+	if _, ok := retarget.ModelName("頭"); ok {
+		t.Errorf("Expected 頭 not to be resolved")
+	}
+
+	retargetedModel, err := retarget.RetargetedModel(model)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	if !retargetedModel.Bones.ContainsByName("下半身") || retargetedModel.Bones.ContainsByName("J_Bip_C_Hips") {
+		t.Errorf("Expected bones to be renamed, got %v", retargetedModel.Bones.Names())
+	}
+	if !model.Bones.ContainsByName("J_Bip_C_Hips") {
+		t.Errorf("Expected original model to be kept, got %v", model.Bones.Names())
+	}
+}
+
+func TestNewBoneRetarget_Hierarchy(t *testing.T) {
+	newModel := func(names []string) *PmxModel {
+		model := NewPmxModel("")
+		for i, name := range names {
+			bone := NewBoneByName(name)
+			bone.ParentIndex = i - 1
+			model.Bones.Append(bone)
+		}
+		model.Bones.Setup()
+		return model
+	}
+
+	// 上半身2 は 上半身 と 首根元 の間にあるボーンから推定する
+	retarget := NewBoneRetarget(newModel([]string{"上半身", "Spine_Any", "首根元"}), nil)
+	if actual, ok := retarget.ModelName("上半身2"); !ok || actual != "Spine_Any" {
+		t.Errorf("Expected 上半身2 to be Spine_Any, got %s", actual)
+	}
+
+	// 間に未対応のボーンが2本ある場合は、どちらか決められないので推定しない
+	retarget = NewBoneRetarget(newModel([]string{"上半身", "Spine_Any", "Chest_Any", "首根元"}), nil)
+	if actual, ok := retarget.ModelName("上半身2"); ok {
+		t.Errorf("Expected 上半身2 to be unresolved, got %s", actual)
+	}
+
+	// 腕とひじの間の捩ボーンが未知の名前の場合も、ひじに捩ボーンを対応付けない
+	retarget = NewBoneRetarget(newModel([]string{"左腕", "ArmRoll_L", "Elbow_Any", "左手捩", "左手首"}), nil)
+	if actual, ok := retarget.ModelName("左ひじ"); ok {
+		t.Errorf("Expected 左ひじ to be unresolved, got %s", actual)
+	}
+}
+
+func TestBoneRetarget_RetargetedModel_Duplicated(t *testing.T) {
+	model := NewPmxModel("")
+	for _, name := range []string{"センター", "Center_Custom"} {
+		bone := NewBoneByName(name)
+		bone.ParentIndex = -1
+		model.Bones.Append(bone)
+	}
+	model.Bones.Setup()
+
+	// センター というボーンがあるのに、別のボーンを センター に指定した場合
+	retarget := NewBoneRetarget(model, map[string]string{"センター": "Center_Custom"})
+	if _, err := retarget.RetargetedModel(model); err == nil {
+		t.Errorf("Expected error for duplicated bone name")
+	}
+
+	// 重ならなければ置き換えられる
+	retarget = NewBoneRetarget(model, nil)
+	if _, err := retarget.RetargetedModel(model); err != nil {
+		t.Errorf("Expected error to be nil, got %q", err)
+	}
+}
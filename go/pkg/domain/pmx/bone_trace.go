@@ -0,0 +1,174 @@
+package pmx
+
+import (
+	"fmt"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/merr"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+)
+
+// トレース変換で使用する、準標準ボーン以外のボーン名
+const (
+	LOWER_TAIL StandardBoneName = "下半身先"
+	UPPER3     StandardBoneName = "上半身3"
+	INDEX_TIP  StandardBoneName = "{d}人指先"
+	TOE        StandardBoneName = "{d}つま先"
+)
+
+// TOE_LENGTH_RATIO つま先IKが無い場合の、ひざ〜足首の長さに対する足首〜つま先の長さの比
+const TOE_LENGTH_RATIO = 0.4
+
+// GetLowerTail 下半身先取得
+func (bones *Bones) GetLowerTail() (*Bone, error) {
+	return bones.GetByName(LOWER_TAIL.String())
+}
+
+// CreateLowerTail 下半身先作成
+func (bones *Bones) CreateLowerTail() (*Bone, error) {
+	bone := NewBoneByName(LOWER_TAIL.String())
+	bone.BoneFlag = BONE_FLAG_IS_VISIBLE | BONE_FLAG_CAN_MANIPULATE | BONE_FLAG_CAN_ROTATE
+
+	// 位置(両足の中間)
+	lower, _ := bones.GetLower()
+	legLeft, _ := bones.GetLeg(BONE_DIRECTION_LEFT)
+	legRight, _ := bones.GetLeg(BONE_DIRECTION_RIGHT)
+	if lower != nil && legLeft != nil && legRight != nil {
+		bone.Position = &mmath.MVec3{
+			X: lower.Position.X,
+			Y: (legLeft.Position.Y + legRight.Position.Y) * 0.5,
+			Z: (legLeft.Position.Z + legRight.Position.Z) * 0.5,
+		}
+		bone.ParentIndex = lower.Index()
+	} else {
+		return nil, merr.NewParentNotFoundError(
+			LOWER_TAIL.String(),
+			fmt.Sprintf("parent bone not found: %s", []string{LOWER.String(), LEG.Left(), LEG.Right()}),
+		)
+	}
+
+	return bone, nil
+}
+
+// GetUpper3 上半身3取得
+func (bones *Bones) GetUpper3() (*Bone, error) {
+	return bones.GetByName(UPPER3.String())
+}
+
+// CreateUpper3 上半身3作成
+func (bones *Bones) CreateUpper3() (*Bone, error) {
+	bone := NewBoneByName(UPPER3.String())
+	bone.BoneFlag = BONE_FLAG_IS_VISIBLE | BONE_FLAG_CAN_MANIPULATE | BONE_FLAG_CAN_ROTATE
+
+	// 位置(上半身2と首の中間)
+	upper2, _ := bones.GetUpper2()
+	neck, _ := bones.GetNeck()
+	if upper2 != nil && neck != nil {
+		bone.Position = upper2.Position.Lerp(neck.Position, 0.5)
+		bone.ParentIndex = upper2.Index()
+		bone.TailIndex = neck.Index()
+		bone.BoneFlag |= BONE_FLAG_TAIL_IS_BONE
+	} else {
+		return nil, merr.NewParentNotFoundError(
+			UPPER3.String(),
+			fmt.Sprintf("parent bone not found: %s", []string{UPPER2.String(), NECK.String()}),
+		)
+	}
+
+	return bone, nil
+}
+
+// GetIndexTip 人指先取得
+func (bones *Bones) GetIndexTip(direction BoneDirection) (*Bone, error) {
+	return bones.GetByName(INDEX_TIP.StringFromDirection(direction))
+}
+
+// CreateIndexTip 人指先作成
+func (bones *Bones) CreateIndexTip(direction BoneDirection) (*Bone, error) {
+	bone := NewBoneByName(INDEX_TIP.StringFromDirection(direction))
+	bone.BoneFlag = BONE_FLAG_CAN_ROTATE
+
+	// 位置(人指３から、人指２〜人指３と同じ長さだけ伸ばす)
+	index2, _ := bones.GetByName(INDEX2.StringFromDirection(direction))
+	index3, _ := bones.GetByName(INDEX3.StringFromDirection(direction))
+	if index2 != nil && index3 != nil {
+		bone.Position = index3.Position.Added(index3.Position.Subed(index2.Position))
+		bone.ParentIndex = index3.Index()
+	} else {
+		return nil, merr.NewParentNotFoundError(
+			INDEX_TIP.StringFromDirection(direction),
+			fmt.Sprintf("parent bone not found: %s",
+				[]string{INDEX2.StringFromDirection(direction), INDEX3.StringFromDirection(direction)}),
+		)
+	}
+
+	return bone, nil
+}
+
+// GetToe つま先取得
+func (bones *Bones) GetToe(direction BoneDirection) (*Bone, error) {
+	return bones.GetByName(TOE.StringFromDirection(direction))
+}
+
+// CreateToe つま先作成
+func (bones *Bones) CreateToe(direction BoneDirection) (*Bone, error) {
+	bone := NewBoneByName(TOE.StringFromDirection(direction))
+	bone.BoneFlag = BONE_FLAG_IS_VISIBLE | BONE_FLAG_CAN_MANIPULATE | BONE_FLAG_CAN_ROTATE
+
+	knee, _ := bones.GetKnee(direction)
+	ankle, _ := bones.GetAnkle(direction)
+	if knee == nil || ankle == nil {
+		return nil, merr.NewParentNotFoundError(
+			TOE.StringFromDirection(direction),
+			fmt.Sprintf("parent bone not found: %s",
+				[]string{KNEE.StringFromDirection(direction), ANKLE.StringFromDirection(direction)}),
+		)
+	}
+
+	// 位置
+	if toeIK, err := bones.GetToeIK(direction); err == nil && toeIK.Ik != nil {
+		// つま先IKのターゲットと同位置
+		if toe, err := bones.Get(toeIK.Ik.BoneIndex); err == nil {
+			bone.Position = toe.Position.Copy()
+		}
+	}
+	if bone.Position.IsZero() {
+		// 足首の前方(-Z)の地面上
+		bone.Position = &mmath.MVec3{
+			X: ankle.Position.X,
+			Y: 0.0,
+			Z: ankle.Position.Z - knee.Position.Distance(ankle.Position)*TOE_LENGTH_RATIO,
+		}
+	}
+	bone.Position.Y = 0.0
+
+	bone.ParentIndex = ankle.Index()
+
+	return bone, nil
+}
+
+// InsertTraceBones トレース変換に必要な不足ボーン作成
+// 作成できたボーン名を返す。親となるボーンが無く作成できないボーンは飛ばす
+func (bones *Bones) InsertTraceBones() ([]string, error) {
+	existNames := make(map[string]struct{}, bones.Length())
+	bones.ForEach(func(i int, b *Bone) bool {
+		existNames[b.Name()] = struct{}{}
+		return true
+	})
+
+	if err := bones.InsertShortageOverrideBones(); err != nil {
+		return nil, err
+	}
+	if err := bones.InsertSystemTailBones(); err != nil {
+		return nil, err
+	}
+
+	insertedNames := make([]string, 0)
+	bones.ForEach(func(i int, b *Bone) bool {
+		if _, ok := existNames[b.Name()]; !ok {
+			insertedNames = append(insertedNames, b.Name())
+		}
+		return true
+	})
+
+	return insertedNames, nil
+}
@@ -0,0 +1,137 @@
+package pmx
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+)
+
+func newTraceTestModel() *PmxModel {
+	model := NewPmxModel("")
+
+	for _, b := range []struct {
+		name     string
+		parent   string
+		position *mmath.MVec3
+	}{
+		{"全ての親", "", &mmath.MVec3{X: 0, Y: 0, Z: 0}},
+		{"センター", "全ての親", &mmath.MVec3{X: 0, Y: 8, Z: 0}},
+		{"上半身", "センター", &mmath.MVec3{X: 0, Y: 12, Z: 0}},
+		{"首", "上半身", &mmath.MVec3{X: 0, Y: 16, Z: 0}},
+		{"頭", "首", &mmath.MVec3{X: 0, Y: 17, Z: 0}},
+		{"下半身", "センター", &mmath.MVec3{X: 0, Y: 12, Z: 0}},
+		{"左足", "下半身", &mmath.MVec3{X: 1, Y: 10, Z: 0}},
+		{"左ひざ", "左足", &mmath.MVec3{X: 1, Y: 6, Z: 0}},
+		{"左足首", "左ひざ", &mmath.MVec3{X: 1, Y: 1, Z: 0}},
+		{"右足", "下半身", &mmath.MVec3{X: -1, Y: 10, Z: 0}},
+		{"右ひざ", "右足", &mmath.MVec3{X: -1, Y: 6, Z: 0}},
+		{"右足首", "右ひざ", &mmath.MVec3{X: -1, Y: 1, Z: 0}},
+	} {
+		bone := NewBoneByName(b.name)
+		bone.Position = b.position
+		bone.ParentIndex = -1
+		if parent, err := model.Bones.GetByName(b.parent); err == nil {
+			bone.ParentIndex = parent.Index()
+		}
+		model.Bones.Append(bone)
+	}
+	model.Bones.Setup()
+
+	return model
+}
+
+func TestBones_InsertTraceBones(t *testing.T) {
+	model := newTraceTestModel()
+
+	insertedNames, err := model.Bones.InsertTraceBones()
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	for _, name := range []string{"グルーブ", "体幹中心", "足中心", "上半身2", "上半身3", "下半身先", "左つま先", "右つま先"} {
+		if !slices.Contains(insertedNames, name) || !model.Bones.ContainsByName(name) {
+			t.Errorf("Expected %s to be inserted, got %v", name, insertedNames)
+		}
+	}
+
+	// 間に挟んだボーンは、親子関係を付け替える
+	for _, expected := range [][2]string{
+		{"グルーブ", "センター"},
+		{"体幹中心", "グルーブ"},
+		{"上半身", "体幹中心"},
+		{"下半身", "体幹中心"},
+		{"足中心", "下半身"},
+		{"左足", "足中心"},
+		{"上半身2", "上半身"},
+		{"上半身3", "上半身2"},
+		{"首", "上半身3"},
+		{"下半身先", "下半身"},
+		{"左つま先", "左足首"},
+	} {
+		bone, _ := model.Bones.GetByName(expected[0])
+		parent, _ := model.Bones.Get(bone.ParentIndex)
+		if parent == nil || parent.Name() != expected[1] {
+			t.Errorf("Expected parent of %s to be %s, got %v", expected[0], expected[1], parent)
+		}
+	}
+
+	lowerTail, _ := model.Bones.GetLowerTail()
+	if !lowerTail.Position.NearEquals(&mmath.MVec3{X: 0, Y: 10, Z: 0}, 1e-6) {
+		t.Errorf("Expected 下半身先 position to be (0, 10, 0), got %v", lowerTail.Position)
+	}
+
+	toe, _ := model.Bones.GetToe(BONE_DIRECTION_LEFT)
+	if !toe.Position.NearEquals(&mmath.MVec3{X: 1, Y: 0, Z: -5 * TOE_LENGTH_RATIO}, 1e-6) {
+		t.Errorf("Expected 左つま先 position to be (1, 0, -2), got %v", toe.Position)
+	}
+
+	// 親ボーンが無いボーンは作成しない
+	for _, name := range []string{"首根元", "左手首先先", "左人指先", "左つま先親"} {
+		if slices.Contains(insertedNames, name) || model.Bones.ContainsByName(name) {
+			t.Errorf("Expected %s not to be inserted, got %v", name, insertedNames)
+		}
+	}
+
+	// 既存のボーンのINDEXは変わらない
+	if neck, _ := model.Bones.GetNeck(); neck.Index() != 3 {
+		t.Errorf("Expected 首 index to be 3, got %d", neck.Index())
+	}
+}
+
+func TestBones_InsertTraceBones_KeepChildren(t *testing.T) {
+	model := newTraceTestModel()
+
+	upper, _ := model.Bones.GetUpper()
+
+	// 左右のある付属ボーン・IKボーンは付け替えない
+	hair := NewBoneByName("左髪")
+	hair.Position = &mmath.MVec3{X: 1, Y: 16, Z: 0}
+	hair.ParentIndex = upper.Index()
+	model.Bones.Append(hair)
+
+	ik := NewBoneByName("上半身IK")
+	ik.Position = &mmath.MVec3{X: 0, Y: 16, Z: 0}
+	ik.ParentIndex = upper.Index()
+	ik.Ik = NewIk()
+	ik.BoneFlag |= BONE_FLAG_IS_IK
+	model.Bones.Append(ik)
+
+	model.Bones.Setup()
+
+	if _, err := model.Bones.InsertTraceBones(); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	for _, expected := range [][2]string{
+		{"左髪", "上半身"},
+		{"上半身IK", "上半身"},
+		{"首", "上半身3"},
+	} {
+		bone, _ := model.Bones.GetByName(expected[0])
+		parent, _ := model.Bones.Get(bone.ParentIndex)
+		if parent == nil || parent.Name() != expected[1] {
+			t.Errorf("Expected parent of %s to be %s, got %v", expected[0], expected[1], parent)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package vmd
 
 import (
+	"maps"
+	"math"
+	"slices"
+
 	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
 	"github.com/petar/GoLLRB/llrb"
 )
@@ -212,3 +216,110 @@ func (baseFrames *BaseFrames[T]) appendOrInsert(f T, isSplitCurve bool) {
 func (baseFrames *BaseFrames[T]) Length() int {
 	return baseFrames.values.Length()
 }
+
+// reduceEndIndex startI から繋げられる最も遠い終了位置と、その時の近似結果を返す
+// 繋げられる範囲を倍々に広げてから、繋げられなかった範囲との間を二分探索する
+// 隣の位置までは補間しないので、fit は必ず成功するものとする
+func reduceEndIndex[T any](startI, lastI int, fit func(endI int) (T, bool)) (int, T) {
+	endI := startI + 1
+	result, _ := fit(endI)
+
+	ngI := lastI + 1
+	for step := 2; endI < lastI; step *= 2 {
+		i := min(startI+step, lastI)
+		if fitResult, ok := fit(i); ok {
+			endI = i
+			result = fitResult
+		} else {
+			ngI = i
+			break
+		}
+	}
+	for ngI-endI > 1 {
+		i := (endI + ngI) / 2
+		if fitResult, ok := fit(i); ok {
+			endI = i
+			result = fitResult
+		} else {
+			ngI = i
+		}
+	}
+
+	return endI, result
+}
+
+// warpTo キーフレームの時間を warp で移して frames に登録する
+// 整数フレームに乗らないキーフレームは、前後の整数フレームに当たる時間で補間曲線を分割したキーフレームに置き換えるので、
+// 変換後の整数フレームの値は、元のモーションを warp の時間で再生した値と一致する
+func (baseFrames *BaseFrames[T]) warpTo(frames *BaseFrames[T], warp *TimeWarp) {
+	if baseFrames.Length() == 0 {
+		return
+	}
+
+	// 元のキーフレームの補間曲線を分割しないように、コピーに対して分割する
+	work := NewBaseFrames(baseFrames.newFunc, baseFrames.nullFunc)
+	baseFrames.ForEach(func(index float32, f T) bool {
+		work.Append(f.Copy().(T))
+		return true
+	})
+
+	// 速さが変わる時間にキーフレームを置き、キーフレーム間では速さが一定になるようにする
+	minIndex := work.MinFrame()
+	maxIndex := work.MaxFrame()
+	for _, oldFrame := range warp.oldFrames {
+		index := float32(oldFrame)
+		if index > minIndex && index < maxIndex && !work.Contains(index) {
+			f := work.Get(index)
+			f.SetIndex(index)
+			work.Insert(f)
+		}
+	}
+
+	// 変換後の整数フレームと、それに当たる元の時間
+	times := make(map[int]float32)
+	work.ForEach(func(index float32, f T) bool {
+		t := warp.Frame(float64(index))
+		if fno := math.Round(t); math.Abs(t-fno) < 1e-4 {
+			times[int(fno)] = index
+			return true
+		}
+		for _, fno := range []float64{math.Floor(t), math.Ceil(t)} {
+			if _, ok := times[int(fno)]; !ok && fno >= 0 {
+				times[int(fno)] = float32(warp.OldFrame(fno))
+			}
+		}
+		return true
+	})
+	if _, ok := times[0]; !ok && warp.Frame(float64(minIndex)) < 0 {
+		// 0フレームより前に移ったキーフレームがある場合、0フレームの値を残す
+		times[0] = float32(warp.OldFrame(0))
+	}
+	fnos := slices.Sorted(maps.Keys(times))
+
+	for _, fno := range fnos {
+		index := times[fno]
+		if work.Contains(index) {
+			continue
+		}
+
+		var f T
+		switch {
+		case index < minIndex:
+			// 最初のキーフレームより前は、最初のキーフレームの値のまま
+			f = work.Get(minIndex).Copy().(T)
+		case index > maxIndex:
+			// 最後のキーフレームより後は、最後のキーフレームの値のまま
+			f = work.Get(maxIndex).Copy().(T)
+		default:
+			f = work.Get(index)
+		}
+		f.SetIndex(index)
+		work.Insert(f)
+	}
+
+	for _, fno := range fnos {
+		f := work.Get(times[fno]).Copy().(T)
+		f.SetIndex(float32(fno))
+		frames.Append(f)
+	}
+}
@@ -149,20 +149,37 @@ func (boneFrames *BoneFrames) Clean() {
 	}
 }
 
-func (boneFrames *BoneFrames) Reduce() *BoneFrames {
-	reduced := NewBoneFrames()
+// Reduce ボーンごとに補間曲線で近似してキーフレームを間引く
+func (boneFrames *BoneFrames) Reduce(maxPositionError, maxDegreeError float64) *BoneFrames {
+	reducedValues := make([]*BoneNameFrames, len(boneFrames.values))
 	var wg sync.WaitGroup
-	for _, boneNameFrames := range boneFrames.values {
+	for i, boneNameFrames := range boneFrames.values {
 		wg.Add(1)
-		go func(bnf *BoneNameFrames) {
+		go func(i int, bnf *BoneNameFrames) {
 			defer wg.Done()
-			reduced.Update(bnf.Reduce())
-		}(boneNameFrames)
+			reducedValues[i] = bnf.Reduce(maxPositionError, maxDegreeError)
+		}(i, boneNameFrames)
 	}
 	wg.Wait()
+
+	reduced := NewBoneFrames()
+	for _, bnf := range reducedValues {
+		reduced.Update(bnf)
+	}
 	return reduced
 }
 
+// Warp キーフレームの時間を warp で移したボーンキーフレーム
+func (boneFrames *BoneFrames) Warp(warp *TimeWarp) *BoneFrames {
+	warped := NewBoneFrames()
+	for _, boneNameFrames := range boneFrames.values {
+		warpedBnf := NewBoneNameFrames(boneNameFrames.Name)
+		boneNameFrames.warpTo(warpedBnf.BaseFrames, warp)
+		warped.Update(warpedBnf)
+	}
+	return warped
+}
+
 func (boneFrames *BoneFrames) ForEach(fn func(boneName string, boneNameFrames *BoneNameFrames)) {
 	for _, boneName := range boneFrames.getNames() {
 		fn(boneName, boneFrames.Get(boneName))
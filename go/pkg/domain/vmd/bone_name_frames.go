@@ -1,7 +1,7 @@
 package vmd
 
 import (
-	"slices"
+	"math"
 
 	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
 	"github.com/tiendc/go-deepcopy"
@@ -23,267 +23,127 @@ func nilBoneFrame() *BoneFrame {
 	return nil
 }
 
-func (boneNameFrames *BoneNameFrames) Reduce() *BoneNameFrames {
-	maxFrame := boneNameFrames.values.Max()
-	maxIFrame := int(maxFrame) + 1
-
-	frames := make([]float32, 0, maxIFrame)
-	xs := make([]float64, 0, maxIFrame)
-	ys := make([]float64, 0, maxIFrame)
-	zs := make([]float64, 0, maxIFrame)
-	fixRs := make([]float64, 0, maxIFrame)
-	quats := make([]*mmath.MQuaternion, 0, maxIFrame)
+// Reduce X・Y・Z・回転をそれぞれ1本の補間曲線で近似できる範囲でキーフレームを間引く
+// 間引いたキーフレーム間を補間曲線で再計算した値と元の値の差が、位置は maxPositionError 以内、
+// 回転は maxDegreeError 度以内になることを検算する
+func (boneNameFrames *BoneNameFrames) Reduce(maxPositionError, maxDegreeError float64) *BoneNameFrames {
+	if boneNameFrames.Length() <= 2 {
+		return boneNameFrames
+	}
 
-	for iF := range maxIFrame {
-		f := float32(iF)
+	minIFrame := int(boneNameFrames.MinFrame())
+	maxIFrame := int(boneNameFrames.MaxFrame())
 
-		frames = append(frames, f)
-		bf := boneNameFrames.Get(f)
+	// 全フレームの値を展開する
+	bfs := make([]*BoneFrame, 0, maxIFrame-minIFrame+1)
+	for iF := minIFrame; iF <= maxIFrame; iF++ {
+		bf := boneNameFrames.Get(float32(iF))
 
+		reduceBf := NewBoneFrame(float32(iF))
+		reduceBf.Position = mmath.NewMVec3()
 		if bf.Position != nil {
-			xs = append(xs, bf.Position.X)
-			ys = append(ys, bf.Position.Y)
-			zs = append(zs, bf.Position.Z)
-		} else {
-			xs = append(xs, 0)
-			ys = append(ys, 0)
-			zs = append(zs, 0)
+			reduceBf.Position = bf.Position.Copy()
 		}
-
+		reduceBf.Rotation = mmath.NewMQuaternion()
 		if bf.Rotation != nil {
-			quats = append(quats, bf.Rotation)
-			fixRs = append(fixRs, mmath.MQuaternionIdent.Dot(bf.Rotation))
-		} else {
-			quats = append(quats, mmath.MQuaternionIdent)
-			fixRs = append(fixRs, 0)
+			reduceBf.Rotation = bf.Rotation.Copy()
 		}
-	}
-
-	inflectionFrames := make([]float32, 0, boneNameFrames.Length())
-	if !mmath.IsAllSameValues(xs) {
-		inflectionFrames = append(inflectionFrames, mmath.FindInflectionFrames(frames, xs, 1e-4)...)
-	}
-	if !mmath.IsAllSameValues(ys) {
-		inflectionFrames = append(inflectionFrames, mmath.FindInflectionFrames(frames, ys, 1e-4)...)
-	}
-	if !mmath.IsAllSameValues(zs) {
-		inflectionFrames = append(inflectionFrames, mmath.FindInflectionFrames(frames, zs, 1e-4)...)
-	}
-	if !mmath.IsAllSameValues(fixRs) {
-		inflectionFrames = append(inflectionFrames, mmath.FindInflectionFrames(frames, fixRs, 1e-6)...)
-	}
-
-	inflectionFrames = mmath.Unique(inflectionFrames)
-	mmath.Sort(inflectionFrames)
-
-	if len(inflectionFrames) <= 2 {
-		// 変曲点がない場合、そのまま終了
-		return boneNameFrames
+		bfs = append(bfs, reduceBf)
 	}
 
 	reduceBfs := NewBoneNameFrames(boneNameFrames.Name)
-	{
-		// 最初のフレームを登録
-		bf := boneNameFrames.Get(inflectionFrames[0])
-		reduceBf := NewBoneFrame(inflectionFrames[0])
-		reduceBf.Position = bf.Position.Copy()
-		reduceBf.Rotation = bf.Rotation.Copy()
-		if bf.Curves != nil {
-			reduceBf.Curves = bf.Curves.Copy()
-		}
-		reduceBfs.Append(reduceBf)
-	}
+	reduceBfs.Append(bfs[0].Copy().(*BoneFrame))
 
-	startFrame := inflectionFrames[0]
-	midFrame := inflectionFrames[1]
-	endFrame := inflectionFrames[2]
-	exactEndFrame := float32(0)
+	lastI := len(bfs) - 1
+	for startI := 0; startI < lastI; {
+		endI, curves := reduceEndIndex(startI, lastI, func(endI int) (*BoneCurves, bool) {
+			curves := fitBoneCurves(bfs, startI, endI, maxPositionError, maxDegreeError)
+			return curves, curves != nil
+		})
 
-	i := 2
-	for {
-		if exactEndFrame >= maxFrame {
-			break
-		}
-
-		// print(fmt.Sprintf("startFrame: %f, midFrame: %f, endFrame: %f\n", startFrame, midFrame, endFrame))
-		exactEndFrame = boneNameFrames.reduceRange(startFrame, midFrame, endFrame, xs, ys, zs, quats, reduceBfs)
-
-		// 実際に繋げた終了フレームまでを繋ぐ
-		exactI := slices.Index(inflectionFrames, exactEndFrame)
-
-		if exactI == -1 {
-			// 途中で区切った場合、範囲から決める
-			if exactEndFrame < midFrame {
-				// 前半で区切っている場合
-				startFrame = exactEndFrame
-				continue
-			} else {
-				// 後半で区切っている場合
-				startFrame = midFrame
-				midFrame = exactEndFrame
-				continue
-			}
-		} else {
-			i = exactI
-		}
-
-		if i >= len(inflectionFrames)-1 {
-			break
-		}
-
-		i += 2
+		reduceBf := bfs[endI].Copy().(*BoneFrame)
+		reduceBf.Curves = curves
+		reduceBfs.Append(reduceBf)
 
-		if i >= len(inflectionFrames)-1 {
-			break
-		} else {
-			startFrame = exactEndFrame
-			midFrame = inflectionFrames[i-1]
-			endFrame = inflectionFrames[i]
-		}
+		startI = endI
 	}
 
-	// 最後のフレームを登録
-	{
-		startFrame := exactEndFrame
-		endFrame := inflectionFrames[len(inflectionFrames)-1]
-		midFrame := float32(int(startFrame+endFrame) / 2)
+	return reduceBfs
+}
 
-		exactEndFrame = boneNameFrames.reduceRange(startFrame, midFrame, endFrame, xs, ys, zs, quats, reduceBfs)
+// fitBoneCurves startI〜endI の値に補間曲線を当てはめ、検算して許容差以内の場合のみ返す
+func fitBoneCurves(bfs []*BoneFrame, startI, endI int, maxPositionError, maxDegreeError float64) *BoneCurves {
+	startBf := bfs[startI]
+	endBf := bfs[endI]
 
-		for exactEndFrame < endFrame {
-			// 途中までしか繋げなかった場合、そこから次を探す
-			startFrame = exactEndFrame
-			midFrame = float32(int(exactEndFrame+endFrame) / 2)
+	xs := make([]float64, 0, endI-startI+1)
+	ys := make([]float64, 0, endI-startI+1)
+	zs := make([]float64, 0, endI-startI+1)
+	rs := make([]float64, 0, endI-startI+1)
+	for i := startI; i <= endI; i++ {
+		xs = append(xs, bfs[i].Position.X)
+		ys = append(ys, bfs[i].Position.Y)
+		zs = append(zs, bfs[i].Position.Z)
 
-			exactEndFrame = boneNameFrames.reduceRange(startFrame, midFrame, endFrame, xs, ys, zs, quats, reduceBfs)
+		// 回転は開始から終了までの球面線形補間の割合にする
+		initialT := float64(i-startI) / float64(endI-startI)
+		t := mmath.FindSlerpT(startBf.Rotation, endBf.Rotation, bfs[i].Rotation, initialT)
+		if rotationDegree(startBf.Rotation.Slerp(endBf.Rotation, t), bfs[i].Rotation) > maxDegreeError {
+			// 開始から終了までの回転の経路から外れている場合、どの補間曲線でも表せない
+			return nil
 		}
+		rs = append(rs, t)
 	}
 
-	return reduceBfs
-}
-
-func (boneNameFrames *BoneNameFrames) reduceRange(
-	startFrame, midFrame, endFrame float32, xs, ys, zs []float64, quats []*mmath.MQuaternion, reduceBfs *BoneNameFrames,
-) float32 {
-	startIFrame := int(startFrame)
-	endIFrame := int(endFrame)
-
-	var rangeXs, rangeYs, rangeZs []float64
-	if len(xs) <= endIFrame {
-		rangeXs = xs[startIFrame:]
-		rangeYs = ys[startIFrame:]
-		rangeZs = zs[startIFrame:]
-	} else {
-		rangeXs = xs[startIFrame : endIFrame+1]
-		rangeYs = ys[startIFrame : endIFrame+1]
-		rangeZs = zs[startIFrame : endIFrame+1]
+	// 補間曲線は開始と終了の間の値しか取らないので、範囲からはみ出している場合は近似するまでもない
+	if isOutOfRange(xs, maxPositionError) || isOutOfRange(ys, maxPositionError) || isOutOfRange(zs, maxPositionError) {
+		return nil
 	}
 
-	rangeRs := make([]float64, 0, len(rangeXs))
-	startQuat := quats[startIFrame]
-	endQuat := quats[endIFrame]
-
-	for i := startFrame; i <= endFrame; i++ {
-		// initialT := float64(i-startFrame) / float64(endFrame-startFrame)
-		quat := quats[int(i)]
-		rangeRs = append(rangeRs, mmath.FindSlerpT(startQuat, endQuat, quat, 0))
+	curves := &BoneCurves{
+		TranslateX: mmath.NewCurveFromValues(xs, 1e-2),
+		TranslateY: mmath.NewCurveFromValues(ys, 1e-2),
+		TranslateZ: mmath.NewCurveFromValues(zs, 1e-2),
+		Rotate:     mmath.NewCurveFromValues(rs, 1e-4),
 	}
-
-	xCurve := mmath.NewCurveFromValues(rangeXs, 1e-2)
-	yCurve := mmath.NewCurveFromValues(rangeYs, 1e-2)
-	zCurve := mmath.NewCurveFromValues(rangeZs, 1e-2)
-	rCurve := mmath.NewCurveFromValues(rangeRs, 1e-4)
-
-	if xCurve != nil && yCurve != nil && zCurve != nil && rCurve != nil {
-		isSuccess := true
-		for i := startIFrame + 1; i < endIFrame; i++ {
-			// 全ての曲線が正常に生成された場合、検算する
-			if !boneNameFrames.checkCurve(
-				xCurve, yCurve, zCurve, rCurve,
-				xs[startIFrame], xs[i], xs[endIFrame],
-				ys[startIFrame], ys[i], ys[endIFrame],
-				zs[startIFrame], zs[i], zs[endIFrame],
-				quats[startIFrame], quats[i], quats[endIFrame],
-				startFrame, float32(i), endFrame,
-			) {
-				isSuccess = false
-				break
-			}
-		}
-
-		if isSuccess {
-			// 検算が成功した場合、最終フレームを登録して終了
-			bf := boneNameFrames.Get(endFrame)
-
-			reduceBf := NewBoneFrame(endFrame)
-			reduceBf.Position = bf.Position.Copy()
-			reduceBf.Rotation = bf.Rotation.Copy()
-			reduceBf.Curves = &BoneCurves{
-				TranslateX: xCurve,
-				TranslateY: yCurve,
-				TranslateZ: zCurve,
-				Rotate:     rCurve,
-			}
-
-			// print(fmt.Sprintf("reduceBf: %v\n", endFrame))
-			reduceBfs.Append(reduceBf)
-
-			// endまで繋げられた場合
-			return endFrame
-		}
+	if curves.TranslateX == nil || curves.TranslateY == nil || curves.TranslateZ == nil || curves.Rotate == nil {
+		return nil
 	}
 
-	// 生成できなかった場合、半分に分割する
-	midIFrame := int(midFrame)
-	if midIFrame == startIFrame || midIFrame == endIFrame {
-		// 半分に出来なかった場合、そのまま全打ち状態で終了
-		{
-			bf := boneNameFrames.Get(startFrame)
+	// 補間曲線で再計算した値が元の値から許容差以内に収まっているか
+	curveBf := endBf.Copy().(*BoneFrame)
+	curveBf.Curves = curves
+	for i := startI + 1; i < endI; i++ {
+		bf := curveBf.lerpFrame(startBf, bfs[i].Index()).(*BoneFrame)
 
-			reduceBf := NewBoneFrame(startFrame)
-			reduceBf.Position = bf.Position.Copy()
-			reduceBf.Rotation = bf.Rotation.Copy()
-			if bf.Curves != nil {
-				reduceBf.Curves = bf.Curves.Copy()
-			}
-
-			reduceBfs.Append(reduceBf)
+		if bf.Position.Distance(bfs[i].Position) > maxPositionError {
+			return nil
 		}
 
-		// endまで繋げられなかった場合
-		return midFrame
+		if rotationDegree(bf.Rotation, bfs[i].Rotation) > maxDegreeError {
+			return nil
+		}
 	}
 
-	return boneNameFrames.reduceRange(startFrame, float32(int(midFrame+startFrame)/2), midFrame, xs, ys, zs, quats, reduceBfs)
+	return curves
 }
 
-// 検算
-func (boneNameFrames *BoneNameFrames) checkCurve(
-	xCurve, yCurve, zCurve, rCurve *mmath.Curve, startX, nowX, endX, startY, nowY, endY, startZ, nowZ, endZ float64,
-	startQuat, nowQuat, endQuat *mmath.MQuaternion, startFrame, nowFrame, endFrame float32,
-) bool {
-	_, xy, _ := mmath.Evaluate(xCurve, startFrame, nowFrame, endFrame)
-	_, yy, _ := mmath.Evaluate(yCurve, startFrame, nowFrame, endFrame)
-	_, zy, _ := mmath.Evaluate(zCurve, startFrame, nowFrame, endFrame)
-	_, ry, _ := mmath.Evaluate(rCurve, startFrame, nowFrame, endFrame)
-
-	checkNowQuat := startQuat.Slerp(endQuat, ry)
-	if !checkNowQuat.NearEquals(nowQuat, 1e-1) {
-		return false
-	}
-
-	checkNowX := mmath.Lerp(startX, endX, xy)
-	if !mmath.NearEquals(checkNowX, nowX, 1e-1) {
-		return false
-	}
+// rotationDegree 補間した回転と元の回転の差(度)
+// 角度が小さい場合の補間は線形補間になり正規化されていないので、正規化してから比較する
+func rotationDegree(lerpRotation, rotation *mmath.MQuaternion) float64 {
+	return mmath.RadToDeg(2 * math.Acos(mmath.Clamped(math.Abs(lerpRotation.Normalized().Dot(rotation)), 0, 1)))
+}
 
-	checkNowY := mmath.Lerp(startY, endY, yy)
-	if !mmath.NearEquals(checkNowY, nowY, 1e-1) {
-		return false
+// isOutOfRange 途中の値が、最初と最後の値の範囲から tolerance を超えてはみ出しているか
+func isOutOfRange(values []float64, tolerance float64) bool {
+	minValue := math.Min(values[0], values[len(values)-1]) - tolerance
+	maxValue := math.Max(values[0], values[len(values)-1]) + tolerance
+	for _, v := range values {
+		if v < minValue || v > maxValue {
+			return true
+		}
 	}
-
-	checkNowZ := mmath.Lerp(startZ, endZ, zy)
-	return mmath.NearEquals(checkNowZ, nowZ, 1e-1)
+	return false
 }
 
 // ContainsActive 有効なキーフレが存在するか
@@ -331,3 +191,48 @@ func (boneNameFrames *BoneNameFrames) Copy() (*BoneNameFrames, error) {
 	err := deepcopy.Copy(copied, boneNameFrames)
 	return copied, err
 }
+
+// FixRotationContinuity 回転の符号を前のキーフレームと同じ半球に揃え、1フレームだけ突出した回転を前後のキーフレームからの球面線形補間で修復する
+// 前後どちらとの角速度(ラジアン/フレーム)も maxRadianPerFrame を超えていて、前後同士は以内の場合を突出とみなす。修復したキーフレームを返す
+func (boneNameFrames *BoneNameFrames) FixRotationContinuity(maxRadianPerFrame float64) []float32 {
+	frames := make([]*BoneFrame, 0, boneNameFrames.Length())
+	boneNameFrames.ForEach(func(fno float32, bf *BoneFrame) bool {
+		if bf.Rotation != nil {
+			frames = append(frames, bf)
+		}
+		return true
+	})
+
+	// 半球を揃える
+	for i := 1; i < len(frames); i++ {
+		if frames[i-1].Rotation.Dot(frames[i].Rotation) < 0 {
+			frames[i].Rotation = frames[i].Rotation.Negated()
+		}
+	}
+
+	fixedFrames := make([]float32, 0)
+	for i := 1; i < len(frames)-1; i++ {
+		prev, bf, next := frames[i-1], frames[i], frames[i+1]
+
+		if angularVelocity(prev, bf) <= maxRadianPerFrame ||
+			angularVelocity(bf, next) <= maxRadianPerFrame ||
+			angularVelocity(prev, next) > maxRadianPerFrame {
+			continue
+		}
+
+		t := float64(bf.Index()-prev.Index()) / float64(next.Index()-prev.Index())
+		bf.Rotation = prev.Rotation.Slerp(next.Rotation, t)
+		if prev.Rotation.Dot(bf.Rotation) < 0 {
+			bf.Rotation = bf.Rotation.Negated()
+		}
+		fixedFrames = append(fixedFrames, bf.Index())
+	}
+
+	return fixedFrames
+}
+
+// angularVelocity 2つのキーフレーム間の1フレームあたりの回転角度
+func angularVelocity(bf1, bf2 *BoneFrame) float64 {
+	radian := 2 * math.Acos(mmath.Clamped(math.Abs(bf1.Rotation.Dot(bf2.Rotation)), 0, 1))
+	return radian / math.Max(1, math.Abs(float64(bf2.Index()-bf1.Index())))
+}
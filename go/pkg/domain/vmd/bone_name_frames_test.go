@@ -0,0 +1,113 @@
+package vmd
+
+import (
+	"math"
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+)
+
+func TestBoneNameFrames_FixRotationContinuity(t *testing.T) {
+	boneNameFrames := NewBoneNameFrames("首")
+
+	for i, deg := range []float64{0, 2, 170, 6, 8} {
+		bf := NewBoneFrame(float32(i))
+		bf.Rotation = mmath.NewMQuaternionFromDegrees(0, deg, 0)
+		if i == 1 {
+			// 符号だけ反転した同じ回転
+			bf.Rotation = bf.Rotation.Negated()
+		}
+		boneNameFrames.Append(bf)
+	}
+
+	fixedFrames := boneNameFrames.FixRotationContinuity(mmath.DegToRad(30))
+	if len(fixedFrames) != 1 || fixedFrames[0] != 2 {
+		t.Errorf("Expected frame 2 to be fixed, got %v", fixedFrames)
+	}
+
+	if boneNameFrames.Get(0).Rotation.Dot(boneNameFrames.Get(1).Rotation) < 0 {
+		t.Errorf("Expected frame 1 to be in the same hemisphere, got %v", boneNameFrames.Get(1).Rotation)
+	}
+
+	expected := mmath.NewMQuaternionFromDegrees(0, 4, 0)
+	if !boneNameFrames.Get(2).Rotation.NearEquals(expected, 1e-6) {
+		t.Errorf("Expected frame 2 to be %v, got %v", expected, boneNameFrames.Get(2).Rotation)
+	}
+}
+
+func TestBoneNameFrames_Reduce(t *testing.T) {
+	boneNameFrames := NewBoneNameFrames("センター")
+
+	// 0〜60 で1往復する緩急のある動き
+	for i := 0; i <= 120; i++ {
+		ratio := (1 - math.Cos(float64(i)*math.Pi/60)) * 0.5
+
+		bf := NewBoneFrame(float32(i))
+		bf.Position = &mmath.MVec3{X: ratio * 10, Y: ratio * ratio * 5, Z: float64(i) * 0.1}
+		bf.Rotation = mmath.NewMQuaternionFromDegrees(0, ratio*90, 0)
+		boneNameFrames.Append(bf)
+	}
+
+	maxPositionError := 0.05
+	maxDegreeError := 0.5
+	reduced := boneNameFrames.Reduce(maxPositionError, maxDegreeError)
+
+	if reduced.Length() >= boneNameFrames.Length()/4 {
+		t.Errorf("Expected frames to be reduced, got %d", reduced.Length())
+	}
+	if reduced.MinFrame() != 0 || reduced.MaxFrame() != 120 {
+		t.Errorf("Expected frames from 0 to 120, got %v to %v", reduced.MinFrame(), reduced.MaxFrame())
+	}
+
+	for i := 0; i <= 120; i++ {
+		bf := boneNameFrames.Get(float32(i))
+		reducedBf := reduced.Get(float32(i))
+
+		if distance := bf.Position.Distance(reducedBf.Position); distance > maxPositionError {
+			t.Errorf("[%d] Expected position error to be within %v, got %v", i, maxPositionError, distance)
+		}
+
+		degree := mmath.RadToDeg(2 * math.Acos(mmath.Clamped(math.Abs(bf.Rotation.Dot(reducedBf.Rotation.Normalized())), 0, 1)))
+		if degree > maxDegreeError {
+			t.Errorf("[%d] Expected rotation error to be within %v, got %v", i, maxDegreeError, degree)
+		}
+	}
+}
+
+func TestBoneFrames_Warp_Rate(t *testing.T) {
+	boneFrames := NewBoneFrames()
+
+	// 60fps で作ったモーション(25フレーム目は30fpsの整数フレームに乗らない)
+	for _, fno := range []float32{0, 25, 60} {
+		bf := NewBoneFrame(fno)
+		bf.Position = &mmath.MVec3{X: float64(fno) * 0.1, Y: float64(fno*fno) * 0.01, Z: 0}
+		bf.Rotation = mmath.NewMQuaternionFromDegrees(0, float64(fno), 0)
+		bf.Curves = NewBoneCurves()
+		bf.Curves.TranslateY = &mmath.Curve{Start: mmath.MVec2{X: 64, Y: 0}, End: mmath.MVec2{X: 64, Y: 127}}
+		bf.Curves.Rotate = &mmath.Curve{Start: mmath.MVec2{X: 100, Y: 10}, End: mmath.MVec2{X: 110, Y: 60}}
+		boneFrames.Get("センター").Append(bf)
+	}
+
+	ratio := VMD_FPS / 60.0
+	retimed := boneFrames.Warp(NewRateTimeWarp(1 / ratio)).Get("センター")
+
+	if retimed.MaxFrame() != 30 {
+		t.Errorf("Expected max frame to be 30, got %v", retimed.MaxFrame())
+	}
+	if retimed.Contains(25) || !retimed.Contains(12) || !retimed.Contains(13) {
+		t.Errorf("Expected frame 25 to be replaced with 12 and 13")
+	}
+
+	for fno := 0; fno <= 30; fno++ {
+		bf := boneFrames.Get("センター").Get(float32(float64(fno) / ratio))
+		retimedBf := retimed.Get(float32(fno))
+
+		// 分割した補間曲線は 0〜127 に丸められるので、その分の誤差は許容する
+		if !retimedBf.Position.NearEquals(bf.Position, 0.2) {
+			t.Errorf("[%d] Expected position to be %v, got %v", fno, bf.Position, retimedBf.Position)
+		}
+		if degree := rotationDegree(retimedBf.Rotation, bf.Rotation.Normalized()); degree > 0.5 {
+			t.Errorf("[%d] Expected rotation to be %v, got %v", fno, bf.Rotation, retimedBf.Rotation)
+		}
+	}
+}
@@ -139,5 +139,6 @@ func (cameraCurves *CameraCurves) Copy() *CameraCurves {
 		Rotate:      cameraCurves.Rotate.Copy(),
 		Distance:    cameraCurves.Distance.Copy(),
 		ViewOfAngle: cameraCurves.ViewOfAngle.Copy(),
+		Values:      cameraCurves.Values,
 	}
 }
@@ -47,7 +47,7 @@ func (cf *CameraFrame) Added(v *CameraFrame) *CameraFrame {
 
 func (cf *CameraFrame) Copy() IBaseFrame {
 	copied := NewCameraFrame(cf.Index())
-	copied.Position = cf.Position
+	copied.Position = cf.Position.Copy()
 	copied.Degrees = cf.Degrees.Copy()
 	copied.Distance = cf.Distance
 	copied.ViewOfAngle = cf.ViewOfAngle
@@ -79,6 +79,7 @@ func (nextCf *CameraFrame) lerpFrame(prevFrame IBaseFrame, index float32) IBaseF
 	q2 := mmath.NewMQuaternionFromRadians(nextCf.Degrees.X, nextCf.Degrees.Y, nextCf.Degrees.Z)
 
 	cf.Quaternion = q1.Slerp(q2, ry)
+	cf.Degrees = cf.Quaternion.ToRadians()
 
 	cf.Position.X = mmath.Lerp(prevCf.Position.X, nextCf.Position.X, xy)
 	cf.Position.Y = mmath.Lerp(prevCf.Position.Y, nextCf.Position.Y, yy)
@@ -0,0 +1,24 @@
+package vmd
+
+import (
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+)
+
+func TestCameraFrame_Copy(t *testing.T) {
+	cf := NewCameraFrame(10)
+	cf.Position = &mmath.MVec3{X: 1, Y: 2, Z: 3}
+	cf.Degrees = &mmath.MVec3{X: 0.1, Y: 0.2, Z: 0.3}
+
+	copied := cf.Copy().(*CameraFrame)
+	copied.Position.X = 10
+	copied.Degrees.X = 1
+
+	if copied.Index() != 10 {
+		t.Errorf("Expected index to be 10, got %v", copied.Index())
+	}
+	if cf.Position.X != 1 || cf.Degrees.X != 0.1 {
+		t.Errorf("Expected original to be unchanged, got %v %v", cf.Position, cf.Degrees)
+	}
+}
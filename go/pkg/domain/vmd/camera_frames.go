@@ -1,6 +1,11 @@
 package vmd
 
-import "github.com/tiendc/go-deepcopy"
+import (
+	"math"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/tiendc/go-deepcopy"
+)
 
 type CameraFrames struct {
 	*BaseFrames[*CameraFrame]
@@ -30,6 +35,146 @@ func (cameraFrames *CameraFrames) Clean() {
 	}
 }
 
+// Reduce X・Y・Z・回転・距離・視野角をそれぞれ1本の補間曲線で近似できる範囲でキーフレームを間引く
+// 間引いたキーフレーム間を補間曲線で再計算した値と元の値の差が、位置と距離は maxPositionError 以内、
+// 回転と視野角は maxDegreeError 度以内になることを検算する
+func (cameraFrames *CameraFrames) Reduce(maxPositionError, maxDegreeError float64) *CameraFrames {
+	if cameraFrames.Length() <= 2 {
+		return cameraFrames
+	}
+
+	minIFrame := int(cameraFrames.MinFrame())
+	maxIFrame := int(cameraFrames.MaxFrame())
+
+	// 全フレームの値を展開する
+	cfs := make([]*CameraFrame, 0, maxIFrame-minIFrame+1)
+	for iF := minIFrame; iF <= maxIFrame; iF++ {
+		cf := cameraFrames.Get(float32(iF))
+
+		reduceCf := NewCameraFrame(float32(iF))
+		reduceCf.Position = cf.Position.Copy()
+		if cameraFrames.Contains(float32(iF)) || len(cfs) == 0 {
+			// キーフレームは、±180度を超えて回り込んだ角度をそのまま使う
+			reduceCf.Degrees = cf.Degrees.Copy()
+		} else {
+			// 補間したキーフレームは±180度に収まっているので、前のフレームから連続するように戻す
+			reduceCf.Degrees = unwrapRadians(cf.Quaternion.ToRadians(), cfs[len(cfs)-1].Degrees)
+		}
+		reduceCf.Quaternion = mmath.NewMQuaternionFromRadians(reduceCf.Degrees.X, reduceCf.Degrees.Y, reduceCf.Degrees.Z)
+		reduceCf.Distance = cf.Distance
+		reduceCf.ViewOfAngle = cf.ViewOfAngle
+		reduceCf.IsPerspectiveOff = cf.IsPerspectiveOff
+		cfs = append(cfs, reduceCf)
+	}
+
+	reduceCfs := NewCameraFrames()
+	reduceCfs.Append(cfs[0])
+
+	lastI := len(cfs) - 1
+	for startI := 0; startI < lastI; {
+		endI, curves := reduceEndIndex(startI, lastI, func(endI int) (*CameraCurves, bool) {
+			curves := fitCameraCurves(cfs, startI, endI, maxPositionError, maxDegreeError)
+			return curves, curves != nil
+		})
+
+		if curves != nil {
+			cfs[endI].Curves = curves
+		}
+		reduceCfs.Append(cfs[endI])
+
+		startI = endI
+	}
+
+	return reduceCfs
+}
+
+// unwrapRadians 各軸の角度(ラジアン)を 2π の倍数だけずらし、prev に最も近い角度にする
+func unwrapRadians(radians, prev *mmath.MVec3) *mmath.MVec3 {
+	unwrap := func(v, prev float64) float64 {
+		return v + 2*math.Pi*math.Round((prev-v)/(2*math.Pi))
+	}
+	return &mmath.MVec3{
+		X: unwrap(radians.X, prev.X),
+		Y: unwrap(radians.Y, prev.Y),
+		Z: unwrap(radians.Z, prev.Z),
+	}
+}
+
+// fitCameraCurves startI〜endI の値に補間曲線を当てはめ、検算して許容差以内の場合のみ返す
+func fitCameraCurves(cfs []*CameraFrame, startI, endI int, maxPositionError, maxDegreeError float64) *CameraCurves {
+	startCf := cfs[startI]
+	endCf := cfs[endI]
+
+	xs := make([]float64, 0, endI-startI+1)
+	ys := make([]float64, 0, endI-startI+1)
+	zs := make([]float64, 0, endI-startI+1)
+	rs := make([]float64, 0, endI-startI+1)
+	ds := make([]float64, 0, endI-startI+1)
+	vs := make([]float64, 0, endI-startI+1)
+	for i := startI; i <= endI; i++ {
+		if i > startI && cfs[i].IsPerspectiveOff != endCf.IsPerspectiveOff {
+			// パースは補間されず次のキーフレームの値になる
+			return nil
+		}
+
+		xs = append(xs, cfs[i].Position.X)
+		ys = append(ys, cfs[i].Position.Y)
+		zs = append(zs, cfs[i].Position.Z)
+		ds = append(ds, cfs[i].Distance)
+		vs = append(vs, float64(cfs[i].ViewOfAngle))
+
+		// 回転は開始から終了までの球面線形補間の割合にする
+		initialT := float64(i-startI) / float64(endI-startI)
+		t := mmath.FindSlerpT(startCf.Quaternion, endCf.Quaternion, cfs[i].Quaternion, initialT)
+		if rotationDegree(startCf.Quaternion.Slerp(endCf.Quaternion, t), cfs[i].Quaternion) > maxDegreeError {
+			// 開始から終了までの回転の経路から外れている場合、どの補間曲線でも表せない
+			return nil
+		}
+		rs = append(rs, t)
+	}
+
+	// 補間曲線は開始と終了の間の値しか取らないので、範囲からはみ出している場合は近似するまでもない
+	if isOutOfRange(xs, maxPositionError) || isOutOfRange(ys, maxPositionError) || isOutOfRange(zs, maxPositionError) ||
+		isOutOfRange(ds, maxPositionError) || isOutOfRange(vs, maxDegreeError) {
+		return nil
+	}
+
+	curves := NewCameraCurves()
+	curves.TranslateX = mmath.NewCurveFromValues(xs, 1e-2)
+	curves.TranslateY = mmath.NewCurveFromValues(ys, 1e-2)
+	curves.TranslateZ = mmath.NewCurveFromValues(zs, 1e-2)
+	curves.Rotate = mmath.NewCurveFromValues(rs, 1e-4)
+	curves.Distance = mmath.NewCurveFromValues(ds, 1e-2)
+	curves.ViewOfAngle = mmath.NewCurveFromValues(vs, 1e-2)
+	if curves.TranslateX == nil || curves.TranslateY == nil || curves.TranslateZ == nil ||
+		curves.Rotate == nil || curves.Distance == nil || curves.ViewOfAngle == nil {
+		return nil
+	}
+
+	// 補間曲線で再計算した値が元の値から許容差以内に収まっているか
+	curveCf := *endCf
+	curveCf.Curves = curves
+	for i := startI + 1; i < endI; i++ {
+		cf := curveCf.lerpFrame(startCf, cfs[i].Index()).(*CameraFrame)
+
+		if cf.Position.Distance(cfs[i].Position) > maxPositionError ||
+			math.Abs(cf.Distance-cfs[i].Distance) > maxPositionError ||
+			math.Abs(float64(cf.ViewOfAngle-cfs[i].ViewOfAngle)) > maxDegreeError ||
+			rotationDegree(cf.Quaternion, cfs[i].Quaternion) > maxDegreeError {
+			return nil
+		}
+	}
+
+	return curves
+}
+
+// Warp キーフレームの時間を warp で移したカメラキーフレーム
+func (cameraFrames *CameraFrames) Warp(warp *TimeWarp) *CameraFrames {
+	warped := NewCameraFrames()
+	cameraFrames.warpTo(warped.BaseFrames, warp)
+	return warped
+}
+
 func (cameraFrames *CameraFrames) Copy() (*CameraFrames, error) {
 	copied := new(CameraFrames)
 	err := deepcopy.Copy(copied, cameraFrames)
@@ -0,0 +1,99 @@
+package vmd
+
+import (
+	"math"
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+)
+
+func TestCameraFrames_Reduce(t *testing.T) {
+	cameraFrames := NewCameraFrames()
+
+	// 0〜60 で緩急をつけて回り込みながら寄る
+	for i := 0; i <= 60; i++ {
+		ratio := (1 - math.Cos(float64(i)*math.Pi/60)) * 0.5
+
+		cf := NewCameraFrame(float32(i))
+		cf.Position = &mmath.MVec3{X: ratio * 10, Y: 10, Z: ratio * ratio * 5}
+		cf.Degrees = &mmath.MVec3{X: 0, Y: mmath.DegToRad(ratio * 90), Z: 0}
+		cf.Distance = -45 + ratio*20
+		cf.ViewOfAngle = 30
+		cf.IsPerspectiveOff = false
+		cameraFrames.Append(cf)
+	}
+
+	maxPositionError := 0.05
+	maxDegreeError := 0.5
+	reduced := cameraFrames.Reduce(maxPositionError, maxDegreeError)
+
+	if reduced.Length() >= cameraFrames.Length()/4 {
+		t.Errorf("Expected frames to be reduced, got %d", reduced.Length())
+	}
+
+	for i := 0; i <= 60; i++ {
+		cf := cameraFrames.Get(float32(i))
+		reducedCf := reduced.Get(float32(i))
+
+		if distance := cf.Position.Distance(reducedCf.Position); distance > maxPositionError {
+			t.Errorf("[%d] Expected position error to be within %v, got %v", i, maxPositionError, distance)
+		}
+		if diff := math.Abs(cf.Distance - reducedCf.Distance); diff > maxPositionError {
+			t.Errorf("[%d] Expected distance error to be within %v, got %v", i, maxPositionError, diff)
+		}
+
+		quat := mmath.NewMQuaternionFromRadians(cf.Degrees.X, cf.Degrees.Y, cf.Degrees.Z)
+		reducedQuat := reducedCf.Quaternion
+		if reducedQuat == nil {
+			reducedQuat = mmath.NewMQuaternionFromRadians(reducedCf.Degrees.X, reducedCf.Degrees.Y, reducedCf.Degrees.Z)
+		}
+		if degree := rotationDegree(reducedQuat, quat); degree > maxDegreeError {
+			t.Errorf("[%d] Expected rotation error to be within %v, got %v", i, maxDegreeError, degree)
+		}
+	}
+}
+
+func TestCameraFrames_Reduce_Unwrap(t *testing.T) {
+	cameraFrames := NewCameraFrames()
+
+	// 0〜60 で1回転半(540度)回り込む。キーフレームは10フレームごと
+	for i := 0; i <= 60; i += 10 {
+		cf := NewCameraFrame(float32(i))
+		cf.Position = &mmath.MVec3{X: 0, Y: 10, Z: 0}
+		cf.Degrees = &mmath.MVec3{X: 0, Y: mmath.DegToRad(float64(i) * 9), Z: 0}
+		cf.Distance = -45
+		cf.ViewOfAngle = 30
+		cameraFrames.Append(cf)
+	}
+
+	reduced := cameraFrames.Reduce(0.05, 0.5)
+
+	// 間引いた後も、角度は±180度に折り返さず回り続ける
+	prevY := math.Inf(-1)
+	reduced.ForEach(func(fno float32, cf *CameraFrame) bool {
+		if cf.Degrees.Y < prevY {
+			t.Errorf("[%v] Expected Y rotation to keep increasing, got %v after %v",
+				fno, mmath.RadToDeg(cf.Degrees.Y), mmath.RadToDeg(prevY))
+		}
+		if cameraFrames.Contains(fno) && math.Abs(cf.Degrees.Y-cameraFrames.Get(fno).Degrees.Y) > 1e-6 {
+			t.Errorf("[%v] Expected Y rotation %v, got %v",
+				fno, mmath.RadToDeg(cameraFrames.Get(fno).Degrees.Y), mmath.RadToDeg(cf.Degrees.Y))
+		}
+		prevY = cf.Degrees.Y
+		return true
+	})
+
+	if last := reduced.Get(reduced.MaxFrame()); math.Abs(mmath.RadToDeg(last.Degrees.Y)-540) > 1e-6 {
+		t.Errorf("Expected last Y rotation to be 540, got %v", mmath.RadToDeg(last.Degrees.Y))
+	}
+}
+
+func TestUnwrapRadians(t *testing.T) {
+	prev := &mmath.MVec3{X: mmath.DegToRad(170), Y: mmath.DegToRad(-350), Z: 0}
+	actual := unwrapRadians(&mmath.MVec3{X: mmath.DegToRad(-175), Y: mmath.DegToRad(5), Z: mmath.DegToRad(10)}, prev)
+	expected := &mmath.MVec3{X: mmath.DegToRad(185), Y: mmath.DegToRad(-355), Z: mmath.DegToRad(10)}
+
+	if !actual.NearEquals(expected, 1e-8) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
@@ -0,0 +1,277 @@
+package vmd
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+)
+
+// Crossfade from から to へ、startFrame〜endFrame の間で切り替えたモーション
+// 窓の前は from、窓の後は to のキーフレームをそのまま使い、窓の間は毎フレーム、
+// ボーン・カメラ・照明の位置を線形補間・回転を球面線形補間、モーフ・距離・視野角・色を線形補間した値を置く
+// easing は窓の中の切り替えの進み具合(VMDの補間曲線と同じ形式)
+// IKのON/OFF・パース・セルフ影モードは補間できないので、進み具合が半分を超えたフレームで to の状態に切り替える
+// カメラ・照明・セルフ影のキーフレームが片方にしか無い場合は、あるほうのキーフレームをそのまま使う
+// 物理・風のキーフレームは from のものをそのまま使う
+func Crossfade(from, to *VmdMotion, startFrame, endFrame int, easing *mmath.Curve) (*VmdMotion, error) {
+	if startFrame < 0 || endFrame <= startFrame {
+		return nil, fmt.Errorf("crossfade window must be increasing: %d -> %d", startFrame, endFrame)
+	}
+
+	start := float32(startFrame)
+	end := float32(endFrame)
+	weights := make([]float64, endFrame-startFrame+1)
+	for i := range weights {
+		_, weights[i], _ = mmath.Evaluate(easing, start, start+float32(i), end)
+	}
+
+	blended, err := from.Copy()
+	if err != nil {
+		return nil, err
+	}
+	blended.BoneFrames = NewBoneFrames()
+	blended.MorphFrames = NewMorphFrames()
+	blended.IkFrames = NewIkFrames()
+
+	for _, boneName := range unionNames(from.BoneFrames.Names(), to.BoneFrames.Names()) {
+		fromBnf := NewBoneNameFrames(boneName)
+		if from.BoneFrames.Contains(boneName) {
+			fromBnf = from.BoneFrames.Get(boneName)
+		}
+		toBnf := NewBoneNameFrames(boneName)
+		if to.BoneFrames.Contains(boneName) {
+			toBnf = to.BoneFrames.Get(boneName)
+		}
+
+		blendedBnf := NewBoneNameFrames(boneName)
+		for i, w := range weights {
+			fno := start + float32(i)
+			bf := NewBoneFrame(fno)
+			bf.Position = lerpPosition(fromBnf.Get(fno).Position, toBnf.Get(fno).Position, w)
+			bf.Rotation = slerpRotation(fromBnf.Get(fno).Rotation, toBnf.Get(fno).Rotation, w)
+			blendedBnf.Append(bf)
+		}
+		appendOutsideWindow(blendedBnf.BaseFrames, fromBnf.BaseFrames, toBnf.BaseFrames, start, end)
+
+		blended.BoneFrames.Update(blendedBnf)
+	}
+
+	for _, morphName := range unionNames(from.MorphFrames.Names(), to.MorphFrames.Names()) {
+		fromMnf := NewMorphNameFrames(morphName)
+		if from.MorphFrames.Contains(morphName) {
+			fromMnf = from.MorphFrames.Get(morphName)
+		}
+		toMnf := NewMorphNameFrames(morphName)
+		if to.MorphFrames.Contains(morphName) {
+			toMnf = to.MorphFrames.Get(morphName)
+		}
+
+		blendedMnf := NewMorphNameFrames(morphName)
+		for i, w := range weights {
+			fno := start + float32(i)
+			mf := NewMorphFrame(fno)
+			mf.Ratio = mmath.Lerp(fromMnf.Get(fno).Ratio, toMnf.Get(fno).Ratio, w)
+			blendedMnf.Append(mf)
+		}
+		appendOutsideWindow(blendedMnf.BaseFrames, fromMnf.BaseFrames, toMnf.BaseFrames, start, end)
+
+		blended.MorphFrames.Update(blendedMnf)
+	}
+
+	if from.IkFrames.Length() > 0 || to.IkFrames.Length() > 0 {
+		switchFrame := end
+		for i, w := range weights {
+			if w >= 0.5 {
+				switchFrame = start + float32(i)
+				break
+			}
+		}
+
+		from.IkFrames.ForEach(func(index float32, ikf *IkFrame) bool {
+			if index < switchFrame {
+				blended.AppendIkFrame(ikf.Copy().(*IkFrame))
+			}
+			return true
+		})
+		switchIkf := to.IkFrames.Get(switchFrame).Copy().(*IkFrame)
+		switchIkf.SetIndex(switchFrame)
+		blended.AppendIkFrame(switchIkf)
+		to.IkFrames.ForEach(func(index float32, ikf *IkFrame) bool {
+			if index > switchFrame {
+				blended.AppendIkFrame(ikf.Copy().(*IkFrame))
+			}
+			return true
+		})
+	}
+
+	if from.CameraFrames.Length() > 0 && to.CameraFrames.Length() > 0 {
+		blended.CameraFrames = crossfadeCameraFrames(from.CameraFrames, to.CameraFrames, weights, start, end)
+	} else if to.CameraFrames.Length() > 0 {
+		if blended.CameraFrames, err = to.CameraFrames.Copy(); err != nil {
+			return nil, err
+		}
+	}
+
+	if from.LightFrames.Length() > 0 && to.LightFrames.Length() > 0 {
+		blended.LightFrames = crossfadeLightFrames(from.LightFrames, to.LightFrames, weights, start, end)
+	} else if to.LightFrames.Length() > 0 {
+		if blended.LightFrames, err = to.LightFrames.Copy(); err != nil {
+			return nil, err
+		}
+	}
+
+	if from.ShadowFrames.Length() > 0 && to.ShadowFrames.Length() > 0 {
+		blended.ShadowFrames = crossfadeShadowFrames(from.ShadowFrames, to.ShadowFrames, weights, start, end)
+	} else if to.ShadowFrames.Length() > 0 {
+		if blended.ShadowFrames, err = to.ShadowFrames.Copy(); err != nil {
+			return nil, err
+		}
+	}
+
+	return blended, nil
+}
+
+// crossfadeCameraFrames 窓の間のカメラを毎フレーム補間したカメラキーフレーム
+func crossfadeCameraFrames(from, to *CameraFrames, weights []float64, start, end float32) *CameraFrames {
+	blended := NewCameraFrames()
+
+	prevDegrees := from.Get(start).Degrees
+	for i, w := range weights {
+		fno := start + float32(i)
+		fromCf := from.Get(fno)
+		toCf := to.Get(fno)
+
+		fromQuat := mmath.NewMQuaternionFromRadians(fromCf.Degrees.X, fromCf.Degrees.Y, fromCf.Degrees.Z)
+		toQuat := mmath.NewMQuaternionFromRadians(toCf.Degrees.X, toCf.Degrees.Y, toCf.Degrees.Z)
+
+		cf := NewCameraFrame(fno)
+		cf.Position = fromCf.Position.Lerp(toCf.Position, w)
+		// オイラー角は±180度に収まっているので、前のフレームから連続するように戻す
+		cf.Degrees = unwrapRadians(fromQuat.Slerp(toQuat, w).ToRadians(), prevDegrees)
+		cf.Distance = mmath.Lerp(fromCf.Distance, toCf.Distance, w)
+		cf.ViewOfAngle = int(math.Round(mmath.Lerp(float64(fromCf.ViewOfAngle), float64(toCf.ViewOfAngle), w)))
+		cf.IsPerspectiveOff = fromCf.IsPerspectiveOff
+		if w >= 0.5 {
+			cf.IsPerspectiveOff = toCf.IsPerspectiveOff
+		}
+		blended.Append(cf)
+
+		prevDegrees = cf.Degrees
+	}
+	appendOutsideWindow(blended.BaseFrames, from.BaseFrames, to.BaseFrames, start, end)
+
+	return blended
+}
+
+// crossfadeLightFrames 窓の間の照明を毎フレーム補間した照明キーフレーム
+func crossfadeLightFrames(from, to *LightFrames, weights []float64, start, end float32) *LightFrames {
+	blended := NewLightFrames()
+
+	for i, w := range weights {
+		fno := start + float32(i)
+		lf := NewLightFrame(fno)
+		lf.Position = from.Get(fno).Position.Lerp(to.Get(fno).Position, w)
+		lf.Color = from.Get(fno).Color.Lerp(to.Get(fno).Color, w)
+		blended.Append(lf)
+	}
+	appendOutsideWindow(blended.BaseFrames, from.BaseFrames, to.BaseFrames, start, end)
+
+	return blended
+}
+
+// crossfadeShadowFrames 窓の間のセルフ影を毎フレーム補間したセルフ影キーフレーム
+func crossfadeShadowFrames(from, to *ShadowFrames, weights []float64, start, end float32) *ShadowFrames {
+	blended := NewShadowFrames()
+
+	for i, w := range weights {
+		fno := start + float32(i)
+		sf := NewShadowFrame(fno)
+		sf.Distance = mmath.Lerp(from.Get(fno).Distance, to.Get(fno).Distance, w)
+		sf.ShadowMode = from.Get(fno).ShadowMode
+		if w >= 0.5 {
+			sf.ShadowMode = to.Get(fno).ShadowMode
+		}
+		blended.Append(sf)
+	}
+	appendOutsideWindow(blended.BaseFrames, from.BaseFrames, to.BaseFrames, start, end)
+
+	return blended
+}
+
+// appendOutsideWindow 窓の開始までの from のキーフレームと、窓の後の to のキーフレームを frames に登録する
+// 窓の端で補間曲線を分割するので、窓の外の動きはそれぞれ元のまま
+func appendOutsideWindow[T IBaseFrame](frames, from, to *BaseFrames[T], start, end float32) {
+	for _, f := range from.splitKeys(start) {
+		if f.Index() <= start {
+			frames.Append(f)
+		}
+	}
+	for _, f := range to.splitKeys(end) {
+		if f.Index() > end {
+			frames.Append(f)
+		}
+	}
+}
+
+// splitKeys frame で補間曲線を分割した後の、全キーフレームのコピー
+func (baseFrames *BaseFrames[T]) splitKeys(frame float32) []T {
+	work := NewBaseFrames(baseFrames.newFunc, baseFrames.nullFunc)
+	baseFrames.ForEach(func(index float32, f T) bool {
+		work.Append(f.Copy().(T))
+		return true
+	})
+
+	if work.Length() > 0 && !work.Contains(frame) {
+		f := work.Get(frame)
+		f.SetIndex(frame)
+		work.Insert(f)
+	}
+
+	keys := make([]T, 0, work.Length())
+	work.ForEach(func(index float32, f T) bool {
+		keys = append(keys, f)
+		return true
+	})
+	return keys
+}
+
+// unionNames 両方の名前を重複なく並べる(a の順の後に、a に無い b の名前を b の順で並べる)
+func unionNames(a, b []string) []string {
+	names := slices.Clone(a)
+	for _, name := range b {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// lerpPosition 位置の線形補間(片方が無い場合は原点とする)
+func lerpPosition(from, to *mmath.MVec3, t float64) *mmath.MVec3 {
+	if from == nil && to == nil {
+		return nil
+	}
+	if from == nil {
+		from = mmath.NewMVec3()
+	}
+	if to == nil {
+		to = mmath.NewMVec3()
+	}
+	return from.Lerp(to, t)
+}
+
+// slerpRotation 回転の球面線形補間(片方が無い場合は単位回転とする)
+func slerpRotation(from, to *mmath.MQuaternion, t float64) *mmath.MQuaternion {
+	if from == nil && to == nil {
+		return nil
+	}
+	if from == nil {
+		from = mmath.NewMQuaternion()
+	}
+	if to == nil {
+		to = mmath.NewMQuaternion()
+	}
+	return from.Slerp(to, t).Normalized()
+}
@@ -0,0 +1,180 @@
+package vmd
+
+import (
+	"math"
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+)
+
+func TestCrossfade(t *testing.T) {
+	from := NewVmdMotion("")
+	to := NewVmdMotion("")
+
+	for _, fno := range []float32{0, 200} {
+		fromBf := NewBoneFrame(fno)
+		fromBf.Position = &mmath.MVec3{X: float64(fno) * 0.1, Y: 0, Z: 0}
+		fromBf.Rotation = mmath.NewMQuaternion()
+		fromBf.Curves = NewBoneCurves()
+		fromBf.Curves.TranslateX = mmath.NewCurveByValues(100, 10, 110, 60)
+		from.AppendBoneFrame("センター", fromBf)
+
+		toBf := NewBoneFrame(fno)
+		toBf.Position = &mmath.MVec3{X: 5, Y: float64(fno) * 0.1, Z: 0}
+		toBf.Rotation = mmath.NewMQuaternionFromDegrees(0, 90, 0)
+		to.AppendBoneFrame("センター", toBf)
+	}
+
+	fromMf := NewMorphFrame(0)
+	fromMf.Ratio = 1
+	from.AppendMorphFrame("まばたき", fromMf)
+
+	// from は足IKをOFFにしている
+	ikf := NewIkFrame(0)
+	ikEnabledFrame := NewIkEnableFrame(0)
+	ikEnabledFrame.BoneName = "左足ＩＫ"
+	ikEnabledFrame.Enabled = false
+	ikf.IkList = append(ikf.IkList, ikEnabledFrame)
+	from.AppendIkFrame(ikf)
+
+	easing := mmath.NewCurveByValues(64, 0, 64, 127)
+	blended, err := Crossfade(from, to, 100, 130, easing)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	blendedBnf := blended.BoneFrames.Get("センター")
+	fromBnf := from.BoneFrames.Get("センター")
+	toBnf := to.BoneFrames.Get("センター")
+	for fno := float32(0); fno <= 200; fno++ {
+		bf := blendedBnf.Get(fno)
+		switch {
+		case fno <= 100:
+			if !bf.Position.NearEquals(fromBnf.Get(fno).Position, 0.1) {
+				t.Errorf("[%v] Expected position to be %v, got %v", fno, fromBnf.Get(fno).Position, bf.Position)
+			}
+		case fno >= 130:
+			if !bf.Position.NearEquals(toBnf.Get(fno).Position, 1e-4) {
+				t.Errorf("[%v] Expected position to be %v, got %v", fno, toBnf.Get(fno).Position, bf.Position)
+			}
+			if degree := rotationDegree(bf.Rotation, toBnf.Get(fno).Rotation); degree > 1e-2 {
+				t.Errorf("[%v] Expected rotation to be %v, got %v", fno, toBnf.Get(fno).Rotation, bf.Rotation)
+			}
+		}
+	}
+
+	// 窓の中間ではおよそ半分ずつ混ざる(補間曲線は0〜127なので、ちょうど半分にはならない)
+	middleBf := blendedBnf.Get(115)
+	middlePosition := fromBnf.Get(115).Position.Lerp(toBnf.Get(115).Position, 0.5)
+	if !middleBf.Position.NearEquals(middlePosition, 0.1) {
+		t.Errorf("Expected middle position to be %v, got %v", middlePosition, middleBf.Position)
+	}
+	if degree := rotationDegree(middleBf.Rotation, mmath.NewMQuaternionFromDegrees(0, 45, 0)); degree > 1 {
+		t.Errorf("Expected middle rotation to be 45 degrees, got %v", middleBf.Rotation.ToDegrees())
+	}
+
+	// 窓の中では飛ばずに、少しずつ動く
+	for fno := float32(100); fno < 130; fno++ {
+		if distance := blendedBnf.Get(fno).Position.Distance(blendedBnf.Get(fno + 1).Position); distance > 1 {
+			t.Errorf("[%v] Expected position to change smoothly, got %v", fno, distance)
+		}
+	}
+
+	blendedMnf := blended.MorphFrames.Get("まばたき")
+	if ratio := blendedMnf.Get(100).Ratio; math.Abs(ratio-1) > 1e-8 {
+		t.Errorf("Expected ratio to be 1 before window, got %v", ratio)
+	}
+	if ratio := blendedMnf.Get(130).Ratio; math.Abs(ratio) > 1e-8 {
+		t.Errorf("Expected ratio to be 0 after window, got %v", ratio)
+	}
+
+	if blended.IkFrames.Get(100).IsEnable("左足ＩＫ") {
+		t.Errorf("Expected ik to be disabled before window")
+	}
+	if !blended.IkFrames.Get(130).IsEnable("左足ＩＫ") {
+		t.Errorf("Expected ik to be enabled after window")
+	}
+
+	if _, err := Crossfade(from, to, 130, 100, easing); err == nil {
+		t.Errorf("Expected error for reversed window")
+	}
+}
+
+func TestCrossfade_CameraLightShadow(t *testing.T) {
+	from := NewVmdMotion("")
+	to := NewVmdMotion("")
+
+	for _, fno := range []float32{0, 200} {
+		fromCf := NewCameraFrame(fno)
+		fromCf.Position = &mmath.MVec3{X: 0, Y: 10, Z: 0}
+		fromCf.Degrees = &mmath.MVec3{X: 0, Y: mmath.DegToRad(170), Z: 0}
+		fromCf.Distance = -40
+		fromCf.ViewOfAngle = 30
+		fromCf.IsPerspectiveOff = false
+		from.AppendCameraFrame(fromCf)
+
+		toCf := NewCameraFrame(fno)
+		toCf.Position = &mmath.MVec3{X: 10, Y: 10, Z: 0}
+		toCf.Degrees = &mmath.MVec3{X: 0, Y: mmath.DegToRad(190), Z: 0}
+		toCf.Distance = -20
+		toCf.ViewOfAngle = 50
+		toCf.IsPerspectiveOff = true
+		to.AppendCameraFrame(toCf)
+	}
+
+	fromLf := NewLightFrame(0)
+	fromLf.Position = &mmath.MVec3{X: -0.5, Y: -1, Z: 0.5}
+	fromLf.Color = &mmath.MVec3{X: 0.6, Y: 0.6, Z: 0.6}
+	from.AppendLightFrame(fromLf)
+
+	toLf := NewLightFrame(0)
+	toLf.Position = &mmath.MVec3{X: 0.5, Y: -1, Z: 0.5}
+	toLf.Color = &mmath.MVec3{X: 1, Y: 0.2, Z: 0.2}
+	to.AppendLightFrame(toLf)
+
+	// セルフ影は to にしか無い
+	toSf := NewShadowFrame(0)
+	toSf.ShadowMode = 1
+	toSf.Distance = 0.05
+	to.AppendShadowFrame(toSf)
+
+	blended, err := Crossfade(from, to, 100, 130, mmath.NewCurveByValues(20, 20, 107, 107))
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	for _, fno := range []float32{0, 100, 130, 200} {
+		expected := from.CameraFrames.Get(fno)
+		if fno >= 130 {
+			expected = to.CameraFrames.Get(fno)
+		}
+		cf := blended.CameraFrames.Get(fno)
+		if !cf.Position.NearEquals(expected.Position, 1e-4) || math.Abs(cf.Distance-expected.Distance) > 1e-4 ||
+			cf.ViewOfAngle != expected.ViewOfAngle || cf.IsPerspectiveOff != expected.IsPerspectiveOff {
+			t.Errorf("[%v] Expected camera %v, got %v", fno, expected, cf)
+		}
+	}
+
+	// 窓の中間では半分ずつ混ざり、180度をまたいでも回り込まない
+	middleCf := blended.CameraFrames.Get(115)
+	if !middleCf.Position.NearEquals(&mmath.MVec3{X: 5, Y: 10, Z: 0}, 0.5) {
+		t.Errorf("Expected middle camera position to be (5, 10, 0), got %v", middleCf.Position)
+	}
+	blended.CameraFrames.ForEach(func(fno float32, cf *CameraFrame) bool {
+		if degree := mmath.RadToDeg(cf.Degrees.Y); degree < 170-1e-4 || degree > 190+1e-4 {
+			t.Errorf("[%v] Expected camera Y rotation to stay within 170〜190, got %v", fno, degree)
+		}
+		return true
+	})
+
+	if lf := blended.LightFrames.Get(100); !lf.Color.NearEquals(fromLf.Color, 1e-8) {
+		t.Errorf("Expected light color before window to be %v, got %v", fromLf.Color, lf.Color)
+	}
+	if lf := blended.LightFrames.Get(130); !lf.Color.NearEquals(toLf.Color, 1e-8) {
+		t.Errorf("Expected light color after window to be %v, got %v", toLf.Color, lf.Color)
+	}
+
+	if sf := blended.ShadowFrames.Get(0); sf.ShadowMode != 1 || sf.Distance != 0.05 {
+		t.Errorf("Expected shadow of to to be kept, got %v %v", sf.ShadowMode, sf.Distance)
+	}
+}
@@ -56,10 +56,8 @@ func (ikf *IkFrame) Copy() IBaseFrame {
 func (nextIkf *IkFrame) lerpFrame(prevFrame IBaseFrame, index float32) IBaseFrame {
 	prevIkf := prevFrame.(*IkFrame)
 	// 補間なしで前のキーフレを引き継ぐ
-	vv := &IkFrame{
-		Visible: prevIkf.Visible,
-		IkList:  make([]*IkEnabledFrame, 0, len(prevIkf.IkList)),
-	}
+	vv := NewIkFrame(index)
+	vv.Visible = prevIkf.Visible
 	for _, v := range prevIkf.IkList {
 		vv.IkList = append(vv.IkList, v.Copy())
 	}
@@ -1,6 +1,10 @@
 package vmd
 
-import "github.com/tiendc/go-deepcopy"
+import (
+	"math"
+
+	"github.com/tiendc/go-deepcopy"
+)
 
 type IkFrames struct {
 	*BaseFrames[*IkFrame]
@@ -28,6 +32,20 @@ func (ikFrames *IkFrames) Clean() {
 	}
 }
 
+// Warp キーフレームの時間を warp で移したIKキーフレーム
+// IKのON/OFFは補間しないので、切り替わった時間以降の最初の整数フレームに移す
+// 0フレームより前に移ったキーフレームは0フレームに置き、後のキーフレームで上書きする
+func (ikFrames *IkFrames) Warp(warp *TimeWarp) *IkFrames {
+	warped := NewIkFrames()
+	ikFrames.ForEach(func(index float32, ikf *IkFrame) bool {
+		warpedIkf := ikf.Copy().(*IkFrame)
+		warpedIkf.SetIndex(float32(max(math.Ceil(warp.Frame(float64(index))-1e-4), 0)))
+		warped.Append(warpedIkf)
+		return true
+	})
+	return warped
+}
+
 func (ikFrames *IkFrames) Copy() (*IkFrames, error) {
 	copied := new(IkFrames)
 	err := deepcopy.Copy(copied, ikFrames)
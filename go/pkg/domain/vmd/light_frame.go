@@ -34,8 +34,8 @@ func (lf *LightFrame) Added(v *LightFrame) *LightFrame {
 
 func (lf *LightFrame) Copy() IBaseFrame {
 	copied := NewLightFrame(lf.Index())
-	copied.Position = lf.Position
-	copied.Color = lf.Color
+	copied.Position = lf.Position.Copy()
+	copied.Color = lf.Color.Copy()
 
 	return copied
 }
@@ -43,12 +43,11 @@ func (lf *LightFrame) Copy() IBaseFrame {
 func (nextLf *LightFrame) lerpFrame(prevFrame IBaseFrame, index float32) IBaseFrame {
 	prevLf := prevFrame.(*LightFrame)
 	// 線形補間
-	t := float64(nextLf.Index()-index) / float64(nextLf.Index()-prevLf.Index())
-	vv := &LightFrame{
-		Position: prevLf.Position.Lerp(nextLf.Position, t),
-		Color:    prevLf.Color.Lerp(nextLf.Color, t),
-	}
-	return vv
+	t := float64(index-prevLf.Index()) / float64(nextLf.Index()-prevLf.Index())
+	lf := NewLightFrame(index)
+	lf.Position = prevLf.Position.Lerp(nextLf.Position, t)
+	lf.Color = prevLf.Color.Lerp(nextLf.Color, t)
+	return lf
 }
 
 func (lf *LightFrame) splitCurve(prevFrame IBaseFrame, nextFrame IBaseFrame, index float32) {
@@ -0,0 +1,50 @@
+package vmd
+
+import (
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+)
+
+func TestLightFrame_Copy(t *testing.T) {
+	lf := NewLightFrame(10)
+	lf.Position = &mmath.MVec3{X: 1, Y: 2, Z: 3}
+	lf.Color = &mmath.MVec3{X: 0.5, Y: 0.6, Z: 0.7}
+
+	copied := lf.Copy().(*LightFrame)
+	copied.Position.X = 10
+	copied.Color.X = 1
+
+	if copied.Index() != 10 {
+		t.Errorf("Expected index to be 10, got %v", copied.Index())
+	}
+	if lf.Position.X != 1 || lf.Color.X != 0.5 {
+		t.Errorf("Expected original to be unchanged, got %v %v", lf.Position, lf.Color)
+	}
+}
+
+func TestLightFrames_Get(t *testing.T) {
+	lightFrames := NewLightFrames()
+
+	prevLf := NewLightFrame(0)
+	prevLf.Position = &mmath.MVec3{X: 0, Y: 0, Z: 0}
+	prevLf.Color = &mmath.MVec3{X: 0, Y: 0, Z: 0}
+	lightFrames.Append(prevLf)
+
+	nextLf := NewLightFrame(10)
+	nextLf.Position = &mmath.MVec3{X: 10, Y: -10, Z: 0}
+	nextLf.Color = &mmath.MVec3{X: 1, Y: 1, Z: 1}
+	lightFrames.Append(nextLf)
+
+	// 前のキーフレームに近い時間は、前のキーフレームに近い値になる
+	lf := lightFrames.Get(2)
+	if lf.Index() != 2 {
+		t.Errorf("Expected index to be 2, got %v", lf.Index())
+	}
+	if !lf.Position.NearEquals(&mmath.MVec3{X: 2, Y: -2, Z: 0}, 1e-8) {
+		t.Errorf("Expected position to be (2, -2, 0), got %v", lf.Position)
+	}
+	if !lf.Color.NearEquals(&mmath.MVec3{X: 0.2, Y: 0.2, Z: 0.2}, 1e-8) {
+		t.Errorf("Expected color to be (0.2, 0.2, 0.2), got %v", lf.Color)
+	}
+}
@@ -29,6 +29,13 @@ func (lightFrames *LightFrames) Clean() {
 	}
 }
 
+// Warp キーフレームの時間を warp で移した照明キーフレーム
+func (lightFrames *LightFrames) Warp(warp *TimeWarp) *LightFrames {
+	warped := NewLightFrames()
+	lightFrames.warpTo(warped.BaseFrames, warp)
+	return warped
+}
+
 func (lightFrames *LightFrames) Copy() (*LightFrames, error) {
 	copied := new(LightFrames)
 	err := deepcopy.Copy(copied, lightFrames)
@@ -0,0 +1,141 @@
+package vmd
+
+import (
+	"strings"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
+)
+
+// mirrorDirections 左右反転で入れ替える方向
+var mirrorDirections = []pmx.BoneDirection{pmx.BONE_DIRECTION_LEFT, pmx.BONE_DIRECTION_RIGHT}
+
+// mirrorMorphNames 名前に左右が入っていない、左右非対称のモーフの組
+var mirrorMorphNames = [][2]string{
+	{"ウィンク", "ウィンク右"},
+	{"ウィンク２", "ｳｨﾝｸ２右"},
+}
+
+// MirrorBoneName 左右反対のボーン名
+// 準標準ボーンは反対の方向の標準ボーン名、それ以外は先頭か末尾の「左」「右」を入れ替える
+func MirrorBoneName(name string) string {
+	if pmx.BoneConfigFromName(name) != nil {
+		for _, direction := range mirrorDirections {
+			for standardName := range pmx.GetStandardBoneConfigs() {
+				if strings.Contains(string(standardName), pmx.BONE_DIRECTION_PREFIX) &&
+					standardName.StringFromDirection(direction) == name {
+					return standardName.StringFromDirection(direction.Opposite())
+				}
+			}
+		}
+	}
+
+	return mirrorDirectionName(name)
+}
+
+// MirrorMorphName 左右反対のモーフ名
+func MirrorMorphName(name string) string {
+	for _, names := range mirrorMorphNames {
+		switch name {
+		case names[0]:
+			return names[1]
+		case names[1]:
+			return names[0]
+		}
+	}
+
+	return mirrorDirectionName(name)
+}
+
+// mirrorDirectionName 先頭か末尾の「左」「右」を入れ替えた名前
+func mirrorDirectionName(name string) string {
+	for _, direction := range mirrorDirections {
+		opposite := direction.Opposite().String()
+		if rest, ok := strings.CutPrefix(name, direction.String()); ok {
+			return opposite + rest
+		}
+		if rest, ok := strings.CutSuffix(name, direction.String()); ok {
+			return rest + opposite
+		}
+	}
+
+	return name
+}
+
+// Mirrored 左右反転したモーション
+// 左右のボーン・モーフ・IKを入れ替え、YZ平面で反転する(位置はXを反転し、回転はY・Z軸回りを逆回りにする)
+func (motion *VmdMotion) Mirrored() (*VmdMotion, error) {
+	mirrored, err := motion.Copy()
+	if err != nil {
+		return nil, err
+	}
+
+	mirrored.BoneFrames = NewBoneFrames()
+	motion.BoneFrames.ForEach(func(boneName string, boneNameFrames *BoneNameFrames) {
+		mirroredBnf := NewBoneNameFrames(MirrorBoneName(boneName))
+		boneNameFrames.ForEach(func(index float32, bf *BoneFrame) bool {
+			mirroredBf := bf.Copy().(*BoneFrame)
+			if mirroredBf.Position != nil {
+				mirroredBf.Position = mirrorPosition(mirroredBf.Position)
+			}
+			if mirroredBf.Rotation != nil {
+				mirroredBf.Rotation = mirrorRotation(mirroredBf.Rotation)
+			}
+			mirroredBnf.Append(mirroredBf)
+			return true
+		})
+		mirrored.BoneFrames.Update(mirroredBnf)
+	})
+
+	mirrored.MorphFrames = NewMorphFrames()
+	motion.MorphFrames.ForEach(func(morphName string, morphNameFrames *MorphNameFrames) {
+		mirroredMnf := NewMorphNameFrames(MirrorMorphName(morphName))
+		morphNameFrames.ForEach(func(index float32, mf *MorphFrame) bool {
+			mirroredMnf.Append(mf.Copy().(*MorphFrame))
+			return true
+		})
+		mirrored.MorphFrames.Update(mirroredMnf)
+	})
+
+	mirrored.CameraFrames = NewCameraFrames()
+	motion.CameraFrames.ForEach(func(index float32, cf *CameraFrame) bool {
+		mirroredCf := cf.Copy().(*CameraFrame)
+		mirroredCf.Position = mirrorPosition(mirroredCf.Position)
+		mirroredCf.Degrees = &mmath.MVec3{X: cf.Degrees.X, Y: -cf.Degrees.Y, Z: -cf.Degrees.Z}
+		if cf.Quaternion != nil {
+			mirroredCf.Quaternion = mirrorRotation(cf.Quaternion)
+		}
+		mirrored.AppendCameraFrame(mirroredCf)
+		return true
+	})
+
+	mirrored.LightFrames = NewLightFrames()
+	motion.LightFrames.ForEach(func(index float32, lf *LightFrame) bool {
+		mirroredLf := lf.Copy().(*LightFrame)
+		mirroredLf.Position = mirrorPosition(mirroredLf.Position)
+		mirrored.AppendLightFrame(mirroredLf)
+		return true
+	})
+
+	mirrored.IkFrames = NewIkFrames()
+	motion.IkFrames.ForEach(func(index float32, ikf *IkFrame) bool {
+		mirroredIkf := ikf.Copy().(*IkFrame)
+		for _, ik := range mirroredIkf.IkList {
+			ik.BoneName = MirrorBoneName(ik.BoneName)
+		}
+		mirrored.AppendIkFrame(mirroredIkf)
+		return true
+	})
+
+	return mirrored, nil
+}
+
+// mirrorPosition YZ平面で反転した位置
+func mirrorPosition(position *mmath.MVec3) *mmath.MVec3 {
+	return &mmath.MVec3{X: -position.X, Y: position.Y, Z: position.Z}
+}
+
+// mirrorRotation YZ平面で反転した回転(X軸回りはそのまま、Y・Z軸回りは逆回り)
+func mirrorRotation(rotation *mmath.MQuaternion) *mmath.MQuaternion {
+	return mmath.NewMQuaternionByValues(rotation.X, -rotation.Y, -rotation.Z, rotation.W)
+}
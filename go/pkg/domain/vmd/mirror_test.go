@@ -0,0 +1,124 @@
+package vmd
+
+import (
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+)
+
+func TestMirrorBoneName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"左腕", "右腕"},
+		{"右足ＩＫ", "左足ＩＫ"},
+		{"左親指０", "右親指０"},
+		{"右足先EX", "左足先EX"},
+		{"腰キャンセル右", "腰キャンセル左"},
+		{"センター", "センター"},
+		{"両目", "両目"},
+		{"左髪1", "右髪1"},
+	}
+	for _, test := range tests {
+		if name := MirrorBoneName(test.name); name != test.expected {
+			t.Errorf("Expected %s to be mirrored to %s, got %s", test.name, test.expected, name)
+		}
+	}
+}
+
+func TestMirrorMorphName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"ウィンク", "ウィンク右"},
+		{"ウィンク右", "ウィンク"},
+		{"ウィンク２", "ｳｨﾝｸ２右"},
+		{"ｳｨﾝｸ２右", "ウィンク２"},
+		{"まばたき", "まばたき"},
+		{"眉左", "眉右"},
+	}
+	for _, test := range tests {
+		if name := MirrorMorphName(test.name); name != test.expected {
+			t.Errorf("Expected %s to be mirrored to %s, got %s", test.name, test.expected, name)
+		}
+	}
+}
+
+func TestVmdMotion_Mirrored(t *testing.T) {
+	motion := NewVmdMotion("")
+
+	centerBf := NewBoneFrame(0)
+	centerBf.Position = &mmath.MVec3{X: 1, Y: 2, Z: 3}
+	centerBf.Rotation = mmath.NewMQuaternionFromDegrees(10, 20, 30)
+	motion.AppendBoneFrame("センター", centerBf)
+
+	armBf := NewBoneFrame(10)
+	armBf.Rotation = mmath.NewMQuaternionFromDegrees(0, 0, 30)
+	motion.AppendBoneFrame("左腕", armBf)
+
+	legIkBf := NewBoneFrame(10)
+	legIkBf.Position = &mmath.MVec3{X: 0.5, Y: 1, Z: -1}
+	motion.AppendBoneFrame("左足ＩＫ", legIkBf)
+
+	mf := NewMorphFrame(5)
+	mf.Ratio = 0.8
+	motion.AppendMorphFrame("ウィンク", mf)
+
+	cf := NewCameraFrame(0)
+	cf.Position = &mmath.MVec3{X: 2, Y: 10, Z: 0}
+	cf.Degrees = &mmath.MVec3{X: 0.1, Y: 0.2, Z: 0.3}
+	cf.Distance = -45
+	motion.AppendCameraFrame(cf)
+
+	ikf := NewIkFrame(0)
+	ikEnabledFrame := NewIkEnableFrame(0)
+	ikEnabledFrame.BoneName = "左足ＩＫ"
+	ikEnabledFrame.Enabled = false
+	ikf.IkList = append(ikf.IkList, ikEnabledFrame)
+	motion.AppendIkFrame(ikf)
+
+	mirrored, err := motion.Mirrored()
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	mirroredCenterBf := mirrored.BoneFrames.Get("センター").Get(0)
+	if !mirroredCenterBf.Position.NearEquals(&mmath.MVec3{X: -1, Y: 2, Z: 3}, 1e-8) {
+		t.Errorf("Expected center position x to be negated, got %v", mirroredCenterBf.Position)
+	}
+	if !mirroredCenterBf.Rotation.NearEquals(mmath.NewMQuaternionFromDegrees(10, -20, -30), 1e-8) {
+		t.Errorf("Expected center rotation to be mirrored, got %v", mirroredCenterBf.Rotation.ToDegrees())
+	}
+
+	if mirrored.BoneFrames.Contains("左腕") || !mirrored.BoneFrames.Get("右腕").Contains(10) {
+		t.Errorf("Expected 左腕 to be swapped to 右腕")
+	}
+	if rotation := mirrored.BoneFrames.Get("右腕").Get(10).Rotation; !rotation.NearEquals(mmath.NewMQuaternionFromDegrees(0, 0, -30), 1e-8) {
+		t.Errorf("Expected arm rotation to be mirrored, got %v", rotation.ToDegrees())
+	}
+	if position := mirrored.BoneFrames.Get("右足ＩＫ").Get(10).Position; !position.NearEquals(&mmath.MVec3{X: -0.5, Y: 1, Z: -1}, 1e-8) {
+		t.Errorf("Expected leg ik position to be mirrored, got %v", position)
+	}
+
+	if !mirrored.MorphFrames.Contains("ウィンク右") || mirrored.MorphFrames.Get("ウィンク右").Get(5).Ratio != 0.8 {
+		t.Errorf("Expected ウィンク to be swapped to ウィンク右")
+	}
+
+	mirroredCf := mirrored.CameraFrames.Get(0)
+	if !mirroredCf.Position.NearEquals(&mmath.MVec3{X: -2, Y: 10, Z: 0}, 1e-8) ||
+		!mirroredCf.Degrees.NearEquals(&mmath.MVec3{X: 0.1, Y: -0.2, Z: -0.3}, 1e-8) {
+		t.Errorf("Expected camera to be mirrored, got %v %v", mirroredCf.Position, mirroredCf.Degrees)
+	}
+
+	mirroredIkf := mirrored.IkFrames.Get(0)
+	if !mirroredIkf.IsEnable("左足ＩＫ") || mirroredIkf.IsEnable("右足ＩＫ") {
+		t.Errorf("Expected ik off to be swapped to 右足ＩＫ")
+	}
+
+	// 元のモーションは変わらない
+	if !motion.BoneFrames.Get("センター").Get(0).Position.NearEquals(&mmath.MVec3{X: 1, Y: 2, Z: 3}, 1e-8) {
+		t.Errorf("Expected original motion to be unchanged")
+	}
+}
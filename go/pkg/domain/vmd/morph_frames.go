@@ -148,6 +148,26 @@ func (morphFrames *MorphFrames) Clean() {
 	}
 }
 
+// Reduce モーフごとに線形補間で近似してキーフレームを間引く
+func (morphFrames *MorphFrames) Reduce(maxRatioError float64) *MorphFrames {
+	reduced := NewMorphFrames()
+	for _, morphNameFrames := range morphFrames.values {
+		reduced.Update(morphNameFrames.Reduce(maxRatioError))
+	}
+	return reduced
+}
+
+// Warp キーフレームの時間を warp で移したモーフキーフレーム
+func (morphFrames *MorphFrames) Warp(warp *TimeWarp) *MorphFrames {
+	warped := NewMorphFrames()
+	for _, morphNameFrames := range morphFrames.values {
+		warpedMnf := NewMorphNameFrames(morphNameFrames.Name)
+		morphNameFrames.warpTo(warpedMnf.BaseFrames, warp)
+		warped.Update(warpedMnf)
+	}
+	return warped
+}
+
 func (morphFrames *MorphFrames) ForEach(fn func(morphName string, morphNameFrames *MorphNameFrames)) {
 	for _, morphName := range morphFrames.getNames() {
 		fn(morphName, morphFrames.Get(morphName))
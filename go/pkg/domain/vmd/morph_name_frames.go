@@ -1,6 +1,8 @@
 package vmd
 
 import (
+	"math"
+
 	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
 	"github.com/tiendc/go-deepcopy"
 )
@@ -55,6 +57,52 @@ func (morphNameFrames *MorphNameFrames) ContainsActive() bool {
 	return isActive
 }
 
+// Reduce 線形補間で maxRatioError 以内に近似できる範囲でキーフレームを間引く
+// モーフには補間曲線が無いので、間引いたキーフレーム間を線形補間した値で検算する
+func (morphNameFrames *MorphNameFrames) Reduce(maxRatioError float64) *MorphNameFrames {
+	if morphNameFrames.Length() <= 2 {
+		return morphNameFrames
+	}
+
+	minIFrame := int(morphNameFrames.MinFrame())
+	maxIFrame := int(morphNameFrames.MaxFrame())
+
+	// 全フレームの値を展開する
+	mfs := make([]*MorphFrame, 0, maxIFrame-minIFrame+1)
+	for iF := minIFrame; iF <= maxIFrame; iF++ {
+		mf := NewMorphFrame(float32(iF))
+		mf.Ratio = morphNameFrames.Get(float32(iF)).Ratio
+		mfs = append(mfs, mf)
+	}
+
+	reduceMfs := NewMorphNameFrames(morphNameFrames.Name)
+	reduceMfs.Append(mfs[0].Copy().(*MorphFrame))
+
+	lastI := len(mfs) - 1
+	for startI := 0; startI < lastI; {
+		endI, _ := reduceEndIndex(startI, lastI, func(endI int) (struct{}, bool) {
+			return struct{}{}, isLinearMorph(mfs, startI, endI, maxRatioError)
+		})
+
+		reduceMfs.Append(mfs[endI].Copy().(*MorphFrame))
+
+		startI = endI
+	}
+
+	return reduceMfs
+}
+
+// isLinearMorph startI〜endI の間を線形補間した値が、元の値から許容差以内に収まっているか
+func isLinearMorph(mfs []*MorphFrame, startI, endI int, maxRatioError float64) bool {
+	for i := startI + 1; i < endI; i++ {
+		mf := mfs[endI].lerpFrame(mfs[startI], mfs[i].Index()).(*MorphFrame)
+		if math.Abs(mf.Ratio-mfs[i].Ratio) > maxRatioError {
+			return false
+		}
+	}
+	return true
+}
+
 func (morphNameFrames *MorphNameFrames) Copy() (*MorphNameFrames, error) {
 	copied := new(MorphNameFrames)
 	err := deepcopy.Copy(copied, morphNameFrames)
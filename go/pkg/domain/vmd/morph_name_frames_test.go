@@ -0,0 +1,42 @@
+package vmd
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMorphNameFrames_Reduce(t *testing.T) {
+	morphNameFrames := NewMorphNameFrames("あ")
+
+	// 0〜10 で開いて、10〜30 で閉じて、30〜60 は閉じたまま
+	for i := 0; i <= 60; i++ {
+		mf := NewMorphFrame(float32(i))
+		switch {
+		case i <= 10:
+			mf.Ratio = float64(i) * 0.1
+		case i <= 30:
+			mf.Ratio = 1 - float64(i-10)*0.05
+		}
+		morphNameFrames.Append(mf)
+	}
+
+	maxRatioError := 0.01
+	reduced := morphNameFrames.Reduce(maxRatioError)
+
+	expectedFrames := []float32{0, 10, 30, 60}
+	if reduced.Length() != len(expectedFrames) {
+		t.Errorf("Expected %d frames, got %d", len(expectedFrames), reduced.Length())
+	}
+	for _, fno := range expectedFrames {
+		if !reduced.Contains(fno) {
+			t.Errorf("Expected frame %v to be contained", fno)
+		}
+	}
+
+	for i := 0; i <= 60; i++ {
+		ratio := morphNameFrames.Get(float32(i)).Ratio
+		if reducedRatio := reduced.Get(float32(i)).Ratio; math.Abs(ratio-reducedRatio) > maxRatioError {
+			t.Errorf("[%d] Expected ratio to be %v, got %v", i, ratio, reducedRatio)
+		}
+	}
+}
@@ -17,11 +17,10 @@ func NewShadowFrame(index float32) *ShadowFrame {
 }
 
 func (sf *ShadowFrame) Copy() IBaseFrame {
-	vv := &ShadowFrame{
-		ShadowMode: sf.ShadowMode,
-		Distance:   sf.Distance,
-	}
-	return vv
+	copied := NewShadowFrame(sf.Index())
+	copied.ShadowMode = sf.ShadowMode
+	copied.Distance = sf.Distance
+	return copied
 }
 
 func (nextSf *ShadowFrame) lerpFrame(prevFrame IBaseFrame, index float32) IBaseFrame {
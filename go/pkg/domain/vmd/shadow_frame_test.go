@@ -0,0 +1,27 @@
+package vmd
+
+import (
+	"testing"
+)
+
+func TestShadowFrame_Copy(t *testing.T) {
+	sf := NewShadowFrame(10)
+	sf.ShadowMode = 1
+	sf.Distance = 0.05
+
+	copied := sf.Copy().(*ShadowFrame)
+
+	if copied.Index() != 10 {
+		t.Errorf("Expected index to be 10, got %v", copied.Index())
+	}
+	if copied.ShadowMode != 1 || copied.Distance != 0.05 {
+		t.Errorf("Expected shadow mode 1 and distance 0.05, got %v %v", copied.ShadowMode, copied.Distance)
+	}
+
+	// キーフレームとしてコピーしたものを登録できる
+	shadowFrames := NewShadowFrames()
+	shadowFrames.Append(copied)
+	if !shadowFrames.Contains(10) {
+		t.Errorf("Expected copied frame to be registered at 10")
+	}
+}
@@ -28,6 +28,13 @@ func (shadowFrames *ShadowFrames) Clean() {
 	}
 }
 
+// Warp キーフレームの時間を warp で移したセルフ影キーフレーム
+func (shadowFrames *ShadowFrames) Warp(warp *TimeWarp) *ShadowFrames {
+	warped := NewShadowFrames()
+	shadowFrames.warpTo(warped.BaseFrames, warp)
+	return warped
+}
+
 func (shadowFrames *ShadowFrames) Copy() (*ShadowFrames, error) {
 	copied := new(ShadowFrames)
 	err := deepcopy.Copy(copied, shadowFrames)
@@ -0,0 +1,59 @@
+package vmd
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// TimeWarp 元のフレームから変換後のフレームへの区分線形の対応
+// 最初と最後の対応点より外側は、端の区間の傾きのまま延長する
+type TimeWarp struct {
+	oldFrames []float64 // 元のフレーム(昇順)
+	newFrames []float64 // 変換後のフレーム(昇順)
+}
+
+// NewTimeWarp 元のフレームと変換後のフレームの対応点から区分線形の対応を作る
+// 対応点は2つ以上で、どちらのフレームも狭義単調増加である必要がある
+func NewTimeWarp(oldFrames, newFrames []float64) (*TimeWarp, error) {
+	if len(oldFrames) < 2 || len(oldFrames) != len(newFrames) {
+		return nil, fmt.Errorf("time warp needs at least 2 pairs of frames: old %d, new %d",
+			len(oldFrames), len(newFrames))
+	}
+	for i := 1; i < len(oldFrames); i++ {
+		if oldFrames[i] <= oldFrames[i-1] || newFrames[i] <= newFrames[i-1] {
+			return nil, fmt.Errorf("time warp frames must be increasing: %v:%v -> %v:%v",
+				oldFrames[i-1], newFrames[i-1], oldFrames[i], newFrames[i])
+		}
+	}
+
+	return &TimeWarp{
+		oldFrames: slices.Clone(oldFrames),
+		newFrames: slices.Clone(newFrames),
+	}, nil
+}
+
+// NewRateTimeWarp 一定の速さ rate 倍で再生する対応(2 なら倍速になり、フレーム数は半分になる)
+func NewRateTimeWarp(rate float64) *TimeWarp {
+	return &TimeWarp{
+		oldFrames: []float64{0, 1},
+		newFrames: []float64{0, 1 / rate},
+	}
+}
+
+// Frame 元のフレームに当たる変換後のフレーム
+func (warp *TimeWarp) Frame(oldFrame float64) float64 {
+	return piecewiseLinear(warp.oldFrames, warp.newFrames, oldFrame)
+}
+
+// OldFrame 変換後のフレームに当たる元のフレーム
+func (warp *TimeWarp) OldFrame(newFrame float64) float64 {
+	return piecewiseLinear(warp.newFrames, warp.oldFrames, newFrame)
+}
+
+// piecewiseLinear 対応点 (xs, ys) を結ぶ折れ線の x での値
+func piecewiseLinear(xs, ys []float64, x float64) float64 {
+	i := min(max(sort.SearchFloat64s(xs, x), 1), len(xs)-1)
+	t := (x - xs[i-1]) / (xs[i] - xs[i-1])
+	return ys[i-1] + (ys[i]-ys[i-1])*t
+}
@@ -0,0 +1,133 @@
+package vmd
+
+import (
+	"math"
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+)
+
+func TestTimeWarp_Frame(t *testing.T) {
+	warp, err := NewTimeWarp([]float64{0, 50, 100}, []float64{0, 50, 150})
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	tests := []struct {
+		oldFrame float64
+		newFrame float64
+	}{
+		{0, 0},
+		{25, 25},
+		{75, 100},
+		{100, 150},
+		{110, 170}, // 最後の区間の傾きのまま延長する
+		{-10, -10},
+	}
+	for _, test := range tests {
+		if newFrame := warp.Frame(test.oldFrame); math.Abs(newFrame-test.newFrame) > 1e-8 {
+			t.Errorf("Expected frame %v to be %v, got %v", test.oldFrame, test.newFrame, newFrame)
+		}
+		if oldFrame := warp.OldFrame(test.newFrame); math.Abs(oldFrame-test.oldFrame) > 1e-8 {
+			t.Errorf("Expected old frame of %v to be %v, got %v", test.newFrame, test.oldFrame, oldFrame)
+		}
+	}
+
+	if _, err := NewTimeWarp([]float64{0, 50, 40}, []float64{0, 50, 150}); err == nil {
+		t.Errorf("Expected error for decreasing frames")
+	}
+	if _, err := NewTimeWarp([]float64{0}, []float64{0}); err == nil {
+		t.Errorf("Expected error for single pair")
+	}
+}
+
+func TestVmdMotion_Warped(t *testing.T) {
+	motion := NewVmdMotion("")
+
+	// 0〜100フレームで線形に動くモーション
+	for _, fno := range []float32{0, 100} {
+		bf := NewBoneFrame(fno)
+		bf.Position = &mmath.MVec3{X: float64(fno), Y: 0, Z: 0}
+		bf.Rotation = mmath.NewMQuaternionFromDegrees(0, float64(fno)*0.5, 0)
+		motion.AppendBoneFrame("センター", bf)
+
+		mf := NewMorphFrame(fno)
+		mf.Ratio = float64(fno) * 0.01
+		motion.AppendMorphFrame("まばたき", mf)
+
+		cf := NewCameraFrame(fno)
+		cf.Position = &mmath.MVec3{X: 0, Y: float64(fno), Z: 0}
+		cf.Distance = -45
+		cf.ViewOfAngle = 30
+		motion.AppendCameraFrame(cf)
+
+		lf := NewLightFrame(fno)
+		lf.Position = &mmath.MVec3{X: float64(fno) * 0.01, Y: 0, Z: 0}
+		lf.Color = &mmath.MVec3{X: 0.6, Y: 0.6, Z: 0.6}
+		motion.AppendLightFrame(lf)
+
+		sf := NewShadowFrame(fno)
+		sf.Distance = float64(fno)
+		motion.AppendShadowFrame(sf)
+	}
+
+	ikf := NewIkFrame(75)
+	motion.AppendIkFrame(ikf)
+
+	// 後半だけ半分の速さにする
+	warp, err := NewTimeWarp([]float64{0, 50, 100}, []float64{0, 50, 150})
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	warped, err := motion.Warped(warp)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	if warped.MaxFrame() != 150 {
+		t.Errorf("Expected max frame to be 150, got %v", warped.MaxFrame())
+	}
+
+	warpedBnf := warped.BoneFrames.Get("センター")
+	if !warpedBnf.Contains(50) {
+		t.Errorf("Expected key frame at the speed change")
+	}
+
+	for fno := 0; fno <= 150; fno++ {
+		oldFrame := warp.OldFrame(float64(fno))
+
+		bf := warpedBnf.Get(float32(fno))
+		if !bf.Position.NearEquals(&mmath.MVec3{X: oldFrame, Y: 0, Z: 0}, 1e-2) {
+			t.Errorf("[%d] Expected position x to be %v, got %v", fno, oldFrame, bf.Position)
+		}
+		if degree := rotationDegree(bf.Rotation, mmath.NewMQuaternionFromDegrees(0, oldFrame*0.5, 0)); degree > 0.1 {
+			t.Errorf("[%d] Expected rotation error to be within 0.1, got %v", fno, degree)
+		}
+
+		if ratio := warped.MorphFrames.Get("まばたき").Get(float32(fno)).Ratio; math.Abs(ratio-oldFrame*0.01) > 1e-4 {
+			t.Errorf("[%d] Expected ratio to be %v, got %v", fno, oldFrame*0.01, ratio)
+		}
+
+		if cf := warped.CameraFrames.Get(float32(fno)); math.Abs(cf.Position.Y-oldFrame) > 1e-2 {
+			t.Errorf("[%d] Expected camera position y to be %v, got %v", fno, oldFrame, cf.Position.Y)
+		}
+
+		if lf := warped.LightFrames.Get(float32(fno)); math.Abs(lf.Position.X-oldFrame*0.01) > 1e-4 {
+			t.Errorf("[%d] Expected light position x to be %v, got %v", fno, oldFrame*0.01, lf.Position.X)
+		}
+
+		if sf := warped.ShadowFrames.Get(float32(fno)); math.Abs(sf.Distance-oldFrame) > 1e-4 {
+			t.Errorf("[%d] Expected shadow distance to be %v, got %v", fno, oldFrame, sf.Distance)
+		}
+	}
+
+	if !warped.IkFrames.Contains(100) || warped.IkFrames.Length() != 1 {
+		t.Errorf("Expected ik frame to be moved to 100")
+	}
+
+	// 元のモーションは変わらない
+	if motion.BoneFrames.Get("センター").Contains(50) || motion.MaxFrame() != 100 {
+		t.Errorf("Expected original motion to be unchanged")
+	}
+}
@@ -38,6 +38,9 @@ type VmdMotion struct {
 	lock                       sync.Mutex                  // スレッドセーフ用のロック
 }
 
+// VMD_FPS MMDの再生フレームレート
+const VMD_FPS = 30.0
+
 var InitialMotion = NewVmdMotion("")
 
 func NewVmdMotion(path string) *VmdMotion {
@@ -284,3 +287,21 @@ func (motion *VmdMotion) Copy() (*VmdMotion, error) {
 
 	return copied, err
 }
+
+// Warped ボーン・モーフ・カメラ・照明・セルフ影・IKのキーフレームの時間を warp で移したモーション
+// 物理・風のキーフレームはそのまま残す
+func (motion *VmdMotion) Warped(warp *TimeWarp) (*VmdMotion, error) {
+	warped, err := motion.Copy()
+	if err != nil {
+		return nil, err
+	}
+
+	warped.BoneFrames = motion.BoneFrames.Warp(warp)
+	warped.MorphFrames = motion.MorphFrames.Warp(warp)
+	warped.CameraFrames = motion.CameraFrames.Warp(warp)
+	warped.LightFrames = motion.LightFrames.Warp(warp)
+	warped.ShadowFrames = motion.ShadowFrames.Warp(warp)
+	warped.IkFrames = motion.IkFrames.Warp(warp)
+
+	return warped, nil
+}
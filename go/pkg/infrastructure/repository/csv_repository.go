@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/japanese"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mi18n"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mproc"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/core"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mcsv"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/infrastructure/mfile"
+)
+
+// UTF-8 BOM (表計算ソフトで文字化けしないように付与する)
+var utf8Bom = []byte{0xEF, 0xBB, 0xBF}
+
+type CsvRepository struct {
+	*baseRepository[*mcsv.CsvModel]
+}
+
+func NewCsvRepository() *CsvRepository {
+	return &CsvRepository{
+		baseRepository: &baseRepository[*mcsv.CsvModel]{
+			newFunc: func(path string) *mcsv.CsvModel {
+				model := mcsv.NewCsvModel(make([][]string, 0))
+				model.SetPath(path)
+				return model
+			},
+		},
+	}
+}
+
+// Save CSVをBOM付きUTF-8で保存する
+func (rep *CsvRepository) Save(overridePath string, data core.IHashModel, includeSystem bool) error {
+	mproc.SetMaxProcess(true)
+	defer mproc.SetMaxProcess(false)
+
+	model := data.(*mcsv.CsvModel)
+
+	path := model.Path()
+	// 保存可能なパスである場合、上書き
+	if mfile.CanSave(overridePath) {
+		path = overridePath
+	}
+
+	mlog.IL("%s", mi18n.T("保存開始", map[string]interface{}{"Type": "Csv", "Path": path}))
+	defer mlog.I("%s", mi18n.T("保存終了", map[string]interface{}{"Type": "Csv"}))
+
+	fout, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fout.Write(utf8Bom); err != nil {
+		fout.Close()
+		return err
+	}
+
+	writer := csv.NewWriter(fout)
+	if err := writer.WriteAll(model.Records()); err != nil {
+		fout.Close()
+		return err
+	}
+
+	// 書き込みの失敗は Close で初めて分かる場合があるので、エラーを返す
+	return fout.Close()
+}
+
+func (rep *CsvRepository) CanLoad(path string) (bool, error) {
+	if isExist, err := mfile.ExistsFile(path); err != nil || !isExist {
+		return false, fmt.Errorf("%s", mi18n.T("ファイル存在エラー", map[string]interface{}{"Path": path}))
+	}
+
+	_, _, ext := mfile.SplitPath(path)
+	if strings.ToLower(ext) != ".csv" {
+		return false, fmt.Errorf("%s", mi18n.T("拡張子エラー", map[string]interface{}{"Path": path, "Ext": ".csv"}))
+	}
+
+	return true, nil
+}
+
+// 指定されたパスのファイルからデータを読み込む
+// BOM付き/無しのUTF-8と、表計算ソフトで保存し直されたShift-JISを受け付ける
+func (rep *CsvRepository) Load(path string) (core.IHashModel, error) {
+	mproc.SetMaxProcess(true)
+	defer mproc.SetMaxProcess(false)
+
+	mlog.IL("%s", mi18n.T("読み込み開始", map[string]interface{}{"Type": "Csv", "Path": path}))
+	defer mlog.I("%s", mi18n.T("読み込み終了", map[string]interface{}{"Type": "Csv"}))
+
+	model := rep.newFunc(path)
+
+	err := rep.open(path)
+	if err != nil {
+		mlog.E("Load.Open error: %v", err)
+		return model, err
+	}
+	defer rep.close()
+
+	records, err := rep.readRecords()
+	if err != nil {
+		mlog.E("Load.readRecords error: %v", err)
+		return model, err
+	}
+
+	model = mcsv.NewCsvModel(records)
+	model.SetPath(path)
+
+	return model, nil
+}
+
+func (rep *CsvRepository) LoadName(path string) string {
+	if ok, err := rep.CanLoad(path); !ok || err != nil {
+		return mi18n.T("読み込み失敗")
+	}
+
+	_, name, _ := mfile.SplitPath(path)
+	return name
+}
+
+func (rep *CsvRepository) readRecords() ([][]string, error) {
+	fbytes, err := io.ReadAll(rep.reader)
+	if err != nil {
+		return nil, err
+	}
+
+	fbytes = bytes.TrimPrefix(fbytes, utf8Bom)
+	if !utf8.Valid(fbytes) {
+		decoded, err := japanese.ShiftJIS.NewDecoder().Bytes(fbytes)
+		if err != nil {
+			return nil, err
+		}
+		fbytes = decoded
+	}
+
+	reader := csv.NewReader(bytes.NewReader(fbytes))
+	// 行ごとに列数が違っても読み込む
+	reader.FieldsPerRecord = -1
+
+	return reader.ReadAll()
+}
@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// glTF 2.0 のJSON構造(出力に必要な分のみ)
+
+type gltfDocument struct {
+	Asset       gltfAsset        `json:"asset"`
+	Scene       int              `json:"scene"`
+	Scenes      []gltfScene      `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes"`
+	Meshes      []gltfMesh       `json:"meshes,omitempty"`
+	Skins       []gltfSkin       `json:"skins,omitempty"`
+	Materials   []gltfMaterial   `json:"materials,omitempty"`
+	Textures    []gltfTexture    `json:"textures,omitempty"`
+	Images      []gltfImage      `json:"images,omitempty"`
+	Animations  []gltfAnimation  `json:"animations,omitempty"`
+	Accessors   []gltfAccessor   `json:"accessors,omitempty"`
+	BufferViews []gltfBufferView `json:"bufferViews,omitempty"`
+	Buffers     []gltfBuffer     `json:"buffers"`
+}
+
+type gltfAsset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator,omitempty"`
+}
+
+type gltfScene struct {
+	Name  string `json:"name,omitempty"`
+	Nodes []int  `json:"nodes"`
+}
+
+type gltfNode struct {
+	Name        string    `json:"name,omitempty"`
+	Children    []int     `json:"children,omitempty"`
+	Translation []float64 `json:"translation,omitempty"`
+	Mesh        *int      `json:"mesh,omitempty"`
+	Skin        *int      `json:"skin,omitempty"`
+}
+
+type gltfMesh struct {
+	Name       string          `json:"name,omitempty"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    int            `json:"indices"`
+	Material   *int           `json:"material,omitempty"`
+}
+
+type gltfSkin struct {
+	InverseBindMatrices int   `json:"inverseBindMatrices"`
+	Joints              []int `json:"joints"`
+}
+
+type gltfMaterial struct {
+	Name                 string  `json:"name,omitempty"`
+	PbrMetallicRoughness gltfPbr `json:"pbrMetallicRoughness"`
+	AlphaMode            string  `json:"alphaMode,omitempty"`
+	DoubleSided          bool    `json:"doubleSided,omitempty"`
+}
+
+type gltfPbr struct {
+	BaseColorFactor  []float64        `json:"baseColorFactor"`
+	BaseColorTexture *gltfTextureInfo `json:"baseColorTexture,omitempty"`
+	MetallicFactor   float64          `json:"metallicFactor"`
+	RoughnessFactor  float64          `json:"roughnessFactor"`
+}
+
+type gltfTextureInfo struct {
+	Index int `json:"index"`
+}
+
+type gltfTexture struct {
+	Source int `json:"source"`
+}
+
+type gltfImage struct {
+	Name       string `json:"name,omitempty"`
+	BufferView *int   `json:"bufferView,omitempty"`
+	MimeType   string `json:"mimeType,omitempty"`
+}
+
+type gltfAnimation struct {
+	Name     string                 `json:"name,omitempty"`
+	Channels []gltfAnimationChannel `json:"channels"`
+	Samplers []gltfAnimationSampler `json:"samplers"`
+}
+
+type gltfAnimationChannel struct {
+	Sampler int                 `json:"sampler"`
+	Target  gltfAnimationTarget `json:"target"`
+}
+
+type gltfAnimationTarget struct {
+	Node int    `json:"node"`
+	Path string `json:"path"`
+}
+
+type gltfAnimationSampler struct {
+	Input         int    `json:"input"`
+	Output        int    `json:"output"`
+	Interpolation string `json:"interpolation"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float64 `json:"min,omitempty"`
+	Max           []float64 `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target,omitempty"`
+}
+
+type gltfBuffer struct {
+	Uri        string `json:"uri,omitempty"`
+	ByteLength int    `json:"byteLength"`
+}
+
+// gltfBuilder 単一バッファにデータを詰めながらアクセサを登録する
+type gltfBuilder struct {
+	doc *gltfDocument
+	buf *bytes.Buffer
+}
+
+func newGltfBuilder() *gltfBuilder {
+	return &gltfBuilder{
+		doc: &gltfDocument{},
+		buf: new(bytes.Buffer),
+	}
+}
+
+var gltfTypeComponents = map[string]int{
+	"SCALAR": 1,
+	"VEC2":   2,
+	"VEC3":   3,
+	"VEC4":   4,
+	"MAT4":   16,
+}
+
+// addBufferView バッファにデータを追加して、バッファビューINDEXを返す
+func (builder *gltfBuilder) addBufferView(data []byte, target int) int {
+	// 各ビューは4バイト境界から始める
+	for builder.buf.Len()%4 != 0 {
+		builder.buf.WriteByte(0)
+	}
+
+	builder.doc.BufferViews = append(builder.doc.BufferViews, gltfBufferView{
+		Buffer:     0,
+		ByteOffset: builder.buf.Len(),
+		ByteLength: len(data),
+		Target:     target,
+	})
+	builder.buf.Write(data)
+
+	return len(builder.doc.BufferViews) - 1
+}
+
+func (builder *gltfBuilder) addAccessor(data []byte, componentType, count int, accessorType string, target int, min, max []float64) int {
+	builder.doc.Accessors = append(builder.doc.Accessors, gltfAccessor{
+		BufferView:    builder.addBufferView(data, target),
+		ComponentType: componentType,
+		Count:         count,
+		Type:          accessorType,
+		Min:           min,
+		Max:           max,
+	})
+
+	return len(builder.doc.Accessors) - 1
+}
+
+// addFloats float配列のアクセサを追加する (withBounds: 要素ごとのmin/maxを付与するか)
+func (builder *gltfBuilder) addFloats(values []float32, accessorType string, target int, withBounds bool) int {
+	components := gltfTypeComponents[accessorType]
+
+	var min, max []float64
+	if withBounds {
+		min = make([]float64, components)
+		max = make([]float64, components)
+		for i := range components {
+			min[i] = math.MaxFloat64
+			max[i] = -math.MaxFloat64
+		}
+		for i, v := range values {
+			min[i%components] = math.Min(min[i%components], float64(v))
+			max[i%components] = math.Max(max[i%components], float64(v))
+		}
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, values)
+
+	return builder.addAccessor(buf.Bytes(), gltfComponentFloat, len(values)/components, accessorType, target, min, max)
+}
+
+func (builder *gltfBuilder) addUShorts(values []uint16, accessorType string, target int) int {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, values)
+
+	return builder.addAccessor(buf.Bytes(), gltfComponentUnsignedShort,
+		len(values)/gltfTypeComponents[accessorType], accessorType, target, nil, nil)
+}
+
+func (builder *gltfBuilder) addUInts(values []uint32, target int) int {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, values)
+
+	return builder.addAccessor(buf.Bytes(), gltfComponentUnsignedInt, len(values), "SCALAR", target, nil, nil)
+}
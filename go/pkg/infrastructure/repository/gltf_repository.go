@@ -0,0 +1,505 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mi18n"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mproc"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/core"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/delta"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/infrastructure/mfile"
+)
+
+const (
+	// MMDの1単位は約8cm
+	GLTF_DEFAULT_SCALE = 0.08
+
+	gltfComponentUnsignedShort = 5123
+	gltfComponentUnsignedInt   = 5125
+	gltfComponentFloat         = 5126
+
+	gltfTargetArrayBuffer        = 34962
+	gltfTargetElementArrayBuffer = 34963
+
+	glbMagic     = 0x46546C67
+	glbChunkJson = 0x4E4F534A
+	glbChunkBin  = 0x004E4942
+)
+
+// glTF 2.0 出力リポジトリ
+// 座標系はMMD(左手系)からglTF(右手系)へZ軸反転で変換する
+type GltfRepository struct {
+	*baseRepository[*pmx.PmxModel]
+	scale          float64            // MMD単位からメートルへの倍率
+	animationName  string             // アニメーション名
+	animationFps   float64            // アニメーションのフレームレート
+	animationDelta []*delta.VmdDeltas // フレームごとのボーン変形結果(先頭から0F,1F,...)
+}
+
+func NewGltfRepository(scale float64) *GltfRepository {
+	if scale <= 0 {
+		scale = GLTF_DEFAULT_SCALE
+	}
+
+	return &GltfRepository{
+		baseRepository: &baseRepository[*pmx.PmxModel]{
+			newFunc: func(path string) *pmx.PmxModel {
+				return pmx.NewPmxModel(path)
+			},
+		},
+		scale: scale,
+	}
+}
+
+// SetAnimation ボーン変形済みのフレーム群をアニメーションとして焼き込む
+func (rep *GltfRepository) SetAnimation(name string, fps float64, deltas []*delta.VmdDeltas) {
+	rep.animationName = name
+	rep.animationFps = fps
+	rep.animationDelta = deltas
+}
+
+func (rep *GltfRepository) CanLoad(path string) (bool, error) {
+	return false, fmt.Errorf("gltf load is not supported: %s", path)
+}
+
+func (rep *GltfRepository) Load(path string) (core.IHashModel, error) {
+	return rep.newFunc(path), fmt.Errorf("gltf load is not supported: %s", path)
+}
+
+func (rep *GltfRepository) LoadName(path string) string {
+	return mi18n.T("読み込み失敗")
+}
+
+// Save 拡張子が .glb ならバイナリ、.gltf ならJSON + .bin で保存する
+func (rep *GltfRepository) Save(overridePath string, data core.IHashModel, includeSystem bool) error {
+	mproc.SetMaxProcess(true)
+	defer mproc.SetMaxProcess(false)
+
+	model := data.(*pmx.PmxModel)
+
+	path := overridePath
+	if path == "" {
+		dir, name, _ := mfile.SplitPath(model.Path())
+		path = fmt.Sprintf("%s%s.glb", dir, name)
+	}
+
+	_, _, ext := mfile.SplitPath(path)
+	ext = strings.ToLower(ext)
+	if ext != ".glb" && ext != ".gltf" {
+		return fmt.Errorf("%s", mi18n.T("拡張子エラー", map[string]interface{}{"Path": path, "Ext": ".glb, .gltf"}))
+	}
+
+	mlog.IL("%s", mi18n.T("保存開始", map[string]interface{}{"Type": "Gltf", "Path": path}))
+	defer mlog.I("%s", mi18n.T("保存終了", map[string]interface{}{"Type": "Gltf"}))
+
+	doc, bin := rep.build(model)
+
+	if ext == ".glb" {
+		return rep.saveGlb(path, doc, bin)
+	}
+
+	dir, name, _ := mfile.SplitPath(path)
+	binName := fmt.Sprintf("%s.bin", name)
+	doc.Buffers[0].Uri = binName
+	if err := os.WriteFile(filepath.Join(dir, binName), bin, 0644); err != nil {
+		return err
+	}
+
+	jsonBytes, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, jsonBytes, 0644)
+}
+
+func (rep *GltfRepository) saveGlb(path string, doc *gltfDocument, bin []byte) error {
+	jsonBytes, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	// チャンクは4バイト境界に揃える(JSONは空白、BINは0埋め)
+	for len(jsonBytes)%4 != 0 {
+		jsonBytes = append(jsonBytes, ' ')
+	}
+	for len(bin)%4 != 0 {
+		bin = append(bin, 0)
+	}
+
+	var buf bytes.Buffer
+	totalLength := 12 + 8 + len(jsonBytes) + 8 + len(bin)
+	for _, v := range []uint32{glbMagic, 2, uint32(totalLength), uint32(len(jsonBytes)), glbChunkJson} {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	buf.Write(jsonBytes)
+	for _, v := range []uint32{uint32(len(bin)), glbChunkBin} {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	buf.Write(bin)
+
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// build モデルからglTFドキュメントとバイナリバッファを構築する
+func (rep *GltfRepository) build(model *pmx.PmxModel) (*gltfDocument, []byte) {
+	builder := newGltfBuilder()
+	doc := builder.doc
+	doc.Asset = gltfAsset{Version: "2.0", Generator: "mmd-auto-trace-5"}
+
+	// ボーン → ノード(ノードINDEX = ボーンINDEX)
+	boneCount := model.Bones.Length()
+	rootNodes := make([]int, 0)
+	model.Bones.ForEach(func(index int, bone *pmx.Bone) bool {
+		node := gltfNode{Name: bone.Name()}
+		translation := bone.Position
+		if model.Bones.Contains(bone.ParentIndex) {
+			parent, _ := model.Bones.Get(bone.ParentIndex)
+			translation = bone.Position.Subed(parent.Position)
+		} else {
+			rootNodes = append(rootNodes, index)
+		}
+		node.Translation = rep.toGltfPosition(translation).Vector()
+		doc.Nodes = append(doc.Nodes, node)
+		return true
+	})
+	model.Bones.ForEach(func(index int, bone *pmx.Bone) bool {
+		if model.Bones.Contains(bone.ParentIndex) {
+			doc.Nodes[bone.ParentIndex].Children = append(doc.Nodes[bone.ParentIndex].Children, index)
+		}
+		return true
+	})
+
+	// スキン
+	inverseBindMatrices := make([]float32, 0, boneCount*16)
+	joints := make([]int, boneCount)
+	model.Bones.ForEach(func(index int, bone *pmx.Bone) bool {
+		joints[index] = index
+		pos := rep.toGltfPosition(bone.Position)
+		inverseBindMatrices = append(inverseBindMatrices,
+			1, 0, 0, 0,
+			0, 1, 0, 0,
+			0, 0, 1, 0,
+			float32(-pos.X), float32(-pos.Y), float32(-pos.Z), 1)
+		return true
+	})
+	if boneCount > 0 {
+		doc.Skins = append(doc.Skins, gltfSkin{
+			InverseBindMatrices: builder.addFloats(inverseBindMatrices, "MAT4", 0, false),
+			Joints:              joints,
+		})
+	}
+
+	// メッシュ
+	if model.Vertices.Length() > 0 {
+		meshNode := gltfNode{Name: model.Name(), Mesh: intPtr(len(doc.Meshes))}
+		if boneCount > 0 {
+			meshNode.Skin = intPtr(0)
+		}
+		doc.Meshes = append(doc.Meshes, rep.buildMesh(builder, model))
+		rootNodes = append(rootNodes, len(doc.Nodes))
+		doc.Nodes = append(doc.Nodes, meshNode)
+	}
+
+	doc.Scenes = []gltfScene{{Name: model.Name(), Nodes: rootNodes}}
+	doc.Scene = 0
+
+	if len(rep.animationDelta) > 0 {
+		if animation := rep.buildAnimation(builder, model); len(animation.Channels) > 0 {
+			doc.Animations = append(doc.Animations, animation)
+		}
+	}
+
+	doc.Buffers = []gltfBuffer{{ByteLength: builder.buf.Len()}}
+
+	return doc, builder.buf.Bytes()
+}
+
+func (rep *GltfRepository) buildMesh(builder *gltfBuilder, model *pmx.PmxModel) gltfMesh {
+	vertexCount := model.Vertices.Length()
+	positions := make([]float32, 0, vertexCount*3)
+	normals := make([]float32, 0, vertexCount*3)
+	uvs := make([]float32, 0, vertexCount*2)
+	jointIndexes := make([]uint16, 0, vertexCount*4)
+	weights := make([]float32, 0, vertexCount*4)
+
+	model.Vertices.ForEach(func(index int, vertex *pmx.Vertex) bool {
+		pos := rep.toGltfPosition(vertex.Position)
+		positions = append(positions, float32(pos.X), float32(pos.Y), float32(pos.Z))
+
+		normal := &mmath.MVec3{X: vertex.Normal.X, Y: vertex.Normal.Y, Z: -vertex.Normal.Z}
+		if normal.Length() == 0 {
+			normal = &mmath.MVec3{X: 0, Y: 1, Z: 0}
+		}
+		normal = normal.Normalized()
+		normals = append(normals, float32(normal.X), float32(normal.Y), float32(normal.Z))
+
+		uvs = append(uvs, float32(vertex.Uv.X), float32(vertex.Uv.Y))
+
+		vertexJoints, vertexWeights := rep.skinWeights(model, vertex)
+		jointIndexes = append(jointIndexes, vertexJoints[:]...)
+		weights = append(weights, vertexWeights[:]...)
+
+		return true
+	})
+
+	attributes := map[string]int{
+		"POSITION":   builder.addFloats(positions, "VEC3", gltfTargetArrayBuffer, true),
+		"NORMAL":     builder.addFloats(normals, "VEC3", gltfTargetArrayBuffer, false),
+		"TEXCOORD_0": builder.addFloats(uvs, "VEC2", gltfTargetArrayBuffer, false),
+	}
+	if model.Bones.Length() > 0 {
+		attributes["JOINTS_0"] = builder.addUShorts(jointIndexes, "VEC4", gltfTargetArrayBuffer)
+		attributes["WEIGHTS_0"] = builder.addFloats(weights, "VEC4", gltfTargetArrayBuffer, false)
+	}
+
+	textureIndexes := rep.buildTextures(builder, model)
+
+	mesh := gltfMesh{Name: model.Name()}
+	faceIndex := 0
+	model.Materials.ForEach(func(index int, material *pmx.Material) bool {
+		faceCount := material.VerticesCount / 3
+		indices := make([]uint32, 0, material.VerticesCount)
+		for i := faceIndex; i < faceIndex+faceCount; i++ {
+			face, err := model.Faces.Get(i)
+			if err != nil {
+				continue
+			}
+			// Z軸反転で表裏が逆になるので、頂点順も反転する
+			indices = append(indices,
+				uint32(face.VertexIndexes[0]), uint32(face.VertexIndexes[2]), uint32(face.VertexIndexes[1]))
+		}
+		faceIndex += faceCount
+
+		if len(indices) == 0 {
+			return true
+		}
+
+		mesh.Primitives = append(mesh.Primitives, gltfPrimitive{
+			Attributes: attributes,
+			Indices:    builder.addUInts(indices, gltfTargetElementArrayBuffer),
+			Material:   intPtr(len(builder.doc.Materials)),
+		})
+		builder.doc.Materials = append(builder.doc.Materials, rep.buildMaterial(material, textureIndexes))
+
+		return true
+	})
+
+	return mesh
+}
+
+// skinWeights 頂点のウェイトを4本に揃える(SDEFはBDEF2として近似する)
+func (rep *GltfRepository) skinWeights(model *pmx.PmxModel, vertex *pmx.Vertex) ([4]uint16, [4]float32) {
+	var joints [4]uint16
+	var weights [4]float32
+
+	if vertex.Deform == nil {
+		return joints, [4]float32{1, 0, 0, 0}
+	}
+
+	total := 0.0
+	n := 0
+	for i, boneIndex := range vertex.Deform.Indexes() {
+		if n >= 4 {
+			break
+		}
+		weight := vertex.Deform.Weights()[i]
+		if !model.Bones.Contains(boneIndex) || weight <= 0 {
+			continue
+		}
+		joints[n] = uint16(boneIndex)
+		weights[n] = float32(weight)
+		total += weight
+		n++
+	}
+
+	if total <= 0 {
+		return [4]uint16{}, [4]float32{1, 0, 0, 0}
+	}
+	for i := range weights {
+		weights[i] = float32(float64(weights[i]) / total)
+	}
+
+	return joints, weights
+}
+
+// buildTextures PNG/JPEGテクスチャを埋め込み、テクスチャINDEX → glTFテクスチャINDEX を返す
+func (rep *GltfRepository) buildTextures(builder *gltfBuilder, model *pmx.PmxModel) map[int]int {
+	textureIndexes := make(map[int]int)
+	modelDir := filepath.Dir(model.Path())
+
+	model.Textures.ForEach(func(index int, texture *pmx.Texture) bool {
+		texturePath := filepath.Join(modelDir, strings.ReplaceAll(texture.Name(), "\\", string(filepath.Separator)))
+		var mimeType string
+		switch strings.ToLower(filepath.Ext(texturePath)) {
+		case ".png":
+			mimeType = "image/png"
+		case ".jpg", ".jpeg":
+			mimeType = "image/jpeg"
+		default:
+			mlog.W("Skip unsupported gltf texture: %s", texture.Name())
+			return true
+		}
+
+		imageBytes, err := os.ReadFile(texturePath)
+		if err != nil {
+			mlog.W("Skip missing gltf texture: %s", texturePath)
+			return true
+		}
+
+		builder.doc.Images = append(builder.doc.Images, gltfImage{
+			Name:       texture.Name(),
+			BufferView: intPtr(builder.addBufferView(imageBytes, 0)),
+			MimeType:   mimeType,
+		})
+		textureIndexes[index] = len(builder.doc.Textures)
+		builder.doc.Textures = append(builder.doc.Textures, gltfTexture{Source: len(builder.doc.Images) - 1})
+
+		return true
+	})
+
+	return textureIndexes
+}
+
+func (rep *GltfRepository) buildMaterial(material *pmx.Material, textureIndexes map[int]int) gltfMaterial {
+	gm := gltfMaterial{
+		Name:        material.Name(),
+		DoubleSided: material.DrawFlag.IsDoubleSidedDrawing(),
+		PbrMetallicRoughness: gltfPbr{
+			BaseColorFactor: []float64{
+				mmath.Clamped01(material.Diffuse.X), mmath.Clamped01(material.Diffuse.Y),
+				mmath.Clamped01(material.Diffuse.Z), mmath.Clamped01(material.Diffuse.W)},
+			MetallicFactor:  0,
+			RoughnessFactor: 1,
+		},
+		AlphaMode: "OPAQUE",
+	}
+
+	if textureIndex, ok := textureIndexes[material.TextureIndex]; ok {
+		gm.PbrMetallicRoughness.BaseColorTexture = &gltfTextureInfo{Index: textureIndex}
+		// MMDのテクスチャは透過を含むことが多い
+		gm.AlphaMode = "BLEND"
+	}
+	if material.Diffuse.W < 1 {
+		gm.AlphaMode = "BLEND"
+	}
+
+	return gm
+}
+
+// buildAnimation フレームごとのボーン変形結果から、ノードのローカルTRSを焼き込む
+func (rep *GltfRepository) buildAnimation(builder *gltfBuilder, model *pmx.PmxModel) gltfAnimation {
+	fps := rep.animationFps
+	if fps <= 0 {
+		fps = 30
+	}
+
+	frameCount := len(rep.animationDelta)
+	times := make([]float32, frameCount)
+	for i := range times {
+		times[i] = float32(float64(i) / fps)
+	}
+
+	animation := gltfAnimation{Name: rep.animationName}
+	inputIndex := -1
+
+	model.Bones.ForEach(func(index int, bone *pmx.Bone) bool {
+		translations := make([]float32, 0, frameCount*3)
+		rotations := make([]float32, 0, frameCount*4)
+		restTranslation := bone.Position
+		if model.Bones.Contains(bone.ParentIndex) {
+			parent, _ := model.Bones.Get(bone.ParentIndex)
+			restTranslation = bone.Position.Subed(parent.Position)
+		}
+		isMoved := false
+		isRotated := false
+		var prevQuat *mmath.MQuaternion
+
+		for _, vmdDeltas := range rep.animationDelta {
+			localMat := rep.localMatrix(model, vmdDeltas, bone)
+
+			translation := localMat.Translation()
+			if !translation.NearEquals(restTranslation, 1e-6) {
+				isMoved = true
+			}
+			pos := rep.toGltfPosition(translation)
+			translations = append(translations, float32(pos.X), float32(pos.Y), float32(pos.Z))
+
+			quat := localMat.Quaternion().Normalized()
+			quat = mmath.NewMQuaternionByValues(-quat.X, -quat.Y, quat.Z, quat.W)
+			if prevQuat != nil && prevQuat.Dot(quat) < 0 {
+				// 補間が遠回りにならないよう、前フレームと同じ半球に揃える
+				quat = quat.Negated()
+			}
+			if !quat.NearEquals(mmath.MQuaternionIdent, 1e-6) && !quat.Negated().NearEquals(mmath.MQuaternionIdent, 1e-6) {
+				isRotated = true
+			}
+			rotations = append(rotations, float32(quat.X), float32(quat.Y), float32(quat.Z), float32(quat.W))
+			prevQuat = quat
+		}
+
+		if !isMoved && !isRotated {
+			return true
+		}
+
+		if inputIndex < 0 {
+			// アニメーションの入力にはmin/maxが必須
+			inputIndex = builder.addFloats(times, "SCALAR", 0, true)
+		}
+
+		if isMoved {
+			animation.Samplers = append(animation.Samplers, gltfAnimationSampler{
+				Input: inputIndex, Output: builder.addFloats(translations, "VEC3", 0, false), Interpolation: "LINEAR"})
+			animation.Channels = append(animation.Channels, gltfAnimationChannel{
+				Sampler: len(animation.Samplers) - 1, Target: gltfAnimationTarget{Node: index, Path: "translation"}})
+		}
+		if isRotated {
+			animation.Samplers = append(animation.Samplers, gltfAnimationSampler{
+				Input: inputIndex, Output: builder.addFloats(rotations, "VEC4", 0, false), Interpolation: "LINEAR"})
+			animation.Channels = append(animation.Channels, gltfAnimationChannel{
+				Sampler: len(animation.Samplers) - 1, Target: gltfAnimationTarget{Node: index, Path: "rotation"}})
+		}
+
+		return true
+	})
+
+	return animation
+}
+
+// localMatrix 親ボーンから見たローカル変形行列(MMD座標系)
+func (rep *GltfRepository) localMatrix(model *pmx.PmxModel, vmdDeltas *delta.VmdDeltas, bone *pmx.Bone) *mmath.MMat4 {
+	globalMat := mmath.NewMMat4()
+	if bd := vmdDeltas.Bones.Get(bone.Index()); bd != nil {
+		globalMat = bd.FilledGlobalMatrix()
+	} else {
+		globalMat = bone.Position.ToMat4()
+	}
+
+	parentMat := mmath.NewMMat4()
+	if model.Bones.Contains(bone.ParentIndex) {
+		if bd := vmdDeltas.Bones.Get(bone.ParentIndex); bd != nil {
+			parentMat = bd.FilledGlobalMatrix()
+		} else {
+			parent, _ := model.Bones.Get(bone.ParentIndex)
+			parentMat = parent.Position.ToMat4()
+		}
+	}
+
+	return parentMat.Inverted().Muled(globalMat)
+}
+
+func (rep *GltfRepository) toGltfPosition(v *mmath.MVec3) *mmath.MVec3 {
+	return &mmath.MVec3{X: v.X * rep.scale, Y: v.Y * rep.scale, Z: -v.Z * rep.scale}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/delta"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
+)
+
+func newGltfTestModel() *pmx.PmxModel {
+	model := pmx.NewPmxModel("")
+	model.SetName("test")
+
+	root := pmx.NewBoneByName("センター")
+	root.Position = &mmath.MVec3{X: 0, Y: 8, Z: 0}
+	root.ParentIndex = -1
+	model.Bones.Append(root)
+
+	child := pmx.NewBoneByName("上半身")
+	child.Position = &mmath.MVec3{X: 0, Y: 10, Z: 1}
+	child.ParentIndex = 0
+	model.Bones.Append(child)
+	model.Bones.Setup()
+
+	for i, pos := range []*mmath.MVec3{{X: 0, Y: 10, Z: 0}, {X: 1, Y: 10, Z: 0}, {X: 0, Y: 11, Z: 0}} {
+		vertex := pmx.NewVertex()
+		vertex.Position = pos
+		vertex.Normal = &mmath.MVec3{X: 0, Y: 0, Z: -1}
+		if i == 2 {
+			vertex.Deform = pmx.NewBdef2(0, 1, 0.25)
+		} else {
+			vertex.Deform = pmx.NewBdef1(1)
+		}
+		model.Vertices.Append(vertex)
+	}
+
+	face := pmx.NewFace()
+	face.VertexIndexes = [3]int{0, 1, 2}
+	model.Faces.Append(face)
+
+	material := pmx.NewMaterialByName("mat")
+	material.Diffuse = &mmath.MVec4{X: 1, Y: 0.5, Z: 0.5, W: 1}
+	material.VerticesCount = 3
+	model.Materials.Append(material)
+
+	return model
+}
+
+func readGlbJson(t *testing.T, path string) (*gltfDocument, []byte) {
+	glb, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	if binary.LittleEndian.Uint32(glb[0:4]) != glbMagic {
+		t.Fatalf("Expected glb magic, got %x", glb[0:4])
+	}
+	if int(binary.LittleEndian.Uint32(glb[8:12])) != len(glb) {
+		t.Fatalf("Expected glb length %d, got %d", len(glb), binary.LittleEndian.Uint32(glb[8:12]))
+	}
+
+	jsonLength := binary.LittleEndian.Uint32(glb[12:16])
+	doc := new(gltfDocument)
+	if err := json.Unmarshal(glb[20:20+jsonLength], doc); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	binLength := binary.LittleEndian.Uint32(glb[20+jsonLength : 24+jsonLength])
+	return doc, glb[28+jsonLength : 28+jsonLength+binLength]
+}
+
+func readGltfFloats(doc *gltfDocument, bin []byte, accessorIndex int) []float32 {
+	accessor := doc.Accessors[accessorIndex]
+	view := doc.BufferViews[accessor.BufferView]
+	values := make([]float32, view.ByteLength/4)
+	binary.Read(bytes.NewReader(bin[view.ByteOffset:view.ByteOffset+view.ByteLength]), binary.LittleEndian, values)
+	return values
+}
+
+func TestGltfRepository_Save(t *testing.T) {
+	model := newGltfTestModel()
+
+	// 1F目で上半身をZ軸回りに90度回す
+	deltas := make([]*delta.VmdDeltas, 2)
+	rotation := mmath.NewMQuaternionFromDegrees(0, 0, 90)
+	for i := range deltas {
+		deltas[i] = delta.NewVmdDeltas(float32(i), model.Bones, "", "")
+		model.Bones.ForEach(func(index int, bone *pmx.Bone) bool {
+			bd := delta.NewBoneDelta(bone, float32(i))
+			bd.GlobalMatrix = bone.Position.ToMat4()
+			if index == 1 && i == 1 {
+				bd.GlobalMatrix = bone.Position.ToMat4().Muled(rotation.ToMat4())
+			}
+			deltas[i].Bones.Update(bd)
+			return true
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "test.glb")
+	rep := NewGltfRepository(1.0)
+	rep.SetAnimation("anim", 30, deltas)
+	if err := rep.Save(path, model, false); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	doc, bin := readGlbJson(t, path)
+
+	if len(doc.Nodes) != 3 {
+		t.Fatalf("Expected 3 nodes, got %d", len(doc.Nodes))
+	}
+	expectedTranslation := []float64{0, 2, -1}
+	for i, v := range doc.Nodes[1].Translation {
+		if math.Abs(v-expectedTranslation[i]) > 1e-6 {
+			t.Errorf("Expected child translation %v, got %v", expectedTranslation, doc.Nodes[1].Translation)
+		}
+	}
+	if len(doc.Skins) != 1 || len(doc.Skins[0].Joints) != 2 {
+		t.Fatalf("Expected 1 skin with 2 joints, got %v", doc.Skins)
+	}
+
+	primitive := doc.Meshes[0].Primitives[0]
+	weights := readGltfFloats(doc, bin, primitive.Attributes["WEIGHTS_0"])
+	if weights[8] != 0.25 || weights[9] != 0.75 {
+		t.Errorf("Expected bdef2 weights [0.25 0.75], got %v", weights[8:12])
+	}
+
+	if len(doc.Animations) != 1 || len(doc.Animations[0].Channels) != 1 {
+		t.Fatalf("Expected 1 animation channel, got %v", doc.Animations)
+	}
+	channel := doc.Animations[0].Channels[0]
+	if channel.Target.Node != 1 || channel.Target.Path != "rotation" {
+		t.Errorf("Expected rotation channel for node 1, got %v", channel.Target)
+	}
+
+	rotations := readGltfFloats(doc, bin, doc.Animations[0].Samplers[channel.Sampler].Output)
+	// Z軸反転なので、Z軸回りの回転はそのまま残る
+	expected := mmath.NewMQuaternionByValues(-rotation.X, -rotation.Y, rotation.Z, rotation.W)
+	actual := mmath.NewMQuaternionByValues(
+		float64(rotations[4]), float64(rotations[5]), float64(rotations[6]), float64(rotations[7]))
+	if !actual.NearEquals(expected, 1e-6) {
+		t.Errorf("Expected rotation %v, got %v", expected, actual)
+	}
+}
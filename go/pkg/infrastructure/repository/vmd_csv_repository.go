@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/core"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mcsv"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/infrastructure/mfile"
+)
+
+const (
+	csvTypeBone  = "bone"
+	csvTypeMorph = "morph"
+)
+
+// VMDのキーフレをCSVの1行1キーフレで表現する際の列
+// 回転はクォータニオン列が正(往復で値が変わらないように)。オイラー角列は確認用で、
+// オイラー角で編集する場合はクォータニオン列を4つとも空欄にする
+var vmdCsvHeader = []string{
+	"type", "frame", "name",
+	"posX", "posY", "posZ",
+	"degX", "degY", "degZ",
+	"quatX", "quatY", "quatZ", "quatW",
+	"curveTX_x1", "curveTX_y1", "curveTX_x2", "curveTX_y2",
+	"curveTY_x1", "curveTY_y1", "curveTY_x2", "curveTY_y2",
+	"curveTZ_x1", "curveTZ_y1", "curveTZ_x2", "curveTZ_y2",
+	"curveR_x1", "curveR_y1", "curveR_x2", "curveR_y2",
+	"ratio",
+}
+
+// VMDとCSVの相互変換リポジトリ
+type VmdCsvRepository struct {
+	csvRepository *CsvRepository
+}
+
+func NewVmdCsvRepository() *VmdCsvRepository {
+	rep := new(VmdCsvRepository)
+	rep.csvRepository = NewCsvRepository()
+	return rep
+}
+
+// Save モーションのボーン・モーフキーフレをCSVに保存する
+func (rep *VmdCsvRepository) Save(overridePath string, data core.IHashModel, includeSystem bool) error {
+	motion := data.(*vmd.VmdMotion)
+
+	path := overridePath
+	if path == "" {
+		dir, name, _ := mfile.SplitPath(motion.Path())
+		path = fmt.Sprintf("%s%s.csv", dir, name)
+	}
+
+	csvModel := NewCsvModelByVmdMotion(motion)
+	csvModel.SetPath(path)
+
+	return rep.csvRepository.Save(path, csvModel, includeSystem)
+}
+
+func (rep *VmdCsvRepository) CanLoad(path string) (bool, error) {
+	return rep.csvRepository.CanLoad(path)
+}
+
+// 指定されたパスのCSVからモーションを読み込む
+func (rep *VmdCsvRepository) Load(path string) (core.IHashModel, error) {
+	if ok, err := rep.CanLoad(path); !ok || err != nil {
+		return vmd.NewVmdMotion(path), err
+	}
+
+	data, err := rep.csvRepository.Load(path)
+	if err != nil {
+		return vmd.NewVmdMotion(path), err
+	}
+
+	dir, name, _ := mfile.SplitPath(path)
+	motion, err := NewVmdMotionByCsvModel(data.(*mcsv.CsvModel), fmt.Sprintf("%s%s.vmd", dir, name))
+	if err != nil {
+		return motion, err
+	}
+
+	motion.SetName(name)
+	motion.UpdateHash()
+
+	return motion, nil
+}
+
+func (rep *VmdCsvRepository) LoadName(path string) string {
+	return rep.csvRepository.LoadName(path)
+}
+
+// NewCsvModelByVmdMotion モーションのボーン・モーフキーフレをCSVモデルに変換する
+func NewCsvModelByVmdMotion(motion *vmd.VmdMotion) *mcsv.CsvModel {
+	records := [][]string{vmdCsvHeader}
+
+	for _, boneName := range motion.BoneFrames.Names() {
+		motion.BoneFrames.Get(boneName).ForEach(func(fno float32, bf *vmd.BoneFrame) bool {
+			records = append(records, boneFrameToRecord(boneName, bf))
+			return true
+		})
+	}
+
+	for _, morphName := range motion.MorphFrames.Names() {
+		motion.MorphFrames.Get(morphName).ForEach(func(fno float32, mf *vmd.MorphFrame) bool {
+			records = append(records, morphFrameToRecord(morphName, mf))
+			return true
+		})
+	}
+
+	return mcsv.NewCsvModel(records)
+}
+
+// NewVmdMotionByCsvModel CSVモデルからモーションを生成する
+func NewVmdMotionByCsvModel(csvModel *mcsv.CsvModel, path string) (*vmd.VmdMotion, error) {
+	motion := vmd.NewVmdMotion(path)
+
+	columns := make(map[string]int)
+	for i, name := range vmdCsvHeader {
+		columns[name] = i
+	}
+
+	for i, record := range csvModel.Records() {
+		if len(record) == 0 || (len(record) == 1 && strings.TrimSpace(record[0]) == "") {
+			continue
+		}
+
+		if i == 0 && strings.TrimSpace(record[0]) == vmdCsvHeader[0] {
+			// ヘッダ行の列順に従う(表計算ソフトで列を入れ替えられても読めるように)
+			columns = make(map[string]int)
+			for j, name := range record {
+				columns[strings.TrimSpace(name)] = j
+			}
+			continue
+		}
+
+		row := &csvRow{record: record, columns: columns, rowIndex: i + 1}
+
+		fno, err := row.float("frame")
+		if err != nil {
+			return motion, err
+		}
+
+		switch row.text("type") {
+		case csvTypeBone:
+			bf, err := recordToBoneFrame(row, float32(fno))
+			if err != nil {
+				return motion, err
+			}
+			motion.AppendBoneFrame(row.text("name"), bf)
+		case csvTypeMorph:
+			mf := vmd.NewMorphFrame(float32(fno))
+			if mf.Ratio, err = row.float("ratio"); err != nil {
+				return motion, err
+			}
+			motion.AppendMorphFrame(row.text("name"), mf)
+		default:
+			return motion, fmt.Errorf("csv row %d: unknown type %q", i+1, row.text("type"))
+		}
+	}
+
+	return motion, nil
+}
+
+func boneFrameToRecord(boneName string, bf *vmd.BoneFrame) []string {
+	record := make([]string, len(vmdCsvHeader))
+	record[0] = csvTypeBone
+	record[1] = formatCsvFrame(bf.Index())
+	record[2] = boneName
+
+	pos := bf.FilledPosition()
+	quat := bf.FilledRotation()
+	degrees := quat.ToMMDDegrees()
+	curves := bf.Curves
+	if curves == nil {
+		curves = vmd.NewBoneCurves()
+	}
+
+	values := []float64{pos.X, pos.Y, pos.Z, degrees.X, degrees.Y, degrees.Z, quat.X, quat.Y, quat.Z, quat.W}
+	for i, v := range values {
+		record[3+i] = formatCsvFloat(v)
+	}
+
+	for i, curve := range []*mmath.Curve{curves.TranslateX, curves.TranslateY, curves.TranslateZ, curves.Rotate} {
+		record[13+i*4] = formatCsvFloat(curve.Start.X)
+		record[14+i*4] = formatCsvFloat(curve.Start.Y)
+		record[15+i*4] = formatCsvFloat(curve.End.X)
+		record[16+i*4] = formatCsvFloat(curve.End.Y)
+	}
+
+	return record
+}
+
+func morphFrameToRecord(morphName string, mf *vmd.MorphFrame) []string {
+	record := make([]string, len(vmdCsvHeader))
+	record[0] = csvTypeMorph
+	record[1] = formatCsvFrame(mf.Index())
+	record[2] = morphName
+	record[len(record)-1] = formatCsvFloat(mf.Ratio)
+
+	return record
+}
+
+func recordToBoneFrame(row *csvRow, fno float32) (*vmd.BoneFrame, error) {
+	bf := vmd.NewBoneFrame(fno)
+
+	values := make(map[string]float64)
+	for i, name := range vmdCsvHeader[3:29] {
+		v, err := row.float(name)
+		if err != nil {
+			return nil, err
+		}
+		if i >= 10 && row.text(name) == "" {
+			// 補間曲線が空欄の場合は線形補間
+			linear := mmath.LINER_CURVE
+			v = []float64{linear.Start.X, linear.Start.Y, linear.End.X, linear.End.Y}[(i-10)%4]
+		}
+		values[name] = v
+	}
+
+	bf.Position = &mmath.MVec3{X: values["posX"], Y: values["posY"], Z: values["posZ"]}
+
+	if row.text("quatX") == "" && row.text("quatY") == "" && row.text("quatZ") == "" && row.text("quatW") == "" {
+		// クォータニオン列が空欄の場合のみ、オイラー角から回転を作る
+		bf.Rotation = mmath.NewMQuaternionFromMMDDegrees(values["degX"], values["degY"], values["degZ"])
+	} else {
+		quat := mmath.NewMQuaternionByValues(values["quatX"], values["quatY"], values["quatZ"], values["quatW"])
+		if quat.Length() == 0 {
+			return nil, fmt.Errorf("csv row %d: quaternion must not be zero", row.rowIndex)
+		}
+		bf.Rotation = quat
+	}
+
+	curveValues := make([]byte, 16)
+	for i, name := range vmdCsvHeader[13:29] {
+		curveValues[i] = byte(mmath.Clamped(values[name], 0, mmath.CURVE_MAX))
+	}
+	bf.Curves = vmd.NewBoneCurves()
+	bf.Curves.TranslateX = mmath.NewCurveByValues(curveValues[0], curveValues[1], curveValues[2], curveValues[3])
+	bf.Curves.TranslateY = mmath.NewCurveByValues(curveValues[4], curveValues[5], curveValues[6], curveValues[7])
+	bf.Curves.TranslateZ = mmath.NewCurveByValues(curveValues[8], curveValues[9], curveValues[10], curveValues[11])
+	bf.Curves.Rotate = mmath.NewCurveByValues(curveValues[12], curveValues[13], curveValues[14], curveValues[15])
+
+	return bf, nil
+}
+
+func formatCsvFrame(fno float32) string {
+	return strconv.FormatFloat(float64(fno), 'f', -1, 32)
+}
+
+// 最短で元の値に戻る表記にする(往復で値が変わらないように)
+func formatCsvFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type csvRow struct {
+	record   []string
+	columns  map[string]int
+	rowIndex int
+}
+
+func (row *csvRow) text(name string) string {
+	index, ok := row.columns[name]
+	if !ok || index >= len(row.record) {
+		return ""
+	}
+	return strings.TrimSpace(row.record[index])
+}
+
+func (row *csvRow) float(name string) (float64, error) {
+	text := row.text(name)
+	if text == "" {
+		return 0, nil
+	}
+
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("csv row %d column %s: %w", row.rowIndex, name, err)
+	}
+	return v, nil
+}
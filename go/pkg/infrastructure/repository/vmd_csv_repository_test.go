@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mcsv"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+)
+
+func TestVmdCsvRepository_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	motion := vmd.NewVmdMotion(filepath.Join(dir, "test.vmd"))
+
+	bf1 := vmd.NewBoneFrame(0)
+	bf1.Position = &mmath.MVec3{X: 1.23456789, Y: -2.5, Z: 0.1}
+	bf1.Rotation = mmath.NewMQuaternionFromDegrees(10, 20, 30)
+	motion.AppendBoneFrame("センター", bf1)
+
+	bf2 := vmd.NewBoneFrame(15)
+	bf2.Position = &mmath.MVec3{X: 0.3, Y: 0.7, Z: -1.1}
+	bf2.Rotation = mmath.NewMQuaternionFromDegrees(-60, 5, 80)
+	bf2.Curves = vmd.NewBoneCurves()
+	bf2.Curves.Rotate = mmath.NewCurveByValues(40, 10, 90, 120)
+	motion.AppendBoneFrame("センター", bf2)
+
+	bf3 := vmd.NewBoneFrame(3)
+	bf3.Rotation = mmath.NewMQuaternionFromDegrees(0, 45, 0)
+	motion.AppendBoneFrame("左腕", bf3)
+
+	mf := vmd.NewMorphFrame(7)
+	mf.Ratio = 0.3333333
+	motion.AppendMorphFrame("あ", mf)
+
+	csvPath := filepath.Join(dir, "test.csv")
+	rep := NewVmdCsvRepository()
+	if err := rep.Save(csvPath, motion, false); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	data, err := rep.Load(csvPath)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	reloadMotion := data.(*vmd.VmdMotion)
+
+	for _, boneName := range []string{"センター", "左腕"} {
+		motion.BoneFrames.Get(boneName).ForEach(func(fno float32, expected *vmd.BoneFrame) bool {
+			if !reloadMotion.BoneFrames.Get(boneName).Contains(fno) {
+				t.Errorf("Expected %s to contain frame %v", boneName, fno)
+				return true
+			}
+			actual := reloadMotion.BoneFrames.Get(boneName).Get(fno)
+			if *actual.Position != *expected.FilledPosition() {
+				t.Errorf("Expected position %v, got %v", expected.Position, actual.Position)
+			}
+			if *actual.Rotation != *expected.FilledRotation() {
+				t.Errorf("Expected rotation %v, got %v", expected.Rotation, actual.Rotation)
+			}
+			expectedCurves := expected.Curves
+			if expectedCurves == nil {
+				expectedCurves = vmd.NewBoneCurves()
+			}
+			if *actual.Curves.Rotate != *expectedCurves.Rotate || *actual.Curves.TranslateX != *expectedCurves.TranslateX {
+				t.Errorf("Expected curves %v, got %v", expectedCurves.Rotate, actual.Curves.Rotate)
+			}
+			return true
+		})
+	}
+
+	if actual := reloadMotion.MorphFrames.Get("あ").Get(7).Ratio; actual != mf.Ratio {
+		t.Errorf("Expected ratio %v, got %v", mf.Ratio, actual)
+	}
+
+	// CSVに戻しても同じ内容になること
+	expectedRecords := NewCsvModelByVmdMotion(motion).Records()
+	actualRecords := NewCsvModelByVmdMotion(reloadMotion).Records()
+	if len(expectedRecords) != len(actualRecords) {
+		t.Fatalf("Expected %d records, got %d", len(expectedRecords), len(actualRecords))
+	}
+	for i := range expectedRecords {
+		for j := range expectedRecords[i] {
+			if expectedRecords[i][j] != actualRecords[i][j] {
+				t.Errorf("Expected cell (%d, %d) to be %q, got %q", i, j, expectedRecords[i][j], actualRecords[i][j])
+			}
+		}
+	}
+}
+
+func TestNewVmdMotionByCsvModel_EditedDegrees(t *testing.T) {
+	motion := vmd.NewVmdMotion("")
+	bf := vmd.NewBoneFrame(0)
+	bf.Rotation = mmath.NewMQuaternion()
+	motion.AppendBoneFrame("首", bf)
+
+	records := NewCsvModelByVmdMotion(motion).Records()
+	// 表計算ソフトでオイラー角を編集し、クォータニオン列を空欄にした想定
+	records[1][6] = "30"
+	for i := 9; i < 13; i++ {
+		records[1][i] = ""
+	}
+
+	reloadMotion, err := NewVmdMotionByCsvModel(mcsv.NewCsvModel(records), "")
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	expected := &mmath.MVec3{X: 30, Y: 0, Z: 0}
+	actual := reloadMotion.BoneFrames.Get("首").Get(0).Rotation.ToMMDDegrees()
+	if !actual.NearEquals(expected, 1e-6) {
+		t.Errorf("Expected degrees %v, got %v", expected, actual)
+	}
+}
+
+func TestNewVmdMotionByCsvModel_EditedQuaternion(t *testing.T) {
+	motion := vmd.NewVmdMotion("")
+	bf := vmd.NewBoneFrame(0)
+	bf.Rotation = mmath.NewMQuaternion()
+	motion.AppendBoneFrame("首", bf)
+
+	records := NewCsvModelByVmdMotion(motion).Records()
+	// クォータニオンだけ編集し、オイラー角は古いままの想定
+	expected := mmath.NewMQuaternionFromDegrees(0, 0, 45)
+	records[1][9] = formatCsvFloat(expected.X)
+	records[1][10] = formatCsvFloat(expected.Y)
+	records[1][11] = formatCsvFloat(expected.Z)
+	records[1][12] = formatCsvFloat(expected.W)
+
+	reloadMotion, err := NewVmdMotionByCsvModel(mcsv.NewCsvModel(records), "")
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	if actual := reloadMotion.BoneFrames.Get("首").Get(0).Rotation; *actual != *expected {
+		t.Errorf("Expected rotation %v, got %v", expected, actual)
+	}
+
+	records[1][9], records[1][10], records[1][11], records[1][12] = "0", "0", "0", "0"
+	if _, err := NewVmdMotionByCsvModel(mcsv.NewCsvModel(records), ""); err == nil {
+		t.Errorf("Expected error for zero quaternion")
+	}
+}
+
+func TestCsvRepository_Save_Error(t *testing.T) {
+	model := mcsv.NewCsvModel([][]string{{"a"}})
+	path := filepath.Join(t.TempDir(), "missing", "test.csv")
+	if err := NewCsvRepository().Save(path, model, false); err == nil {
+		t.Errorf("Expected error for unwritable path")
+	}
+}
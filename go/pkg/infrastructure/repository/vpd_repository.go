@@ -3,6 +3,7 @@ package repository
 import (
 	"bufio"
 	"fmt"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
@@ -38,10 +39,86 @@ func (rep *VpdRepository) Save(overridePath string, data core.IHashModel, includ
 	mproc.SetMaxProcess(true)
 	defer mproc.SetMaxProcess(false)
 
-	mlog.IL("%s", mi18n.T("保存開始", map[string]interface{}{"Type": "Vpd", "Path": overridePath}))
+	motion := data.(*vmd.VmdMotion)
+
+	path := motion.Path()
+	// 保存可能なパスである場合、上書き
+	if mfile.CanSave(overridePath) {
+		path = overridePath
+	}
+
+	mlog.IL("%s", mi18n.T("保存開始", map[string]interface{}{"Type": "Vpd", "Path": path}))
 	defer mlog.I("%s", mi18n.T("保存終了", map[string]interface{}{"Type": "Vpd"}))
 
-	return nil
+	// 0フレーム目のポーズを出力する
+	var builder strings.Builder
+	rep.writePose(&builder, motion, 0)
+
+	// Shift-JIS で書き込む
+	encoded, err := japanese.ShiftJIS.NewEncoder().String(builder.String())
+	if err != nil {
+		return fmt.Errorf("failed to encode: %w\n\n%v", err, mstring.GetStackTrace())
+	}
+
+	return os.WriteFile(path, []byte(encoded), 0644)
+}
+
+// writePose 指定フレームのボーンとモーフを VPD の書式で書き込む
+func (rep *VpdRepository) writePose(builder *strings.Builder, motion *vmd.VmdMotion, frame float32) {
+	modelName := motion.Name()
+	if modelName == "" {
+		modelName = "Vpd Model"
+	}
+
+	boneNames := make([]string, 0, motion.BoneFrames.Length())
+	motion.BoneFrames.ForEach(func(name string, boneNameFrames *vmd.BoneNameFrames) {
+		if boneNameFrames.Length() > 0 {
+			boneNames = append(boneNames, name)
+		}
+	})
+
+	morphNames := make([]string, 0, motion.MorphFrames.Length())
+	motion.MorphFrames.ForEach(func(name string, morphNameFrames *vmd.MorphNameFrames) {
+		if morphNameFrames.Length() > 0 {
+			morphNames = append(morphNames, name)
+		}
+	})
+
+	builder.WriteString("Vocaloid Pose Data file\r\n")
+	builder.WriteString("\r\n")
+	builder.WriteString(fmt.Sprintf("%s.osm;\t\t// 親ファイル名\r\n", modelName))
+	builder.WriteString(fmt.Sprintf("%d;\t\t\t\t// 総ポーズボーン数\r\n", len(boneNames)))
+	builder.WriteString("\r\n")
+
+	for i, boneName := range boneNames {
+		bf := motion.BoneFrames.Get(boneName).Get(frame)
+
+		position := mmath.NewMVec3()
+		if bf.Position != nil {
+			position = bf.Position
+		}
+		rotation := mmath.NewMQuaternion()
+		if bf.Rotation != nil {
+			rotation = bf.Rotation
+		}
+
+		builder.WriteString(fmt.Sprintf("Bone%d{%s\r\n", i, boneName))
+		builder.WriteString(fmt.Sprintf("  %.6f,%.6f,%.6f;\t\t\t\t// trans x,y,z\r\n",
+			position.X, position.Y, position.Z))
+		builder.WriteString(fmt.Sprintf("  %.6f,%.6f,%.6f,%.6f;\t\t// Quaternion x,y,z,w\r\n",
+			rotation.X, rotation.Y, rotation.Z, rotation.W))
+		builder.WriteString("}\r\n")
+		builder.WriteString("\r\n")
+	}
+
+	for i, morphName := range morphNames {
+		mf := motion.MorphFrames.Get(morphName).Get(frame)
+
+		builder.WriteString(fmt.Sprintf("Morph%d{%s\r\n", i, morphName))
+		builder.WriteString(fmt.Sprintf("  %.6f;\t\t\t\t// weight\r\n", mf.Ratio))
+		builder.WriteString("}\r\n")
+		builder.WriteString("\r\n")
+	}
 }
 
 func (rep *VpdRepository) CanLoad(path string) (bool, error) {
@@ -179,6 +256,7 @@ func (rep *VpdRepository) loadModel(motion *vmd.VmdMotion) error {
 	boneStartPattern := regexp.MustCompile(`(?:.*)(?:{)(.*)`)
 	bonePosPattern := regexp.MustCompile(`([+-]?\d+(?:\.\d+))(?:,)([+-]?\d+(?:\.\d+))(?:,)([+-]?\d+(?:\.\d+))(?:;)(?:.*trans.*)`)
 	boneRotPattern := regexp.MustCompile(`([+-]?\d+(?:\.\d+))(?:,)([+-]?\d+(?:\.\d+))(?:,)([+-]?\d+(?:\.\d+))(?:,)([+-]?\d+(?:\.\d+))(?:;)(?:.*Quaternion.*)`)
+	morphRatioPattern := regexp.MustCompile(`([+-]?\d+(?:\.\d+))(?:;)(?:.*weight.*)`)
 
 	var bf *vmd.BoneFrame
 	var boneName string
@@ -217,6 +295,18 @@ func (rep *VpdRepository) loadModel(motion *vmd.VmdMotion) error {
 				continue
 			}
 		}
+		{
+			// モーフ値(括弧開始の名前をモーフ名とする)
+			matches, err := rep.readText(line, morphRatioPattern)
+			if err == nil && len(matches) > 0 {
+				ratio, _ := strconv.ParseFloat(matches[1], 64)
+				mf := vmd.NewMorphFrame(0)
+				mf.Ratio = ratio
+
+				motion.AppendMorphFrame(boneName, mf)
+				continue
+			}
+		}
 	}
 
 	return nil
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+)
+
+func TestVpdRepository_Save(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_output.vpd")
+
+	motion := vmd.NewVmdMotion(path)
+	motion.SetName("初音ミク")
+
+	centerBf := vmd.NewBoneFrame(0)
+	centerBf.Position = &mmath.MVec3{X: 1, Y: -2.5, Z: 3}
+	motion.AppendBoneFrame("センター", centerBf)
+
+	armBf := vmd.NewBoneFrame(0)
+	armBf.Rotation = mmath.NewMQuaternionFromDegrees(10, 20, 30)
+	motion.AppendBoneFrame("左腕", armBf)
+
+	mf := vmd.NewMorphFrame(0)
+	mf.Ratio = 0.5
+	motion.AppendMorphFrame("まばたき", mf)
+
+	r := NewVpdRepository()
+	if err := r.Save("", motion, false); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	// Shift-JIS で書き込まれていること(UTF-8のままでは読み込めない)
+	if data, err := os.ReadFile(path); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	} else if string(data[:len("Vocaloid Pose Data file")]) != "Vocaloid Pose Data file" {
+		t.Errorf("Expected vpd signature, got %q", string(data[:30]))
+	}
+
+	reloadData, err := NewVpdRepository().Load(path)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	reloadMotion := reloadData.(*vmd.VmdMotion)
+
+	if reloadMotion.Name() != motion.Name() {
+		t.Errorf("Expected model name to be %q, got %q", motion.Name(), reloadMotion.Name())
+	}
+
+	reloadCenterBf := reloadMotion.BoneFrames.Get("センター").Get(0)
+	if !reloadCenterBf.Position.NearEquals(centerBf.Position, 1e-5) {
+		t.Errorf("Expected position to be %v, got %v", centerBf.Position, reloadCenterBf.Position)
+	}
+	if !reloadCenterBf.Rotation.NearEquals(mmath.NewMQuaternion(), 1e-5) {
+		t.Errorf("Expected rotation to be identity, got %v", reloadCenterBf.Rotation)
+	}
+
+	reloadArmBf := reloadMotion.BoneFrames.Get("左腕").Get(0)
+	if !reloadArmBf.Rotation.NearEquals(armBf.Rotation, 1e-5) {
+		t.Errorf("Expected rotation to be %v, got %v", armBf.Rotation, reloadArmBf.Rotation)
+	}
+
+	if !reloadMotion.MorphFrames.Contains("まばたき") {
+		t.Fatalf("Expected まばたき to be contained in morph frames")
+	}
+	if ratio := reloadMotion.MorphFrames.Get("まばたき").Get(0).Ratio; ratio != 0.5 {
+		t.Errorf("Expected ratio to be 0.5, got %v", ratio)
+	}
+}
@@ -0,0 +1,45 @@
+package usecase
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/infrastructure/repository"
+)
+
+// AugmentModel 変換に必要な不足ボーンをモデルに追加する
+// 元のモデルを変更しないように、RetargetedModel で作成したコピーに対して呼び出す
+func AugmentModel(model *pmx.PmxModel) error {
+	mlog.I("Insert missing bones ...")
+
+	insertedNames, err := model.Bones.InsertTraceBones()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range insertedNames {
+		mlog.I("Insert %s", name)
+	}
+
+	return nil
+}
+
+// SaveAugmentedModel ボーンを追加したモデルを、元のボーン名に戻して保存する
+func SaveAugmentedModel(model *pmx.PmxModel, retarget *pmx.BoneRetarget, dirPath string) (string, error) {
+	restoredModel, err := retarget.RestoredModel(model)
+	if err != nil {
+		return "", err
+	}
+
+	fileName := filepath.Base(model.Path())
+	path := filepath.Join(dirPath, fmt.Sprintf("%s_trace.pmx", strings.TrimSuffix(fileName, filepath.Ext(fileName))))
+
+	if err := repository.NewPmxRepository(false).Save(path, restoredModel, false); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
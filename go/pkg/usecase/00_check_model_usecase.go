@@ -0,0 +1,147 @@
+package usecase
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
+)
+
+// ModelCheckStage 変換処理の工程ごとのチェック結果
+type ModelCheckStage struct {
+	Name         string   // 工程名
+	IsRequired   bool     // 必須工程か(false の場合、ボーンが足りなくても処理を飛ばして続行する)
+	MissingBones []string // 工程で必要なボーンのうち、モデルに無いボーン
+}
+
+// Passed 工程で必要なボーンが全て揃っているか
+func (stage *ModelCheckStage) Passed() bool {
+	return len(stage.MissingBones) == 0
+}
+
+// ModelCheckReport 変換前のモデルのチェック結果
+type ModelCheckReport struct {
+	*pmx.BoneCheckResult
+	Stages []*ModelCheckStage
+}
+
+// Passed 必須工程に必要なボーンが全て揃っているか
+func (report *ModelCheckReport) Passed() bool {
+	for _, stage := range report.Stages {
+		if stage.IsRequired && !stage.Passed() {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckModel 変換を始める前に、モデルのボーン構成と各工程で必要なボーンが揃っているかを調べる
+func CheckModel(model *pmx.PmxModel, useRoot bool) *ModelCheckReport {
+	report := &ModelCheckReport{
+		BoneCheckResult: pmx.CheckStandardBones(model),
+		Stages:          make([]*ModelCheckStage, 0),
+	}
+
+	scaleBoneNames := make([]string, 0)
+	for _, segment := range scaleSegments {
+		scaleBoneNames = append(scaleBoneNames, segment[0], segment[1])
+	}
+
+	// 手首の向きは指から求めるので、指が無いモデルでも手首以外は変換できるように分けておく
+	rotateBoneNames := make([]string, 0)
+	rotateHandBoneNames := make([]string, 0)
+	for _, boneConfig := range rotateBoneConfigs(model) {
+		boneNames := []string{
+			boneConfig.Name, boneConfig.DirectionFrom, boneConfig.DirectionTo, boneConfig.UpFrom, boneConfig.UpTo}
+		if slices.ContainsFunc(boneNames, func(name string) bool { return strings.Contains(name, "指") }) {
+			rotateHandBoneNames = append(rotateHandBoneNames, boneNames...)
+		} else {
+			rotateBoneNames = append(rotateBoneNames, boneNames...)
+		}
+	}
+
+	toeBoneNames := make([]string, 0)
+	for _, name := range []pmx.StandardBoneName{pmx.ANKLE, pmx.TOE_EX, pmx.TOE_P, pmx.TOE_C} {
+		for _, direction := range name.Directions() {
+			toeBoneNames = append(toeBoneNames, name.StringFromDirection(direction))
+		}
+	}
+
+	limitBoneNames := make([]string, 0)
+	for _, limit := range jointLimits {
+		for _, direction := range limit.Name.Directions() {
+			limitBoneNames = append(limitBoneNames, limit.Name.StringFromDirection(direction))
+		}
+	}
+
+	twistBoneNames := make([]string, 0)
+	for _, name := range []pmx.StandardBoneName{pmx.ARM_TWIST, pmx.WRIST_TWIST} {
+		for _, direction := range name.Directions() {
+			twistBoneNames = append(twistBoneNames, name.StringFromDirection(direction))
+		}
+	}
+
+	// グルーブ・全ての親が無い場合は、その分の移動をセンターに残す
+	rootBoneNames := []string{pmx.CENTER.String()}
+	rootSplitBoneNames := []string{pmx.GROOVE.String()}
+	if useRoot {
+		rootSplitBoneNames = append(rootSplitBoneNames, pmx.ROOT.String())
+	}
+
+	for _, stage := range []struct {
+		name       string
+		isRequired bool
+		boneNames  []string
+	}{
+		{"Scale", false, scaleBoneNames},
+		{"Rotate", true, rotateBoneNames},
+		{"Rotate Hand", false, rotateHandBoneNames},
+		{"Toe", false, toeBoneNames},
+		{"Limit", false, limitBoneNames},
+		{"Twist", false, twistBoneNames},
+		{"Root", true, rootBoneNames},
+		{"Root Split", false, rootSplitBoneNames},
+	} {
+		report.Stages = append(report.Stages, &ModelCheckStage{
+			Name:         stage.name,
+			IsRequired:   stage.isRequired,
+			MissingBones: missingBoneNames(model, stage.boneNames),
+		})
+	}
+
+	return report
+}
+
+// Log チェック結果を出力する
+func (report *ModelCheckReport) Log() {
+	mlog.I("Missing standard bones: %d %s", len(report.MissingBones), strings.Join(report.MissingBones, ", "))
+	mlog.I("Extra bones: %d %s", len(report.ExtraBones), strings.Join(report.ExtraBones, ", "))
+	mlog.I("Misparented bones: %d", len(report.MisparentedBones))
+	for _, bone := range report.MisparentedBones {
+		mlog.I("  %s: parent %s (expected: %s)", bone.Name, bone.ParentName, strings.Join(bone.ExpectedParentNames, " / "))
+	}
+	mlog.I("Missing IK chains: %d %s", len(report.MissingIkChains), strings.Join(report.MissingIkChains, ", "))
+
+	for _, stage := range report.Stages {
+		switch {
+		case stage.Passed():
+			mlog.I("[PASS] %s", stage.Name)
+		case stage.IsRequired:
+			mlog.I("[FAIL] %s: missing %s", stage.Name, strings.Join(stage.MissingBones, ", "))
+		default:
+			mlog.I("[FAIL] %s (optional, partially skipped): missing %s", stage.Name, strings.Join(stage.MissingBones, ", "))
+		}
+	}
+}
+
+// missingBoneNames モデルに無いボーン名(重複なし)
+func missingBoneNames(model *pmx.PmxModel, boneNames []string) []string {
+	missingNames := make([]string, 0)
+	for _, boneName := range boneNames {
+		if !model.Bones.ContainsByName(boneName) && !slices.Contains(missingNames, boneName) {
+			missingNames = append(missingNames, boneName)
+		}
+	}
+	return missingNames
+}
@@ -0,0 +1,32 @@
+package usecase
+
+import (
+	"testing"
+)
+
+func TestCheckModel_WithoutGroove(t *testing.T) {
+	model := loadTestModel(t)
+
+	report := CheckModel(model, true)
+	if !report.Passed() {
+		t.Fatalf("Expected trace model to pass")
+	}
+
+	// グルーブが無いモデルも変換できる
+	grooveBone, err := model.Bones.GetByName("グルーブ")
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	grooveBone.SetName("グルーブ_unused")
+	model.Bones.UpdateNameIndexes()
+
+	report = CheckModel(model, true)
+	if !report.Passed() {
+		t.Errorf("Expected model without groove to pass")
+	}
+	for _, stage := range report.Stages {
+		if stage.Name == "Root Split" && (stage.Passed() || stage.IsRequired) {
+			t.Errorf("Expected root split stage to be optional and fail, got %v", stage.MissingBones)
+		}
+	}
+}
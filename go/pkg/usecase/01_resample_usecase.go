@@ -0,0 +1,49 @@
+package usecase
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mjson"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+)
+
+// DEFAULT_FPS 元動画のフレームレートの指定が無い場合のフレームレート
+const DEFAULT_FPS = vmd.VMD_FPS
+
+// FPS_SIDECAR_EXT クリップごとのフレームレートを書いたファイルの拡張子(<クリップ名>.fps)
+const FPS_SIDECAR_EXT = ".fps"
+
+// Resample 元動画のフレームレートのフレーム番号を、MMDのフレームレートのフレーム番号に置き換える
+// 元動画のフレームレートは <クリップ名>.fps、JSON の fps、defaultFps の順に優先する
+func Resample(frames *mjson.Frames, defaultFps float64, motionNum, allNum int) {
+	fps := clipFps(frames, defaultFps)
+	if mmath.NearEquals(fps, vmd.VMD_FPS, 1e-6) {
+		return
+	}
+
+	mlog.I("[%d/%d] Resample %.2ffps -> %.0ffps ...", motionNum, allNum, fps, vmd.VMD_FPS)
+
+	frames.Resample(fps, vmd.VMD_FPS)
+}
+
+// clipFps クリップの元動画のフレームレート
+func clipFps(frames *mjson.Frames, defaultFps float64) float64 {
+	sidecarPath := strings.TrimSuffix(frames.Path, filepath.Ext(frames.Path)) + FPS_SIDECAR_EXT
+	if data, err := os.ReadFile(sidecarPath); err == nil {
+		if fps, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64); err == nil && fps > 0 {
+			return fps
+		}
+		mlog.W("Invalid fps file: %s", sidecarPath)
+	}
+
+	if frames.Fps > 0 {
+		return frames.Fps
+	}
+
+	return defaultFps
+}
@@ -36,7 +36,7 @@ func Unpack(dirPath string) ([]*mjson.Frames, error) {
 		// JSONデータを読み込んで展開
 		file, err := os.Open(path)
 		if err != nil {
-			mlog.E(fmt.Sprintf("[%s] Failed to open file: %v", path), err)
+			mlog.E(fmt.Sprintf("[%s] Failed to open file", path), err)
 			break
 		}
 		defer file.Close()
@@ -46,7 +46,7 @@ func Unpack(dirPath string) ([]*mjson.Frames, error) {
 		decoder := json.NewDecoder(file)
 		err = decoder.Decode(frames)
 		if err != nil {
-			mlog.E(fmt.Sprintf("[%s] Failed to decode json: %v", path), err)
+			mlog.E(fmt.Sprintf("[%s] Failed to decode json", path), err)
 			break
 		}
 
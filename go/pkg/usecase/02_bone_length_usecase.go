@@ -0,0 +1,165 @@
+package usecase
+
+import (
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mjson"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/utils"
+)
+
+// BONE_LENGTH_CONFIDENCE_THRESHOLD 骨格の長さの推定に使うフレームの信頼度の閾値
+const BONE_LENGTH_CONFIDENCE_THRESHOLD = 0.6
+
+// BONE_LENGTH_MIN_CONFIDENCE 関節の動かしやすさ(信頼度の逆数)を求める際の信頼度の下限
+const BONE_LENGTH_MIN_CONFIDENCE = 0.01
+
+// BONE_LENGTH_ITERATIONS 親子の両方を動かして長さを揃える反復回数
+const BONE_LENGTH_ITERATIONS = 10
+
+// FixBoneLength 区間ごとに安定した長さを推定し、各フレームの親子関節をその長さの球面上に載せ直す
+// 長さは信頼度の高いフレームの中央値(該当フレームがない場合は全フレームの中央値)
+// 長さの差は親子の信頼度の逆数の比で割り振り、信頼度の低い関節ほど大きく動かす(根元の関節は動かさない)
+// 関節の信頼度は Mediapipe の可視度があればそれ、無ければフレームの信頼度(信頼度が無いデータは全て同じ)
+func FixBoneLength(frames *mjson.Frames, mapping *mjson.JointMapping, motionNum, allNum int) {
+	mlog.I("[%d/%d] Fix Bone Length ...", motionNum, allNum)
+
+	confidentLengths := make(map[string][]float64)
+	allLengths := make(map[string][]float64)
+	hasConfidence := false
+
+	for _, frame := range frames.Frames {
+		hasConfidence = hasConfidence || frame.Confidential > 0
+		positions, _ := jointPositions(frame.Joint3D, mapping)
+
+		for _, joint := range mapping.JointsByHierarchy() {
+			pos, ok := positions[joint.Name]
+			if !ok || joint.Parent == "" {
+				continue
+			}
+			parentPos, ok := positions[joint.Parent]
+			if !ok {
+				continue
+			}
+
+			length := pos.Distance(parentPos)
+			allLengths[joint.Name] = append(allLengths[joint.Name], length)
+			if frame.Confidential >= BONE_LENGTH_CONFIDENCE_THRESHOLD {
+				confidentLengths[joint.Name] = append(confidentLengths[joint.Name], length)
+			}
+		}
+	}
+
+	lengths := make(map[string]float64)
+	for jointName, values := range allLengths {
+		if confidentValues, ok := confidentLengths[jointName]; ok {
+			values = confidentValues
+		}
+		lengths[jointName] = mmath.Median(values)
+	}
+
+	bar := utils.NewProgressBar(len(frames.Frames))
+
+	for _, frame := range frames.Frames {
+		bar.Increment()
+
+		positions, keys := jointPositions(frame.Joint3D, mapping)
+
+		// 関節ごとの動かしやすさ
+		mobilities := make(map[string]float64, len(positions))
+		for _, joint := range mapping.JointsByHierarchy() {
+			if _, ok := positions[joint.Name]; !ok || joint.Parent == "" {
+				continue
+			}
+			confidence := jointConfidence(frame, joint.Name, hasConfidence)
+			mobilities[joint.Name] = 1 / max(confidence, BONE_LENGTH_MIN_CONFIDENCE)
+		}
+
+		// 親子の両方を、動かしやすさの比で長さの差だけ動かす
+		for range BONE_LENGTH_ITERATIONS {
+			for _, joint := range mapping.JointsByHierarchy() {
+				length, pos, parentPos, ok := boneLengthSegment(joint, lengths, positions)
+				if !ok {
+					continue
+				}
+				totalMobility := mobilities[joint.Name] + mobilities[joint.Parent]
+				if totalMobility == 0 {
+					continue
+				}
+
+				direction := pos.Subed(parentPos)
+				currentLength := direction.Length()
+				if currentLength == 0 {
+					continue
+				}
+
+				diff := direction.MuledScalar((currentLength - length) / currentLength)
+				pos.Sub(diff.MuledScalar(mobilities[joint.Name] / totalMobility))
+				parentPos.Add(diff.MuledScalar(mobilities[joint.Parent] / totalMobility))
+			}
+		}
+
+		// 残った差は、親から順に元の向きを保ったまま子関節で揃える
+		for _, joint := range mapping.JointsByHierarchy() {
+			length, pos, parentPos, ok := boneLengthSegment(joint, lengths, positions)
+			if !ok {
+				continue
+			}
+			direction := pos.Subed(parentPos)
+			if direction.Length() == 0 {
+				continue
+			}
+			*pos = *parentPos.Added(direction.Normalize().MuledScalar(length))
+		}
+
+		for jointName, pos := range positions {
+			frame.Joint3D[keys[jointName]] = mjson.Position{X: pos.X, Y: pos.Y, Z: pos.Z}
+		}
+	}
+
+	bar.Finish()
+}
+
+// boneLengthSegment 関節の長さと、関節・親関節の位置(どれかが無い場合は false)
+func boneLengthSegment(
+	joint *mjson.JointDefinition, lengths map[string]float64, positions map[string]*mmath.MVec3,
+) (float64, *mmath.MVec3, *mmath.MVec3, bool) {
+	length, ok := lengths[joint.Name]
+	if !ok {
+		return 0, nil, nil, false
+	}
+	pos, ok := positions[joint.Name]
+	if !ok {
+		return 0, nil, nil, false
+	}
+	parentPos, ok := positions[joint.Parent]
+	if !ok {
+		return 0, nil, nil, false
+	}
+	return length, pos, parentPos, true
+}
+
+// jointConfidence 関節の信頼度(Mediapipe の可視度があればそれ、無ければフレームの信頼度)
+func jointConfidence(frame mjson.Frame, jointName string, hasConfidence bool) float64 {
+	if pos, ok := frame.Mediapipe[jointName]; ok {
+		return pos.Visibility
+	}
+	if hasConfidence {
+		return frame.Confidential
+	}
+	return 1.0
+}
+
+// jointPositions 関節名ごとの位置と、元データ上のキーを返す
+func jointPositions(joints map[string]mjson.Position, mapping *mjson.JointMapping) (map[string]*mmath.MVec3, map[string]string) {
+	positions := make(map[string]*mmath.MVec3, len(joints))
+	keys := make(map[string]string, len(joints))
+
+	for key, pos := range joints {
+		if jointName, ok := mapping.JointName(key); ok {
+			positions[jointName] = &mmath.MVec3{X: pos.X, Y: pos.Y, Z: pos.Z}
+			keys[jointName] = key
+		}
+	}
+
+	return positions, keys
+}
@@ -0,0 +1,77 @@
+package usecase
+
+import (
+	"math"
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mjson"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+)
+
+// newBoneLengthTestFrame 骨盤から左足首までの関節(ひざから足首までの長さは shinLength)
+func newBoneLengthTestFrame(shinLength, confidence float64) mjson.Frame {
+	return mjson.Frame{
+		Confidential: confidence,
+		Joint3D: map[string]mjson.Position{
+			"pelvis":     {X: 0, Y: 1.0, Z: 0},
+			"left_hip":   {X: 0.1, Y: 0.9, Z: 0},
+			"left_knee":  {X: 0.1, Y: 0.5, Z: 0},
+			"left_ankle": {X: 0.1, Y: 0.5 - shinLength, Z: 0},
+		},
+	}
+}
+
+func TestFixBoneLength(t *testing.T) {
+	mapping, err := mjson.LoadJointMapping("smpl")
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	// フレームごとに揺れる長さは、中央値に揃う
+	frames := &mjson.Frames{Frames: map[int]mjson.Frame{}}
+	for fno, shinLength := range []float64{0.40, 0.44, 0.36, 0.40, 0.42} {
+		frames.Frames[fno] = newBoneLengthTestFrame(shinLength, 0.9)
+	}
+	FixBoneLength(frames, mapping, 1, 1)
+
+	position := func(frame mjson.Frame, jointName string) *mmath.MVec3 {
+		pos := frame.Joint3D[jointName]
+		return &mmath.MVec3{X: pos.X, Y: pos.Y, Z: pos.Z}
+	}
+	for fno, frame := range frames.Frames {
+		if length := position(frame, "left_ankle").Distance(position(frame, "left_knee")); math.Abs(length-0.40) > 1e-6 {
+			t.Errorf("[%d] Expected shin length to be 0.40, got %v", fno, length)
+		}
+		if length := position(frame, "left_knee").Distance(position(frame, "left_hip")); math.Abs(length-0.40) > 1e-6 {
+			t.Errorf("[%d] Expected thigh length to be 0.40, got %v", fno, length)
+		}
+		if !position(frame, "pelvis").NearEquals(&mmath.MVec3{X: 0, Y: 1.0, Z: 0}, 1e-8) {
+			t.Errorf("[%d] Expected pelvis not to move, got %v", fno, position(frame, "pelvis"))
+		}
+	}
+
+	// 信頼度の低い子関節は、信頼度の高い親関節より大きく動く
+	frames = &mjson.Frames{Frames: map[int]mjson.Frame{}}
+	for fno, shinLength := range []float64{0.40, 0.40, 0.40, 0.50} {
+		frames.Frames[fno] = newBoneLengthTestFrame(shinLength, 0.9)
+	}
+	frame := frames.Frames[3]
+	frame.Mediapipe = map[string]mjson.PositionVisibility{
+		"left_knee":  {Visibility: 0.9},
+		"left_ankle": {Visibility: 0.2},
+	}
+	frames.Frames[3] = frame
+	original := newBoneLengthTestFrame(0.50, 0.9)
+
+	FixBoneLength(frames, mapping, 1, 1)
+
+	fixed := frames.Frames[3]
+	kneeMove := position(fixed, "left_knee").Distance(position(original, "left_knee"))
+	ankleMove := position(fixed, "left_ankle").Distance(position(original, "left_ankle"))
+	if kneeMove == 0 || ankleMove <= kneeMove {
+		t.Errorf("Expected ankle to move more than knee, got ankle %v, knee %v", ankleMove, kneeMove)
+	}
+	if length := position(fixed, "left_ankle").Distance(position(fixed, "left_knee")); math.Abs(length-0.40) > 1e-6 {
+		t.Errorf("Expected shin length to be 0.40, got %v", length)
+	}
+}
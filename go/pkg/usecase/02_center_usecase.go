@@ -1,9 +1,14 @@
 package usecase
 
-import "github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mjson"
+import (
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mjson"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
+)
 
-func CalcMinYZ(allFrames []*mjson.Frames) (float64, float64) {
-	// 最も早いフレームの最も手前の"pelvis"のZ座標を取得
+func CalcMinYZ(allFrames []*mjson.Frames, mapping *mjson.JointMapping) (float64, float64) {
+	// 最も早いフレームの最も手前の基準関節のZ座標を取得
 	minFrame := 0
 
 	for _, frames := range allFrames {
@@ -31,7 +36,7 @@ func CalcMinYZ(allFrames []*mjson.Frames) (float64, float64) {
 			continue
 		}
 
-		if pos, ok := minFrameData.GlobalJoint3D["pelvis"]; ok {
+		if pos, ok := mapping.RootPosition(minFrameData.GlobalJoint3D); ok {
 			if pos.Y < minY {
 				minY = pos.Y
 			}
@@ -44,3 +49,86 @@ func CalcMinYZ(allFrames []*mjson.Frames) (float64, float64) {
 
 	return minY, maxZ
 }
+
+// SCALE_CONFIDENCE_THRESHOLD スケール計算に使うフレームの信頼度の閾値
+const SCALE_CONFIDENCE_THRESHOLD = 0.6
+
+// scaleSegments スケール計算に使う区間(股関節→ひざ→足首、上半身→首)
+var scaleSegments = [][2]string{
+	{"右足", "右ひざ"},
+	{"右ひざ", "右足首"},
+	{"左足", "左ひざ"},
+	{"左ひざ", "左足首"},
+	{"上半身", "首"},
+}
+
+// CalcScale トラッキングした区間の長さとモデルのボーンの長さの比から、人物ごとのスケールを求める
+// 計算できない場合は既定の SCALE を返す
+func CalcScale(frames *mjson.Frames, mapping *mjson.JointMapping, model *pmx.PmxModel, motionNum, allNum int) float64 {
+	modelLength := 0.0
+	segments := make([][2]string, 0, len(scaleSegments))
+	for _, segment := range scaleSegments {
+		fromBone, err := model.Bones.GetByName(segment[0])
+		if err != nil {
+			continue
+		}
+		toBone, err := model.Bones.GetByName(segment[1])
+		if err != nil {
+			continue
+		}
+		modelLength += fromBone.Position.Distance(toBone.Position)
+		segments = append(segments, segment)
+	}
+
+	if len(segments) == 0 || modelLength == 0 {
+		mlog.W("[%d/%d] Scale segments not found in model, use default scale: %.4f", motionNum, allNum, SCALE)
+		return SCALE
+	}
+
+	ratios := make([]float64, 0, len(frames.Frames))
+	allRatios := make([]float64, 0, len(frames.Frames))
+	for _, frame := range frames.Frames {
+		bonePositions := mapping.BonePositions(frame.Joint3D, func(pos mjson.Position) *mmath.MVec3 {
+			return mapping.Flipped(pos)
+		})
+
+		trackedLength := 0.0
+		for _, segment := range segments {
+			fromPos, ok := bonePositions[segment[0]]
+			if !ok {
+				trackedLength = 0
+				break
+			}
+			toPos, ok := bonePositions[segment[1]]
+			if !ok {
+				trackedLength = 0
+				break
+			}
+			trackedLength += fromPos.Distance(toPos)
+		}
+
+		if trackedLength == 0 {
+			continue
+		}
+
+		allRatios = append(allRatios, modelLength/trackedLength)
+		if frame.Confidential >= SCALE_CONFIDENCE_THRESHOLD {
+			ratios = append(ratios, modelLength/trackedLength)
+		}
+	}
+
+	// 信頼度の高いフレームがない場合は全フレームから求める
+	if len(ratios) == 0 {
+		ratios = allRatios
+	}
+
+	if len(ratios) == 0 {
+		mlog.W("[%d/%d] Scale segments not tracked, use default scale: %.4f", motionNum, allNum, SCALE)
+		return SCALE
+	}
+
+	scale := mmath.Median(ratios)
+	mlog.I("[%d/%d] Scale: %.4f", motionNum, allNum, scale)
+
+	return scale
+}
@@ -11,9 +11,11 @@ import (
 	"github.com/miu200521358/mmd-auto-trace-5/pkg/utils"
 )
 
+// SCALE 既定のスケール(モデルから計算できない場合に使用)
 const SCALE = 0.1259496 * 100
 
-func Move(frames *mjson.Frames, motionNum, allNum int, minY, maxZ float64) *vmd.VmdMotion {
+// Move 関節定義に従って、トラッカーの関節位置をボーン位置モーションに変換する
+func Move(frames *mjson.Frames, mapping *mjson.JointMapping, scale float64, motionNum, allNum int, minY, maxZ float64) *vmd.VmdMotion {
 	mlog.I("[%d/%d] Convert Move ...", motionNum, allNum)
 
 	bar := utils.NewProgressBar(len(frames.Frames))
@@ -23,26 +25,17 @@ func Move(frames *mjson.Frames, motionNum, allNum int, minY, maxZ float64) *vmd.
 	for fno, frame := range frames.Frames {
 		bar.Increment()
 
-		for jointName, pos := range frame.Joint3D {
-			// ボーン名がある場合、ボーン移動モーションにも出力
-			if boneName, ok := joint2bones[string(jointName)]; ok {
-				bf := vmd.NewBoneFrame(float32(fno))
-				bf.Position = &mmath.MVec3{X: pos.X, Y: -pos.Y - minY, Z: pos.Z - maxZ}
-				bf.Position.MulScalar(SCALE)
-				movMotion.AppendBoneFrame(boneName, bf)
-			}
-		}
+		bonePositions := mapping.BonePositions(frame.Joint3D, func(pos mjson.Position) *mmath.MVec3 {
+			v := mapping.Flipped(pos)
+			v.Y -= minY
+			v.Z -= maxZ
+			return v.MulScalar(scale)
+		})
 
-		// 追加で計算するボーン
-		{
-			bf := vmd.NewBoneFrame(float32(fno))
-			bf.Position = movMotion.BoneFrames.Get("右足").Get(float32(fno)).Position.Added(movMotion.BoneFrames.Get("左足").Get(float32(fno)).Position).DivedScalar(2)
-			movMotion.AppendBoneFrame("下半身先", bf)
-		}
-		{
+		for boneName, pos := range bonePositions {
 			bf := vmd.NewBoneFrame(float32(fno))
-			bf.Position = movMotion.BoneFrames.Get("上半身").Get(float32(fno)).Position.Copy()
-			movMotion.AppendBoneFrame("下半身", bf)
+			bf.Position = pos
+			movMotion.AppendBoneFrame(boneName, bf)
 		}
 	}
 
@@ -50,35 +43,3 @@ func Move(frames *mjson.Frames, motionNum, allNum int, minY, maxZ float64) *vmd.
 
 	return movMotion
 }
-
-var joint2bones = map[string]string{
-	"pelvis":          "上半身",
-	"spine2":          "上半身2",
-	"spine3":          "上半身3",
-	"neck":            "首",
-	"head":            "頭",
-	"right_collar":    "右肩",
-	"right_shoulder":  "右腕",
-	"right_elbow":     "右ひじ",
-	"right_wrist":     "右手首",
-	"left_collar":     "左肩",
-	"left_shoulder":   "左腕",
-	"left_elbow":      "左ひじ",
-	"left_wrist":      "左手首",
-	"right_hip":       "右足",
-	"right_knee":      "右ひざ",
-	"right_ankle":     "右足首",
-	"left_hip":        "左足",
-	"left_knee":       "左ひざ",
-	"left_ankle":      "左足首",
-	"right_eye":       "右目",
-	"left_eye":        "左目",
-	"right_ear":       "右耳",
-	"left_ear":        "左耳",
-	"left_big_toe":    "左つま先親",
-	"left_small_toe":  "左つま先子",
-	"left_heel":       "左かかと",
-	"right_big_toe":   "右つま先親",
-	"right_small_toe": "右つま先子",
-	"right_heel":      "右かかと",
-}
@@ -0,0 +1,28 @@
+package usecase
+
+import (
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/utils"
+)
+
+// SPIKE_DEGREE_PER_FRAME 1フレームで突出とみなす回転角度(度)
+const SPIKE_DEGREE_PER_FRAME = 45.0
+
+// FixContinuity 全ボーンの回転の符号を揃え、1フレームだけ突出した回転を前後から補間して修復する
+func FixContinuity(rotMotion *vmd.VmdMotion, motionNum, allNum int) {
+	mlog.I("[%d/%d] Fix Continuity ...", motionNum, allNum)
+
+	bar := utils.NewProgressBar(len(rotMotion.BoneFrames.Names()))
+
+	rotMotion.BoneFrames.ForEach(func(boneName string, boneNameFrames *vmd.BoneNameFrames) {
+		bar.Increment()
+
+		for _, fno := range boneNameFrames.FixRotationContinuity(mmath.DegToRad(SPIKE_DEGREE_PER_FRAME)) {
+			mlog.I("[%d/%d] Repair spike %s[%.0f]", motionNum, allNum, boneName, fno)
+		}
+	})
+
+	bar.Finish()
+}
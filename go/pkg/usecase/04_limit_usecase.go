@@ -0,0 +1,95 @@
+package usecase
+
+import (
+	"strings"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/utils"
+)
+
+// LimitJoints 関節の可動域を超える回転を、スイングと捩りに分けて可動域内に収める
+func LimitJoints(rotMotion *vmd.VmdMotion, model *pmx.PmxModel, motionNum, allNum int) *vmd.VmdMotion {
+	mlog.I("[%d/%d] Limit Joints ...", motionNum, allNum)
+
+	limitMotion, err := rotMotion.Copy()
+	if err != nil {
+		mlog.E("Failed to copy motion", err)
+		return rotMotion
+	}
+	limitMotion.SetPath(strings.Replace(rotMotion.Path(), "_rotate.vmd", "_limit.vmd", -1))
+
+	bar := utils.NewProgressBar(len(jointLimits))
+
+	for _, limit := range jointLimits {
+		bar.Increment()
+
+		for _, direction := range limit.Name.Directions() {
+			boneName := limit.Name.StringFromDirection(direction)
+			if !limitMotion.BoneFrames.Contains(boneName) {
+				continue
+			}
+			bone, err := model.Bones.GetByName(boneName)
+			if err != nil {
+				continue
+			}
+
+			twistAxis := limit.twistAxis(bone)
+			swingMax := mmath.DegToRad(limit.SwingMax)
+			twistMin := mmath.DegToRad(limit.TwistMin)
+			twistMax := mmath.DegToRad(limit.TwistMax)
+
+			limitMotion.BoneFrames.Get(boneName).ForEach(func(fno float32, bf *vmd.BoneFrame) bool {
+				if bf.Rotation == nil {
+					return true
+				}
+
+				clampedQuat, isClamped := bf.Rotation.ClampedSwingTwist(twistAxis, swingMax, twistMin, twistMax)
+				if isClamped {
+					mlog.I("[%d/%d] Clamp %s[%.0f]: %s -> %s", motionNum, allNum, boneName, fno,
+						bf.Rotation.ToMMDDegrees().String(), clampedQuat.ToMMDDegrees().String())
+					bf.Rotation = clampedQuat
+				}
+
+				return true
+			})
+		}
+	}
+
+	bar.Finish()
+
+	return limitMotion
+}
+
+type jointLimit struct {
+	Name     pmx.StandardBoneName
+	IsHinge  bool    // 蝶番関節か(true: ボーンの向きと正面(-Z)に直交する軸回り, false: ボーンの向きを捩り軸とする)
+	SwingMax float64 // 捩り軸以外の回転の最大角度(度)
+	TwistMin float64 // 捩り軸回りの最小角度(度)
+	TwistMax float64 // 捩り軸回りの最大角度(度)
+}
+
+// twistAxis 捩り軸。蝶番関節はボーンの向きと正面(-Z)に直交する軸(曲げる向きが正になる)
+func (limit *jointLimit) twistAxis(bone *pmx.Bone) *mmath.MVec3 {
+	if limit.IsHinge {
+		return bone.LocalAxis.Cross(mmath.MVec3UnitZNeg).Normalize()
+	}
+	return bone.LocalAxis.Normalized()
+}
+
+var jointLimits = []*jointLimit{
+	{Name: pmx.LOWER, SwingMax: 60, TwistMin: -60, TwistMax: 60},
+	{Name: pmx.UPPER, SwingMax: 60, TwistMin: -60, TwistMax: 60},
+	{Name: pmx.UPPER2, SwingMax: 45, TwistMin: -45, TwistMax: 45},
+	{Name: pmx.NECK, SwingMax: 60, TwistMin: -80, TwistMax: 80},
+	{Name: pmx.HEAD, SwingMax: 50, TwistMin: -70, TwistMax: 70},
+	{Name: pmx.SHOULDER, SwingMax: 30, TwistMin: -20, TwistMax: 20},
+	{Name: pmx.ARM, SwingMax: 170, TwistMin: -100, TwistMax: 100},
+	{Name: pmx.ELBOW, IsHinge: true, SwingMax: 30, TwistMin: -5, TwistMax: 160},
+	{Name: pmx.WRIST, SwingMax: 85, TwistMin: -30, TwistMax: 30},
+	{Name: pmx.LEG, SwingMax: 130, TwistMin: -60, TwistMax: 60},
+	{Name: pmx.KNEE, IsHinge: true, SwingMax: 10, TwistMin: -160, TwistMax: 5},
+	{Name: pmx.ANKLE, SwingMax: 60, TwistMin: -30, TwistMax: 30},
+}
@@ -0,0 +1,63 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+)
+
+func TestLimitJoints_Hinge(t *testing.T) {
+	model := loadTestModel(t)
+
+	tests := []struct {
+		boneName      string
+		naturalDegree float64 // 曲げる向きの回転(可動域内)
+		reverseDegree float64 // 逆向きに曲げた回転(可動域外)
+		limitDegree   float64 // 逆向きの可動域の端
+		isFrontBend   bool    // 曲げると子ボーンが前(-Z)に出るか
+	}{
+		{"左ひじ", 90, -30, -5, true},
+		{"右ひじ", 90, -30, -5, true},
+		{"左ひざ", -90, 30, 5, false},
+		{"右ひざ", -90, 30, 5, false},
+	}
+
+	for _, test := range tests {
+		bone, err := model.Bones.GetByName(test.boneName)
+		if err != nil {
+			t.Fatalf("Expected %s to exist, got %q", test.boneName, err)
+		}
+		limit := &jointLimit{IsHinge: true}
+		axis := limit.twistAxis(bone)
+		hinge := func(degree float64) *mmath.MQuaternion {
+			return mmath.NewMQuaternionFromAxisAnglesRotate(axis, mmath.DegToRad(degree))
+		}
+
+		// 蝶番の軸の向き: ひじは前に、ひざは後ろに曲がる
+		bent := hinge(test.naturalDegree).MulVec3(bone.LocalAxis)
+		if (bent.Z < 0) != test.isFrontBend {
+			t.Errorf("Expected %s to bend front=%v, got %v", test.boneName, test.isFrontBend, bent)
+		}
+
+		rotMotion := vmd.NewVmdMotion("")
+		for fno, degree := range []float64{test.naturalDegree, test.reverseDegree} {
+			bf := vmd.NewBoneFrame(float32(fno))
+			bf.Rotation = hinge(degree)
+			rotMotion.AppendBoneFrame(test.boneName, bf)
+		}
+
+		limitMotion := LimitJoints(rotMotion, model, 1, 1)
+		bfs := limitMotion.BoneFrames.Get(test.boneName)
+
+		// 可動域内の回転はそのまま
+		if degree := quatDegree(bfs.Get(0).Rotation, hinge(test.naturalDegree)); degree > 1e-4 {
+			t.Errorf("Expected %s in range to be unchanged, got %v degrees apart", test.boneName, degree)
+		}
+		// 逆向きに曲げた回転は可動域の端に収まる
+		if degree := quatDegree(bfs.Get(1).Rotation, hinge(test.limitDegree)); degree > 1e-4 {
+			t.Errorf("Expected %s bent backwards to be clamped to %v degrees, got %v",
+				test.boneName, test.limitDegree, bfs.Get(1).Rotation.ToMMDDegrees())
+		}
+	}
+}
@@ -1,28 +1,22 @@
 package usecase
 
 import (
+	"slices"
 	"strings"
 
 	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
 	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
 	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
 	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
-	"github.com/miu200521358/mmd-auto-trace-5/pkg/infrastructure/repository"
 	"github.com/miu200521358/mmd-auto-trace-5/pkg/utils"
 )
 
-func Rotate(moveMotion *vmd.VmdMotion, modelPath string, motionNum, allNum int) *vmd.VmdMotion {
+func Rotate(moveMotion *vmd.VmdMotion, pmxModel *pmx.PmxModel, motionNum, allNum int) *vmd.VmdMotion {
 	mlog.I("[%d/%d] Convert Rotate ...", motionNum, allNum)
 
-	// モデル読み込み
-	pr := repository.NewPmxRepository(false)
-	data, err := pr.Load(modelPath)
-	if err != nil {
-		mlog.E("Failed to read pmx: %v", err)
-	}
-	pmxModel := data.(*pmx.PmxModel)
+	configs := rotateBoneConfigs(pmxModel)
 
-	bar := utils.NewProgressBar(len(boneConfigs))
+	bar := utils.NewProgressBar(len(configs))
 
 	rotMotion := vmd.NewVmdMotion(strings.Replace(moveMotion.Path(), "_move.vmd", "_rotate.vmd", -1))
 
@@ -33,16 +27,27 @@ func Rotate(moveMotion *vmd.VmdMotion, modelPath string, motionNum, allNum int)
 		return true
 	})
 
-	for _, boneConfig := range boneConfigs {
+	// 背骨の位置は、モデルの背骨ボーンに合わせて割り振り直したものを使う
+	posMotion := resampleSpine(moveMotion, pmxModel)
+
+	for _, boneConfig := range configs {
 		bar.Increment()
 
-		if !moveMotion.BoneFrames.Contains(boneConfig.Name) || !moveMotion.BoneFrames.Contains(boneConfig.DirectionFrom) ||
-			!moveMotion.BoneFrames.Contains(boneConfig.DirectionTo) || !moveMotion.BoneFrames.Contains(boneConfig.UpFrom) ||
-			!moveMotion.BoneFrames.Contains(boneConfig.UpTo) {
+		if !posMotion.BoneFrames.Contains(boneConfig.Name) || !posMotion.BoneFrames.Contains(boneConfig.DirectionFrom) ||
+			!posMotion.BoneFrames.Contains(boneConfig.DirectionTo) || !posMotion.BoneFrames.Contains(boneConfig.UpFrom) ||
+			!posMotion.BoneFrames.Contains(boneConfig.UpTo) {
+			continue
+		}
+
+		// モデルに無いボーン(対応付けできなかったボーン)は回転を求めない
+		if !pmxModel.Bones.ContainsByName(boneConfig.Name) || !pmxModel.Bones.ContainsByName(boneConfig.DirectionFrom) ||
+			!pmxModel.Bones.ContainsByName(boneConfig.DirectionTo) || !pmxModel.Bones.ContainsByName(boneConfig.UpFrom) ||
+			!pmxModel.Bones.ContainsByName(boneConfig.UpTo) {
+			mlog.W("[%d/%d] Skip %s: bone not found in model", motionNum, allNum, boneConfig.Name)
 			continue
 		}
 
-		moveMotion.BoneFrames.Get(boneConfig.Name).ForEach(func(fno float32, bf *vmd.BoneFrame) bool {
+		posMotion.BoneFrames.Get(boneConfig.Name).ForEach(func(fno float32, bf *vmd.BoneFrame) bool {
 			// モデルのボーン角度
 			boneDirectionFromBone, _ := pmxModel.Bones.GetByName(boneConfig.DirectionFrom)
 			boneDirectionFrom := boneDirectionFromBone.Position
@@ -60,10 +65,10 @@ func Rotate(moveMotion *vmd.VmdMotion, modelPath string, motionNum, allNum int)
 			boneQuat := mmath.NewMQuaternionFromDirection(boneDirectionVector, boneCrossVector)
 
 			// モーションのボーン角度
-			motionDirectionFromPos := moveMotion.BoneFrames.Get(boneConfig.DirectionFrom).Get(fno).Position
-			motionDirectionToPos := moveMotion.BoneFrames.Get(boneConfig.DirectionTo).Get(fno).Position
-			motionUpFromPos := moveMotion.BoneFrames.Get(boneConfig.UpFrom).Get(fno).Position
-			motionUpToPos := moveMotion.BoneFrames.Get(boneConfig.UpTo).Get(fno).Position
+			motionDirectionFromPos := posMotion.BoneFrames.Get(boneConfig.DirectionFrom).Get(fno).Position
+			motionDirectionToPos := posMotion.BoneFrames.Get(boneConfig.DirectionTo).Get(fno).Position
+			motionUpFromPos := posMotion.BoneFrames.Get(boneConfig.UpFrom).Get(fno).Position
+			motionUpToPos := posMotion.BoneFrames.Get(boneConfig.UpTo).Get(fno).Position
 
 			motionDirectionVector := motionDirectionToPos.Subed(motionDirectionFromPos).Normalize()
 			motionUpVector := motionUpToPos.Subed(motionUpFromPos).Normalize()
@@ -72,9 +77,12 @@ func Rotate(moveMotion *vmd.VmdMotion, modelPath string, motionNum, allNum int)
 			motionQuat := mmath.NewMQuaternionFromDirection(motionDirectionVector, motionCrossVector)
 
 			// キャンセルボーン角度
-			cancelQuat := mmath.NewMQuaternion()
-			for _, cancelBoneName := range boneConfig.Cancels {
-				cancelQuat.Mul(rotMotion.BoneFrames.Get(cancelBoneName).Get(fno).Rotation)
+			cancelQuat := parentRotation(pmxModel, rotMotion, boneConfig.Name, fno)
+
+			// 回転付与角度(上半身3 等、回転付与を持つボーンは付与分を打ち消す)
+			effectQuat := mmath.NewMQuaternion()
+			if bone, err := pmxModel.Bones.GetByName(boneConfig.Name); err == nil {
+				effectQuat = effectRotation(pmxModel, rotMotion, bone, fno)
 			}
 
 			// 調整角度
@@ -82,12 +90,18 @@ func Rotate(moveMotion *vmd.VmdMotion, modelPath string, motionNum, allNum int)
 
 			// ボーンフレーム登録
 			rotBf := vmd.NewBoneFrame(fno)
-			rotBf.Rotation = invertQuat.Mul(cancelQuat.Inverse()).Mul(motionQuat).Mul(boneQuat.Inverse()).Normalize()
+			rotBf.Rotation = invertQuat.Mul(cancelQuat.Inverse()).Mul(motionQuat).Mul(boneQuat.Inverse()).
+				Mul(effectQuat.Inverse()).Normalize()
 
 			rotMotion.AppendBoneFrame(boneConfig.Name, rotBf)
 
 			return true
 		})
+
+		// 肩の上げ下げは肩Pに移す(子ボーンより先に移しておく)
+		if boneConfig.ElevationTo != "" && pmxModel.Bones.ContainsByName(boneConfig.ElevationTo) {
+			separateElevation(rotMotion, boneConfig.Name, boneConfig.ElevationTo)
+		}
 	}
 
 	bar.Finish()
@@ -95,16 +109,242 @@ func Rotate(moveMotion *vmd.VmdMotion, modelPath string, motionNum, allNum int)
 	return rotMotion
 }
 
+// parentRotation 親ボーンから順に、変換済みの回転(回転付与を含む)を合成した回転
+func parentRotation(model *pmx.PmxModel, rotMotion *vmd.VmdMotion, boneName string, fno float32) *mmath.MQuaternion {
+	quat := mmath.NewMQuaternion()
+
+	bone, err := model.Bones.GetByName(boneName)
+	if err != nil {
+		return quat
+	}
+
+	// ParentBoneNames は親ボーンから順なので、ルート側から合成する
+	for i := len(bone.ParentBoneNames) - 1; i >= 0; i-- {
+		parent, err := model.Bones.GetByName(bone.ParentBoneNames[i])
+		if err != nil {
+			continue
+		}
+
+		if rot := frameRotation(rotMotion, parent.Name(), fno); rot != nil {
+			quat.Mul(rot)
+		}
+
+		// 肩C 等、回転付与で親の回転を打ち消すボーン
+		quat.Mul(effectRotation(model, rotMotion, parent, fno))
+	}
+
+	return quat
+}
+
+// effectRotation ボーンの回転付与で加わる、変換済みの回転(回転付与が無い場合は単位回転)
+func effectRotation(model *pmx.PmxModel, rotMotion *vmd.VmdMotion, bone *pmx.Bone, fno float32) *mmath.MQuaternion {
+	if !bone.IsEffectorRotation() {
+		return mmath.NewMQuaternion()
+	}
+
+	effectBone, err := model.Bones.Get(bone.EffectIndex)
+	if err != nil {
+		return mmath.NewMQuaternion()
+	}
+
+	if rot := frameRotation(rotMotion, effectBone.Name(), fno); rot != nil {
+		return rot.MuledScalar(bone.EffectFactor)
+	}
+	return mmath.NewMQuaternion()
+}
+
+// frameRotation 指定フレームに登録済みの回転(無い場合はnil)
+func frameRotation(motion *vmd.VmdMotion, boneName string, fno float32) *mmath.MQuaternion {
+	if !motion.BoneFrames.Contains(boneName) || !motion.BoneFrames.Get(boneName).Contains(fno) {
+		return nil
+	}
+	return motion.BoneFrames.Get(boneName).Get(fno).Rotation
+}
+
+// separateElevation 回転を前後軸(Z)回りの回転と、それ以外の回転に分け、前後軸回りの回転を親ボーンに移す
+func separateElevation(rotMotion *vmd.VmdMotion, boneName, elevationBoneName string) {
+	rotMotion.BoneFrames.Get(boneName).ForEach(func(fno float32, bf *vmd.BoneFrame) bool {
+		if bf.Rotation == nil {
+			return true
+		}
+
+		// 親ボーンの回転を先に掛けるので、逆回転を分けてから戻す
+		invElevationQuat, invSwingQuat := bf.Rotation.Inverted().SeparateTwistByAxis(mmath.MVec3UnitZ)
+
+		elevationBf := vmd.NewBoneFrame(fno)
+		elevationBf.Rotation = invElevationQuat.Inverted().Normalize()
+		rotMotion.AppendBoneFrame(elevationBoneName, elevationBf)
+
+		bf.Rotation = invSwingQuat.Inverted().Normalize()
+
+		return true
+	})
+}
+
+// motionSpineBoneNames トラッカーの背骨の関節に対応するボーン名(根元から順)
+var motionSpineBoneNames = []string{"上半身", "上半身1", "上半身2", "上半身3", "首"}
+
+// modelSpineBoneNames 背骨として回転を割り振るモデルのボーン(根元から順)
+var modelSpineBoneNames = []pmx.StandardBoneName{pmx.UPPER, pmx.UPPER2, pmx.UPPER3, pmx.NECK_ROOT}
+
+// SPINE_SEGMENT_MIN_LENGTH 背骨ボーンとして扱う、前後の背骨ボーンとの最小距離
+// これより短い区間はボーンの向きが求まらない(回転がNaNになる)ので、そのボーンには割り振らない
+const SPINE_SEGMENT_MIN_LENGTH = 1e-3
+
+// spineBoneNames モデルにある背骨ボーン名。上半身から始まり、首で終わる
+func spineBoneNames(model *pmx.PmxModel) []string {
+	names := []string{pmx.UPPER.String()}
+
+	upper, _ := model.Bones.GetByName(pmx.UPPER.String())
+	neck, _ := model.Bones.GetByName(pmx.NECK.String())
+	if upper == nil || neck == nil {
+		return append(names, pmx.NECK.String())
+	}
+
+	prevPosition := upper.Position
+	for _, name := range modelSpineBoneNames[1:] {
+		bone, err := model.Bones.GetByName(name.String())
+		if err != nil {
+			continue
+		}
+		if bone.Position.Distance(prevPosition) < SPINE_SEGMENT_MIN_LENGTH ||
+			bone.Position.Distance(neck.Position) < SPINE_SEGMENT_MIN_LENGTH {
+			continue
+		}
+		names = append(names, name.String())
+		prevPosition = bone.Position
+	}
+	return append(names, pmx.NECK.String())
+}
+
+// spineBoneConfigs モデルにある背骨ボーンの回転定義。各背骨ボーンは次の背骨ボーン(最後は首)を向く
+func spineBoneConfigs(model *pmx.PmxModel) []*boneConfig {
+	names := spineBoneNames(model)
+
+	configs := make([]*boneConfig, 0, len(names)-1)
+	for i, name := range names[:len(names)-1] {
+		configs = append(configs, &boneConfig{
+			Name:          name,
+			DirectionFrom: name,
+			DirectionTo:   names[i+1],
+			UpFrom:        "左腕",
+			UpTo:          "右腕",
+			Invert:        &mmath.MVec3{},
+		})
+	}
+
+	return configs
+}
+
+// rotateBoneConfigs モデルで回転を求めるボーンの定義(背骨、その他の順)
+func rotateBoneConfigs(model *pmx.PmxModel) []*boneConfig {
+	return append(spineBoneConfigs(model), boneConfigs...)
+}
+
+// resampleSpine トラッカーの背骨を、上半身から首までの長さの割合がモデルの背骨ボーンと同じになる位置で区切り直す
+// 背骨以外のボーンは元のモーションのボーンフレームをそのまま使う
+func resampleSpine(moveMotion *vmd.VmdMotion, model *pmx.PmxModel) *vmd.VmdMotion {
+	spineNames := spineBoneNames(model)
+
+	posMotion := vmd.NewVmdMotion(moveMotion.Path())
+	for _, boneName := range moveMotion.BoneFrames.Names() {
+		if !slices.Contains(spineNames, boneName) {
+			posMotion.BoneFrames.Update(moveMotion.BoneFrames.Get(boneName))
+		}
+	}
+
+	bonePositions := make([]*mmath.MVec3, len(spineNames))
+	for i, boneName := range spineNames {
+		bone, err := model.Bones.GetByName(boneName)
+		if err != nil {
+			// 上半身か首が無いモデルは、背骨の回転を求めない
+			return posMotion
+		}
+		bonePositions[i] = bone.Position
+	}
+	boneRatios := polylineRatios(bonePositions)
+
+	if !moveMotion.BoneFrames.Contains(pmx.UPPER.String()) {
+		return posMotion
+	}
+
+	if !moveMotion.BoneFrames.Contains(pmx.NECK.String()) {
+		return posMotion
+	}
+
+	moveMotion.BoneFrames.Get(pmx.UPPER.String()).ForEach(func(fno float32, bf *vmd.BoneFrame) bool {
+		// 首が無いフレームは区切れない
+		if !moveMotion.BoneFrames.Get(pmx.NECK.String()).Contains(fno) {
+			return true
+		}
+
+		motionPositions := make([]*mmath.MVec3, 0, len(motionSpineBoneNames))
+		for _, boneName := range motionSpineBoneNames {
+			if moveMotion.BoneFrames.Contains(boneName) && moveMotion.BoneFrames.Get(boneName).Contains(fno) {
+				motionPositions = append(motionPositions, moveMotion.BoneFrames.Get(boneName).Get(fno).Position)
+			}
+		}
+		motionRatios := polylineRatios(motionPositions)
+
+		for i, boneName := range spineNames {
+			spineBf := vmd.NewBoneFrame(fno)
+			spineBf.Position = samplePolyline(motionPositions, motionRatios, boneRatios[i])
+			posMotion.AppendBoneFrame(boneName, spineBf)
+		}
+
+		return true
+	})
+
+	return posMotion
+}
+
+// polylineRatios 折れ線の各点の、始点からの長さの全長に対する割合
+func polylineRatios(points []*mmath.MVec3) []float64 {
+	ratios := make([]float64, len(points))
+	for i := 1; i < len(points); i++ {
+		ratios[i] = ratios[i-1] + points[i].Distance(points[i-1])
+	}
+
+	total := ratios[len(ratios)-1]
+	for i := range ratios {
+		if total > 0 {
+			ratios[i] /= total
+		} else {
+			ratios[i] = float64(i) / float64(len(ratios)-1)
+		}
+	}
+
+	return ratios
+}
+
+// samplePolyline 折れ線上の、始点からの長さの割合が ratio となる位置
+func samplePolyline(points []*mmath.MVec3, ratios []float64, ratio float64) *mmath.MVec3 {
+	for i := 1; i < len(points); i++ {
+		if ratio > ratios[i] && i < len(points)-1 {
+			continue
+		}
+
+		t := 0.0
+		if length := ratios[i] - ratios[i-1]; length > 0 {
+			t = (ratio - ratios[i-1]) / length
+		}
+		return points[i-1].Lerp(points[i], t).Copy()
+	}
+
+	return points[0].Copy()
+}
+
 type boneConfig struct {
 	Name          string
 	DirectionFrom string
 	DirectionTo   string
 	UpFrom        string
 	UpTo          string
-	Cancels       []string
+	ElevationTo   string // 前後軸回りの回転(肩の上げ下げ)を移す親ボーン(肩P)
 	Invert        *mmath.MVec3
 }
 
+// boneConfigs 背骨以外のボーンの回転定義
 var boneConfigs = []*boneConfig{
 	{
 		Name:          "下半身",
@@ -112,25 +352,6 @@ var boneConfigs = []*boneConfig{
 		DirectionTo:   "下半身先",
 		UpFrom:        "左足",
 		UpTo:          "右足",
-		Cancels:       []string{},
-		Invert:        &mmath.MVec3{},
-	},
-	{
-		Name:          "上半身",
-		DirectionFrom: "上半身",
-		DirectionTo:   "上半身2",
-		UpFrom:        "左腕",
-		UpTo:          "右腕",
-		Cancels:       []string{},
-		Invert:        &mmath.MVec3{},
-	},
-	{
-		Name:          "上半身2",
-		DirectionFrom: "上半身2",
-		DirectionTo:   "首",
-		UpFrom:        "左腕",
-		UpTo:          "右腕",
-		Cancels:       []string{"上半身"},
 		Invert:        &mmath.MVec3{},
 	},
 	{
@@ -139,7 +360,6 @@ var boneConfigs = []*boneConfig{
 		DirectionTo:   "頭",
 		UpFrom:        "左腕",
 		UpTo:          "右腕",
-		Cancels:       []string{"上半身", "上半身2"},
 		Invert:        &mmath.MVec3{},
 	},
 	{
@@ -148,16 +368,15 @@ var boneConfigs = []*boneConfig{
 		DirectionTo:   "頭",
 		UpFrom:        "左目",
 		UpTo:          "右目",
-		Cancels:       []string{"上半身", "上半身2", "首"},
 		Invert:        &mmath.MVec3{},
 	},
 	{
 		Name:          "左肩",
 		DirectionFrom: "首",
 		DirectionTo:   "左腕",
-		UpFrom:        "上半身2",
-		UpTo:          "上半身3",
-		Cancels:       []string{"上半身", "上半身2"},
+		UpFrom:        "上半身",
+		UpTo:          "首",
+		ElevationTo:   "左肩P",
 		Invert:        &mmath.MVec3{},
 	},
 	{
@@ -166,7 +385,6 @@ var boneConfigs = []*boneConfig{
 		DirectionTo:   "左ひじ",
 		UpFrom:        "首",
 		UpTo:          "左腕",
-		Cancels:       []string{"上半身", "上半身2", "左肩"},
 		Invert:        &mmath.MVec3{},
 	},
 	{
@@ -175,7 +393,6 @@ var boneConfigs = []*boneConfig{
 		DirectionTo:   "左手首",
 		UpFrom:        "左腕",
 		UpTo:          "左ひじ",
-		Cancels:       []string{"上半身", "上半身2", "左肩", "左腕"},
 		Invert:        &mmath.MVec3{},
 	},
 	{
@@ -184,16 +401,15 @@ var boneConfigs = []*boneConfig{
 		DirectionTo:   "左人指先",
 		UpFrom:        "左親指１",
 		UpTo:          "左小指１",
-		Cancels:       []string{"上半身", "上半身2", "左肩", "左腕", "左ひじ"},
 		Invert:        &mmath.MVec3{},
 	},
 	{
 		Name:          "右肩",
 		DirectionFrom: "首",
 		DirectionTo:   "右腕",
-		UpFrom:        "上半身2",
-		UpTo:          "上半身3",
-		Cancels:       []string{"上半身", "上半身2"},
+		UpFrom:        "上半身",
+		UpTo:          "首",
+		ElevationTo:   "右肩P",
 		Invert:        &mmath.MVec3{},
 	},
 	{
@@ -202,7 +418,6 @@ var boneConfigs = []*boneConfig{
 		DirectionTo:   "右ひじ",
 		UpFrom:        "首",
 		UpTo:          "右腕",
-		Cancels:       []string{"上半身", "上半身2", "右肩"},
 		Invert:        &mmath.MVec3{},
 	},
 	{
@@ -211,7 +426,6 @@ var boneConfigs = []*boneConfig{
 		DirectionTo:   "右手首",
 		UpFrom:        "右腕",
 		UpTo:          "右ひじ",
-		Cancels:       []string{"上半身", "上半身2", "右肩", "右腕"},
 		Invert:        &mmath.MVec3{},
 	},
 	{
@@ -220,7 +434,6 @@ var boneConfigs = []*boneConfig{
 		DirectionTo:   "右人指先",
 		UpFrom:        "右親指１",
 		UpTo:          "右小指１",
-		Cancels:       []string{"上半身", "上半身2", "右肩", "右腕", "右ひじ"},
 		Invert:        &mmath.MVec3{},
 	},
 	{
@@ -229,7 +442,6 @@ var boneConfigs = []*boneConfig{
 		DirectionTo:   "左ひざ",
 		UpFrom:        "左足",
 		UpTo:          "右足",
-		Cancels:       []string{"下半身"},
 		Invert:        &mmath.MVec3{},
 	},
 	{
@@ -238,7 +450,6 @@ var boneConfigs = []*boneConfig{
 		DirectionTo:   "左足首",
 		UpFrom:        "左足",
 		UpTo:          "右足",
-		Cancels:       []string{"下半身", "左足"},
 		Invert:        &mmath.MVec3{},
 	},
 	{
@@ -247,7 +458,6 @@ var boneConfigs = []*boneConfig{
 		DirectionTo:   "左つま先",
 		UpFrom:        "左つま先親",
 		UpTo:          "左つま先子",
-		Cancels:       []string{"下半身", "左足", "左ひざ"},
 		Invert:        &mmath.MVec3{},
 	},
 	{
@@ -256,7 +466,6 @@ var boneConfigs = []*boneConfig{
 		DirectionTo:   "右ひざ",
 		UpFrom:        "左足",
 		UpTo:          "右足",
-		Cancels:       []string{"下半身"},
 		Invert:        &mmath.MVec3{},
 	},
 	{
@@ -265,7 +474,6 @@ var boneConfigs = []*boneConfig{
 		DirectionTo:   "右足首",
 		UpFrom:        "左足",
 		UpTo:          "右足",
-		Cancels:       []string{"下半身", "右足"},
 		Invert:        &mmath.MVec3{},
 	},
 	{
@@ -274,7 +482,6 @@ var boneConfigs = []*boneConfig{
 		DirectionTo:   "右つま先",
 		UpFrom:        "右つま先親",
 		UpTo:          "右つま先子",
-		Cancels:       []string{"下半身", "右足", "右ひざ"},
 		Invert:        &mmath.MVec3{},
 	},
 }
@@ -0,0 +1,132 @@
+package usecase
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/usecase/deform"
+)
+
+func TestSpineBoneNames(t *testing.T) {
+	model := loadTestModel(t)
+
+	expected := []string{"上半身", "上半身2", "上半身3", "首"}
+	if names := spineBoneNames(model); !slices.Equal(names, expected) {
+		t.Errorf("Expected spine bone names %v, got %v", expected, names)
+	}
+
+	// 首と同じ位置にある首根元は、向きが求まらないので背骨に含めない
+	neck, _ := model.Bones.GetNeck()
+	neckRoot := pmx.NewBoneByName(pmx.NECK_ROOT.String())
+	neckRoot.Position = neck.Position.Copy()
+	neckRoot.ParentIndex = neck.ParentIndex
+	if err := model.Bones.Insert(neckRoot); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	model.Bones.Setup()
+
+	if names := spineBoneNames(model); !slices.Equal(names, expected) {
+		t.Errorf("Expected spine bone names %v, got %v", expected, names)
+	}
+
+	for _, boneConfig := range spineBoneConfigs(model) {
+		from, _ := model.Bones.GetByName(boneConfig.DirectionFrom)
+		to, _ := model.Bones.GetByName(boneConfig.DirectionTo)
+		if from.Position.Distance(to.Position) < SPINE_SEGMENT_MIN_LENGTH {
+			t.Errorf("Expected %s to have a spine segment, got zero length", boneConfig.Name)
+		}
+	}
+}
+
+func TestRotate_Spine(t *testing.T) {
+	model := loadTestModel(t)
+
+	upper, _ := model.Bones.GetUpper()
+	neck, _ := model.Bones.GetNeck()
+	spineLength := upper.Position.Distance(neck.Position)
+
+	// トラッカーの背骨を、前方に60度曲げた円弧上に等間隔に置く
+	bendRadian := mmath.DegToRad(60)
+	radius := spineLength / bendRadian
+
+	moveMotion := vmd.NewVmdMotion("")
+	appendPosition := func(boneName string, position *mmath.MVec3) {
+		bf := vmd.NewBoneFrame(0)
+		bf.Position = position
+		moveMotion.AppendBoneFrame(boneName, bf)
+	}
+
+	appendPosition("下半身", mmath.NewMVec3())
+	for i, boneName := range motionSpineBoneNames {
+		angle := bendRadian * float64(i) / float64(len(motionSpineBoneNames)-1)
+		appendPosition(boneName, upper.Position.Added(&mmath.MVec3{
+			X: 0,
+			Y: radius * math.Sin(angle),
+			Z: -radius * (1 - math.Cos(angle)),
+		}))
+	}
+	motionNeck := moveMotion.BoneFrames.Get("首").Get(0).Position
+	appendPosition("左腕", motionNeck.Added(&mmath.MVec3{X: 1.5, Y: 0, Z: 0}))
+	appendPosition("右腕", motionNeck.Added(&mmath.MVec3{X: -1.5, Y: 0, Z: 0}))
+
+	rotMotion := Rotate(moveMotion, model, 1, 1)
+
+	// 曲がりは背骨ボーンそれぞれに割り振られる
+	spineNames := spineBoneNames(model)
+	for _, boneName := range spineNames[:len(spineNames)-1] {
+		if !rotMotion.BoneFrames.Contains(boneName) {
+			t.Fatalf("Expected %s to be rotated", boneName)
+		}
+		if degree := rotMotion.BoneFrames.Get(boneName).Get(0).Rotation.ToDegree(); degree < 5 {
+			t.Errorf("Expected %s to share the spine bend, got %v degrees", boneName, degree)
+		}
+	}
+
+	// 変形後の各背骨ボーンは、区切り直したトラッカーの背骨の方向を向く
+	posMotion := resampleSpine(moveMotion, model)
+	deltas := deform.DeformBone(model, rotMotion, rotMotion, false, 0, spineNames)
+	for i := range spineNames[:len(spineNames)-1] {
+		from := deltas.Bones.GetByName(spineNames[i]).FilledGlobalPosition()
+		to := deltas.Bones.GetByName(spineNames[i+1]).FilledGlobalPosition()
+		actual := to.Subed(from).Normalized()
+
+		expectedFrom := posMotion.BoneFrames.Get(spineNames[i]).Get(0).Position
+		expectedTo := posMotion.BoneFrames.Get(spineNames[i+1]).Get(0).Position
+		expected := expectedTo.Subed(expectedFrom).Normalized()
+
+		if degree := mmath.RadToDeg(math.Acos(mmath.Clamped(actual.Dot(expected), -1, 1))); degree > 0.5 {
+			t.Errorf("Expected %s to point along the tracked spine, got %v degrees apart", spineNames[i], degree)
+		}
+	}
+}
+
+func TestSeparateElevation(t *testing.T) {
+	rotMotion := vmd.NewVmdMotion("")
+
+	original := mmath.NewMQuaternionFromDegrees(10, 20, 30)
+	bf := vmd.NewBoneFrame(0)
+	bf.Rotation = original.Copy()
+	rotMotion.AppendBoneFrame("左肩", bf)
+
+	separateElevation(rotMotion, "左肩", "左肩P")
+
+	elevation := rotMotion.BoneFrames.Get("左肩P").Get(0).Rotation
+	swing := rotMotion.BoneFrames.Get("左肩").Get(0).Rotation
+
+	// 肩Pには前後軸(Z)回りの回転だけが移る
+	if elevationTwist, _ := elevation.SeparateTwistByAxis(mmath.MVec3UnitZ); quatDegree(elevationTwist, elevation) > 1e-4 {
+		t.Errorf("Expected 左肩P to rotate around Z only, got %v", elevation.ToMMDDegrees())
+	}
+	if elevation.ToDegree() < 1 {
+		t.Errorf("Expected 左肩P to take the elevation, got %v degrees", elevation.ToDegree())
+	}
+
+	// 肩Pと肩を合成すると、元の肩の回転になる
+	if degree := quatDegree(elevation.Muled(swing), original); degree > 1e-4 {
+		t.Errorf("Expected composed rotation to be unchanged, got %v degrees apart", degree)
+	}
+}
@@ -0,0 +1,110 @@
+package usecase
+
+import (
+	"math"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/utils"
+)
+
+// BendToes かかと〜足首の面に対する、つま先(親指と小指の中間)の曲がりを足先EXの回転にする
+// 足先EXが無いモデルでは何もしない
+func BendToes(moveMotion, rotMotion *vmd.VmdMotion, model *pmx.PmxModel, motionNum, allNum int) {
+	mlog.I("[%d/%d] Bend Toes ...", motionNum, allNum)
+
+	directions := pmx.TOE_EX.Directions()
+	bar := utils.NewProgressBar(len(directions))
+
+	for _, direction := range directions {
+		bar.Increment()
+
+		toeEx, err := model.Bones.GetToeEx(direction)
+		if err != nil {
+			continue
+		}
+
+		restAngle, lateralAxis, err := restToeAngle(model, direction)
+		if err != nil {
+			mlog.W("[%d/%d] Skip %s: %s", motionNum, allNum, toeEx.Name(), err.Error())
+			continue
+		}
+
+		heelName := pmx.HEEL.StringFromDirection(direction)
+		ankleName := pmx.ANKLE.StringFromDirection(direction)
+		bigToeName := pmx.TOE_P.StringFromDirection(direction)
+		smallToeName := pmx.TOE_C.StringFromDirection(direction)
+		if !moveMotion.BoneFrames.Contains(heelName) || !moveMotion.BoneFrames.Contains(ankleName) ||
+			!moveMotion.BoneFrames.Contains(bigToeName) || !moveMotion.BoneFrames.Contains(smallToeName) {
+			continue
+		}
+
+		moveMotion.BoneFrames.Get(ankleName).ForEach(func(fno float32, bf *vmd.BoneFrame) bool {
+			if !moveMotion.BoneFrames.Get(heelName).Contains(fno) ||
+				!moveMotion.BoneFrames.Get(bigToeName).Contains(fno) ||
+				!moveMotion.BoneFrames.Get(smallToeName).Contains(fno) {
+				return true
+			}
+
+			angle := toeAngle(
+				moveMotion.BoneFrames.Get(heelName).Get(fno).Position,
+				bf.Position,
+				moveMotion.BoneFrames.Get(bigToeName).Get(fno).Position,
+				moveMotion.BoneFrames.Get(smallToeName).Get(fno).Position,
+			)
+
+			// モデルの横軸回りに、モデルとの角度差分だけ曲げる
+			toeBf := vmd.NewBoneFrame(fno)
+			toeBf.Rotation = mmath.NewMQuaternionFromAxisAnglesRotate(lateralAxis, angle-restAngle)
+			rotMotion.AppendBoneFrame(toeEx.Name(), toeBf)
+
+			return true
+		})
+	}
+
+	bar.Finish()
+}
+
+// restToeAngle モデルのつま先の角度と横軸(親指→小指)
+// かかとが無いモデルは、かかとを作成する場合と同じ位置にあるものとして計算する
+func restToeAngle(model *pmx.PmxModel, direction pmx.BoneDirection) (float64, *mmath.MVec3, error) {
+	heel, err := model.Bones.GetHeel(direction)
+	if err != nil {
+		if heel, err = model.Bones.CreateHeel(direction); err != nil {
+			return 0, nil, err
+		}
+	}
+	ankle, err := model.Bones.GetAnkle(direction)
+	if err != nil {
+		return 0, nil, err
+	}
+	bigToe, err := model.Bones.GetToeP(direction)
+	if err != nil {
+		return 0, nil, err
+	}
+	smallToe, err := model.Bones.GetToeC(direction)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	angle := toeAngle(heel.Position, ankle.Position, bigToe.Position, smallToe.Position)
+	lateralAxis := smallToe.Position.Subed(bigToe.Position).Normalize()
+
+	return angle, lateralAxis, nil
+}
+
+// toeAngle かかと→足首 から 足首→つま先(親指と小指の中間) までの、横軸(親指→小指)回りの角度(ラジアン)
+func toeAngle(heel, ankle, bigToe, smallToe *mmath.MVec3) float64 {
+	lateralAxis := smallToe.Subed(bigToe).Normalize()
+	toe := bigToe.Lerp(smallToe, 0.5)
+
+	// 横軸に垂直な面に投影する
+	rearVector := ankle.Subed(heel)
+	rearVector = rearVector.Subed(lateralAxis.MuledScalar(rearVector.Dot(lateralAxis)))
+	frontVector := toe.Subed(ankle)
+	frontVector = frontVector.Subed(lateralAxis.MuledScalar(frontVector.Dot(lateralAxis)))
+
+	return math.Atan2(lateralAxis.Dot(rearVector.Cross(frontVector)), rearVector.Dot(frontVector))
+}
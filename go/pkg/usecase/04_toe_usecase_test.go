@@ -0,0 +1,118 @@
+package usecase
+
+import (
+	"math"
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+)
+
+func TestToeAngle(t *testing.T) {
+	heel := &mmath.MVec3{X: 0, Y: 0, Z: 0.5}
+	ankle := &mmath.MVec3{X: 0, Y: 1, Z: 0}
+
+	// 足先の高さごとの、つま先(親指と小指の中間)の位置
+	toe := func(y float64) *mmath.MVec3 {
+		return &mmath.MVec3{X: 0, Y: y, Z: -1.5}
+	}
+
+	// 左足は親指が内側(-X)、右足は親指が内側(+X)
+	for _, side := range []float64{1, -1} {
+		bigToe := func(y float64) *mmath.MVec3 { return toe(y).Added(&mmath.MVec3{X: -0.2 * side}) }
+		smallToe := func(y float64) *mmath.MVec3 { return toe(y).Added(&mmath.MVec3{X: 0.2 * side}) }
+		lateralAxis := &mmath.MVec3{X: side, Y: 0, Z: 0}
+
+		flat := toeAngle(heel, ankle, bigToe(0), smallToe(0))
+		raised := toeAngle(heel, ankle, bigToe(0.6), smallToe(0.6))
+		curled := toeAngle(heel, ankle, bigToe(-0.6), smallToe(-0.6))
+
+		// つま先を上げると横軸(親指→小指)回りの角度は、左足は増え、右足は減る(丸めると逆)
+		if (raised-flat)*side <= 0 || (curled-flat)*side >= 0 {
+			t.Errorf("[%v] Expected raised and curled toes to bend in opposite directions, got %v, %v, %v",
+				side, raised, flat, curled)
+		}
+
+		// 角度の差分だけ横軸回りに回すと、平らなつま先が上げたつま先の向きになる
+		rotated := mmath.NewMQuaternionFromAxisAnglesRotate(lateralAxis, raised-flat).MulVec3(toe(0).Subed(ankle))
+		expected := toe(0.6).Subed(ankle).Normalize()
+		if !rotated.Normalize().NearEquals(expected, 1e-8) {
+			t.Errorf("[%v] Expected rotated toe to be %v, got %v", side, expected, rotated)
+		}
+	}
+}
+
+func TestBendToes_Rest(t *testing.T) {
+	model := loadTestModel(t)
+	if err := AugmentModel(model); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	// 足先EXの無いモデルなので追加する
+	for _, direction := range pmx.TOE_EX.Directions() {
+		toeEx, err := model.Bones.CreateToeEx(direction)
+		if err != nil {
+			t.Fatalf("Expected error to be nil, got %q", err)
+		}
+		if err := model.Bones.Insert(toeEx); err != nil {
+			t.Fatalf("Expected error to be nil, got %q", err)
+		}
+	}
+	model.Bones.Setup()
+
+	// モデルと同じ足の形をトラッキングした場合
+	moveMotion := vmd.NewVmdMotion("")
+	for _, direction := range pmx.TOE_EX.Directions() {
+		heel, err := model.Bones.GetHeel(direction)
+		if err != nil {
+			heel, err = model.Bones.CreateHeel(direction)
+		}
+		if err != nil {
+			t.Fatalf("Expected error to be nil, got %q", err)
+		}
+		ankle, err := model.Bones.GetAnkle(direction)
+		if err != nil {
+			t.Fatalf("Expected error to be nil, got %q", err)
+		}
+		bigToe, err := model.Bones.GetToeP(direction)
+		if err != nil {
+			t.Fatalf("Expected error to be nil, got %q", err)
+		}
+		smallToe, err := model.Bones.GetToeC(direction)
+		if err != nil {
+			t.Fatalf("Expected error to be nil, got %q", err)
+		}
+
+		for _, bone := range []struct {
+			name     pmx.StandardBoneName
+			position *mmath.MVec3
+		}{
+			{pmx.HEEL, heel.Position},
+			{pmx.ANKLE, ankle.Position},
+			{pmx.TOE_P, bigToe.Position},
+			{pmx.TOE_C, smallToe.Position},
+		} {
+			bf := vmd.NewBoneFrame(0)
+			bf.Position = bone.position.Copy()
+			moveMotion.AppendBoneFrame(bone.name.StringFromDirection(direction), bf)
+		}
+	}
+
+	rotMotion := vmd.NewVmdMotion("")
+	BendToes(moveMotion, rotMotion, model, 1, 1)
+
+	// 足先EXは回転しない
+	for _, direction := range pmx.TOE_EX.Directions() {
+		toeEx, err := model.Bones.GetToeEx(direction)
+		if err != nil {
+			t.Fatalf("Expected %s to exist, got %q", pmx.TOE_EX.StringFromDirection(direction), err)
+		}
+		if !rotMotion.BoneFrames.Contains(toeEx.Name()) {
+			t.Fatalf("Expected %s to be written", toeEx.Name())
+		}
+		if degree := rotMotion.BoneFrames.Get(toeEx.Name()).Get(0).Rotation.ToDegree(); math.Abs(degree) > 1e-6 {
+			t.Errorf("Expected %s to be identity, got %v degrees", toeEx.Name(), degree)
+		}
+	}
+}
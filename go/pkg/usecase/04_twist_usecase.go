@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+)
+
+// DistributeTwist 腕・ひじ・手首の捩り成分を、モデルにある腕捩・手捩に振り分ける
+// 腕の捩りは腕捩へ、ひじと手首の捩りは手捩へ載せる(見た目の回転は変わらない)
+func DistributeTwist(rotMotion *vmd.VmdMotion, model *pmx.PmxModel, motionNum, allNum int) {
+	mlog.I("[%d/%d] Distribute Twist ...", motionNum, allNum)
+
+	for _, direction := range []pmx.BoneDirection{pmx.BONE_DIRECTION_LEFT, pmx.BONE_DIRECTION_RIGHT} {
+		distributeArmTwist(rotMotion, model, direction)
+		distributeWristTwist(rotMotion, model, direction)
+	}
+}
+
+// distributeArmTwist 腕の捩りを腕捩に移す
+func distributeArmTwist(rotMotion *vmd.VmdMotion, model *pmx.PmxModel, direction pmx.BoneDirection) {
+	armName := pmx.ARM.StringFromDirection(direction)
+	armTwistName := pmx.ARM_TWIST.StringFromDirection(direction)
+
+	armTwistBone, err := model.Bones.GetByName(armTwistName)
+	if err != nil || !rotMotion.BoneFrames.Contains(armName) {
+		return
+	}
+	twistAxis := twistBoneAxis(armTwistBone)
+
+	rotMotion.BoneFrames.Get(armName).ForEach(func(fno float32, bf *vmd.BoneFrame) bool {
+		if bf.Rotation == nil {
+			return true
+		}
+
+		// 腕 = スイング * 捩り
+		twistQuat, swingQuat := bf.Rotation.SeparateTwistByAxis(twistAxis)
+		bf.Rotation = swingQuat
+
+		setBoneRotation(rotMotion, armTwistName, fno, twistQuat)
+
+		return true
+	})
+}
+
+// distributeWristTwist ひじと手首の捩りを手捩に移す
+func distributeWristTwist(rotMotion *vmd.VmdMotion, model *pmx.PmxModel, direction pmx.BoneDirection) {
+	elbowName := pmx.ELBOW.StringFromDirection(direction)
+	wristName := pmx.WRIST.StringFromDirection(direction)
+	wristTwistName := pmx.WRIST_TWIST.StringFromDirection(direction)
+
+	wristTwistBone, err := model.Bones.GetByName(wristTwistName)
+	if err != nil || (!rotMotion.BoneFrames.Contains(elbowName) && !rotMotion.BoneFrames.Contains(wristName)) {
+		return
+	}
+	twistAxis := twistBoneAxis(wristTwistBone)
+
+	fnos := make([]float32, 0)
+	for _, boneName := range []string{elbowName, wristName} {
+		rotMotion.BoneFrames.Get(boneName).ForEach(func(fno float32, bf *vmd.BoneFrame) bool {
+			fnos = append(fnos, fno)
+			return true
+		})
+	}
+	fnos = mmath.Unique(fnos)
+	mmath.Sort(fnos)
+
+	// 書き換える前に、全キーフレームの回転を求めておく
+	elbowQuats := make([]*mmath.MQuaternion, len(fnos))
+	wristQuats := make([]*mmath.MQuaternion, len(fnos))
+	twistQuats := make([]*mmath.MQuaternion, len(fnos))
+	for i, fno := range fnos {
+		elbowQuat := rotMotion.BoneFrames.Get(elbowName).Get(fno).FilledRotation()
+		wristQuat := rotMotion.BoneFrames.Get(wristName).Get(fno).FilledRotation()
+
+		// ひじ = スイング * 捩り
+		elbowTwistQuat, elbowSwingQuat := elbowQuat.SeparateTwistByAxis(twistAxis)
+		// 手首 = 捩り * スイング (逆回転を分解して、捩りを手首の手前に出す)
+		invWristTwistQuat, invWristSwingQuat := wristQuat.Inverted().SeparateTwistByAxis(twistAxis)
+
+		elbowQuats[i] = elbowSwingQuat
+		wristQuats[i] = invWristSwingQuat.Inverted()
+		twistQuats[i] = elbowTwistQuat.Muled(invWristTwistQuat.Inverted()).Normalize()
+	}
+
+	for i, fno := range fnos {
+		setBoneRotation(rotMotion, elbowName, fno, elbowQuats[i])
+		setBoneRotation(rotMotion, wristName, fno, wristQuats[i])
+		setBoneRotation(rotMotion, wristTwistName, fno, twistQuats[i])
+	}
+}
+
+// setBoneRotation キーフレームがあれば回転だけ更新し、なければ追加する
+func setBoneRotation(motion *vmd.VmdMotion, boneName string, fno float32, quat *mmath.MQuaternion) {
+	if motion.BoneFrames.Contains(boneName) && motion.BoneFrames.Get(boneName).Contains(fno) {
+		motion.BoneFrames.Get(boneName).Get(fno).Rotation = quat
+		return
+	}
+
+	bf := vmd.NewBoneFrame(fno)
+	bf.Rotation = quat
+	motion.AppendBoneFrame(boneName, bf)
+}
+
+// twistBoneAxis 捩りボーンの軸(軸制限があればその向き)
+func twistBoneAxis(bone *pmx.Bone) *mmath.MVec3 {
+	if bone.HasFixedAxis() {
+		return bone.FixedAxis.Normalized()
+	}
+	return bone.LocalAxis.Normalized()
+}
@@ -0,0 +1,85 @@
+package usecase
+
+import (
+	"math"
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/infrastructure/repository"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/usecase/deform"
+)
+
+// loadTestModel テスト用のトレースモデルを読み込む
+func loadTestModel(t *testing.T) *pmx.PmxModel {
+	t.Helper()
+
+	data, err := repository.NewPmxRepository(true).Load("../../../data/pmx/v4_trace_model.pmx")
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	return data.(*pmx.PmxModel)
+}
+
+func TestDistributeTwist(t *testing.T) {
+	model := loadTestModel(t)
+
+	motion := vmd.NewVmdMotion("")
+	for i, fno := range []float32{0, 10} {
+		armBf := vmd.NewBoneFrame(fno)
+		armBf.Rotation = mmath.NewMQuaternionFromDegrees(20+float64(i)*10, 35, -40)
+		motion.AppendBoneFrame("左腕", armBf)
+
+		elbowBf := vmd.NewBoneFrame(fno)
+		elbowBf.Rotation = mmath.NewMQuaternionFromDegrees(30, -70, 15)
+		motion.AppendBoneFrame("左ひじ", elbowBf)
+
+		wristBf := vmd.NewBoneFrame(fno)
+		wristBf.Rotation = mmath.NewMQuaternionFromDegrees(-25, 10, 40-float64(i)*20)
+		motion.AppendBoneFrame("左手首", wristBf)
+	}
+
+	distributed, err := motion.Copy()
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	DistributeTwist(distributed, model, 1, 1)
+
+	if !distributed.BoneFrames.Contains("左腕捩") || !distributed.BoneFrames.Contains("左手捩") {
+		t.Fatalf("Expected twist to be distributed to twist bones")
+	}
+
+	// 腕に捩りが残らない
+	armTwistBone, _ := model.Bones.GetByName("左腕捩")
+	armTwistAxis := twistBoneAxis(armTwistBone)
+	if twist, _ := distributed.BoneFrames.Get("左腕").Get(0).Rotation.SeparateTwistByAxis(armTwistAxis); twist.ToDegree() > 1e-4 {
+		t.Errorf("Expected arm twist to be removed, got %v", twist.ToDegree())
+	}
+
+	// 振り分けた後も、キーフレームでは手首から先の姿勢は変わらない
+	// (キーフレームの間は分解した回転ごとに補間するので、わずかにずれる)
+	boneNames := []string{"左手首", "左中指１"}
+	for _, fno := range []int{0, 10} {
+		expected := deform.DeformBone(model, motion, motion, false, fno, boneNames)
+		actual := deform.DeformBone(model, distributed, distributed, false, fno, boneNames)
+		for _, boneName := range boneNames {
+			expectedDelta := expected.Bones.GetByName(boneName)
+			actualDelta := actual.Bones.GetByName(boneName)
+			if !actualDelta.FilledGlobalPosition().NearEquals(expectedDelta.FilledGlobalPosition(), 1e-3) {
+				t.Errorf("[%d] Expected %s position %v, got %v", fno, boneName,
+					expectedDelta.FilledGlobalPosition(), actualDelta.FilledGlobalPosition())
+			}
+			expectedQuat := expectedDelta.FilledGlobalMatrix().Quaternion()
+			actualQuat := actualDelta.FilledGlobalMatrix().Quaternion()
+			if degree := quatDegree(expectedQuat, actualQuat); degree > 1e-2 {
+				t.Errorf("[%d] Expected %s rotation to be unchanged, got %v degrees apart", fno, boneName, degree)
+			}
+		}
+	}
+}
+
+// quatDegree 2つの回転の差(度)
+func quatDegree(a, b *mmath.MQuaternion) float64 {
+	return mmath.RadToDeg(2 * math.Acos(mmath.Clamped(math.Abs(a.Normalized().Dot(b.Normalized())), 0, 1)))
+}
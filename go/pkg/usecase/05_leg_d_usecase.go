@@ -0,0 +1,149 @@
+package usecase
+
+import (
+	"slices"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/usecase/deform"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/utils"
+)
+
+// LEG_D_TOLERANCE D系ボーン出力と通常出力の足首位置の許容差
+const LEG_D_TOLERANCE = 0.01
+
+// legDBoneNames 通常の足ボーンと、回転を移すD系ボーン
+var legDBoneNames = [][2]pmx.StandardBoneName{
+	{pmx.LEG, pmx.LEG_D},
+	{pmx.KNEE, pmx.KNEE_D},
+	{pmx.ANKLE, pmx.ANKLE_D},
+}
+
+// HasLegD モデルが左右の足D・ひざD・足首Dを持っているか
+func HasLegD(model *pmx.PmxModel) bool {
+	for _, names := range legDBoneNames {
+		for _, direction := range names[1].Directions() {
+			if !model.Bones.ContainsByName(names[1].StringFromDirection(direction)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ConvertLegD 足・ひざ・足首の回転を足D・ひざD・足首Dに移し、足を動かすIKをOFFにする
+func ConvertLegD(rootMotion *vmd.VmdMotion, model *pmx.PmxModel, motionNum, allNum int) *vmd.VmdMotion {
+	mlog.I("[%d/%d] Convert Leg D ...", motionNum, allNum)
+
+	legDMotion, err := rootMotion.Copy()
+	if err != nil {
+		mlog.E("Failed to copy motion", err)
+		return rootMotion
+	}
+
+	for _, names := range legDBoneNames {
+		for _, direction := range names[0].Directions() {
+			boneName := names[0].StringFromDirection(direction)
+			if !legDMotion.BoneFrames.Contains(boneName) {
+				continue
+			}
+
+			boneNameFrames := legDMotion.BoneFrames.Get(boneName)
+			legDMotion.BoneFrames.Delete(boneName)
+			boneNameFrames.Name = names[1].StringFromDirection(direction)
+			legDMotion.BoneFrames.Update(boneNameFrames)
+		}
+	}
+
+	// 足ボーンは元の位置のまま、D系ボーンだけで動かす
+	ikFrame := vmd.NewIkFrame(0)
+	model.Bones.ForEach(func(index int, bone *pmx.Bone) bool {
+		if isLegIk(model, bone) {
+			ikEnabledFrame := vmd.NewIkEnableFrame(0)
+			ikEnabledFrame.BoneName = bone.Name()
+			ikEnabledFrame.Enabled = false
+			ikFrame.IkList = append(ikFrame.IkList, ikEnabledFrame)
+		}
+		return true
+	})
+	legDMotion.AppendIkFrame(ikFrame)
+
+	return legDMotion
+}
+
+// isLegIk 足(またはその子孫)をターゲットかリンクに持つIKボーンか
+func isLegIk(model *pmx.PmxModel, bone *pmx.Bone) bool {
+	if !bone.IsIK() || bone.Ik == nil {
+		return false
+	}
+
+	boneIndexes := []int{bone.Ik.BoneIndex}
+	for _, link := range bone.Ik.Links {
+		boneIndexes = append(boneIndexes, link.BoneIndex)
+	}
+
+	for _, direction := range pmx.LEG.Directions() {
+		leg, err := model.Bones.GetLeg(direction)
+		if err != nil {
+			continue
+		}
+		for _, boneIndex := range boneIndexes {
+			if linkBone, err := model.Bones.Get(boneIndex); err == nil &&
+				(linkBone.Index() == leg.Index() || slices.Contains(linkBone.ParentBoneIndexes, leg.Index())) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// VerifyLegD 通常出力(IKなし)とD系ボーン出力(IK OFF)をそれぞれ変形し、足首位置の最大の差を返す
+func VerifyLegD(rootMotion, legDMotion *vmd.VmdMotion, model *pmx.PmxModel, motionNum, allNum int) float64 {
+	mlog.I("[%d/%d] Verify Leg D ...", motionNum, allNum)
+
+	ankleNames := make([]string, 0)
+	ankleDNames := make([]string, 0)
+	for _, direction := range pmx.ANKLE.Directions() {
+		ankleNames = append(ankleNames, pmx.ANKLE.StringFromDirection(direction))
+		ankleDNames = append(ankleDNames, pmx.ANKLE_D.StringFromDirection(direction))
+	}
+
+	maxFrame := int(rootMotion.MaxFrame())
+	bar := utils.NewProgressBar(maxFrame + 1)
+
+	maxDistance := 0.0
+	maxName := ""
+	maxFno := 0
+	for fno := 0; fno <= maxFrame; fno++ {
+		bar.Increment()
+
+		deltas := deform.DeformBone(model, rootMotion, rootMotion, false, fno, ankleNames)
+		legDDeltas := deform.DeformBone(model, legDMotion, legDMotion, true, fno, ankleDNames)
+
+		for i, ankleName := range ankleNames {
+			ankleDelta := deltas.Bones.GetByName(ankleName)
+			ankleDDelta := legDDeltas.Bones.GetByName(ankleDNames[i])
+			if ankleDelta == nil || ankleDDelta == nil {
+				continue
+			}
+
+			if distance := ankleDelta.FilledGlobalPosition().Distance(ankleDDelta.FilledGlobalPosition()); distance > maxDistance {
+				maxDistance = distance
+				maxName = ankleName
+				maxFno = fno
+			}
+		}
+	}
+
+	bar.Finish()
+
+	if maxDistance > LEG_D_TOLERANCE {
+		mlog.W("[%d/%d] Leg D ankle position differs: %s[%d] %.4f", motionNum, allNum, maxName, maxFno, maxDistance)
+	} else {
+		mlog.I("[%d/%d] Leg D ankle position max difference: %.4f", motionNum, allNum, maxDistance)
+	}
+
+	return maxDistance
+}
@@ -0,0 +1,106 @@
+package usecase
+
+import (
+	"strings"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+)
+
+const (
+	CENTER_SMOOTH_SIGMA = 1.5  // センター(XZ)の平滑化の強さ(フレーム)
+	GROOVE_SMOOTH_SIGMA = 2.0  // グルーブ(Y)の平滑化の強さ(フレーム)
+	ROOT_SMOOTH_SIGMA   = 30.0 // 全ての親に載せる長距離移動の平滑化の強さ(フレーム)
+)
+
+// SplitRootMotion センターの移動を、水平方向のセンターと上下方向のグルーブに分解する
+// useRoot が true の場合、大きな移動(強く平滑化した水平移動)を全ての親に載せ、残りをセンターに残す
+// モデルにグルーブ・全ての親が無い場合、その分の移動はセンターに残す。センターの回転はそのまま引き継ぐ
+func SplitRootMotion(
+	rotMotion *vmd.VmdMotion, model *pmx.PmxModel, useRoot bool, motionNum, allNum int,
+) *vmd.VmdMotion {
+	mlog.I("[%d/%d] Split Root Motion ...", motionNum, allNum)
+
+	rootMotion, err := rotMotion.Copy()
+	if err != nil {
+		mlog.E("Failed to copy motion", err)
+		return rotMotion
+	}
+	rootMotion.SetPath(strings.Replace(rotMotion.Path(), "_limit.vmd", "_root.vmd", -1))
+
+	if !rotMotion.BoneFrames.Contains(pmx.CENTER.String()) {
+		return rootMotion
+	}
+
+	useGroove := model.Bones.ContainsByName(pmx.GROOVE.String())
+	if !useGroove {
+		mlog.W("[%d/%d] Model has no %s, keep vertical motion on %s",
+			motionNum, allNum, pmx.GROOVE.String(), pmx.CENTER.String())
+	}
+	if useRoot && !model.Bones.ContainsByName(pmx.ROOT.String()) {
+		mlog.W("[%d/%d] Model has no %s, keep travel on %s",
+			motionNum, allNum, pmx.ROOT.String(), pmx.CENTER.String())
+		useRoot = false
+	}
+
+	fnos := make([]float64, 0)
+	centerBfs := make([]*vmd.BoneFrame, 0)
+	xs := make([]float64, 0)
+	ys := make([]float64, 0)
+	zs := make([]float64, 0)
+	rotMotion.BoneFrames.Get(pmx.CENTER.String()).ForEach(func(fno float32, bf *vmd.BoneFrame) bool {
+		pos := bf.FilledPosition()
+		fnos = append(fnos, float64(fno))
+		centerBfs = append(centerBfs, bf)
+		xs = append(xs, pos.X)
+		ys = append(ys, pos.Y)
+		zs = append(zs, pos.Z)
+		return true
+	})
+
+	rootXs := make([]float64, len(fnos))
+	rootZs := make([]float64, len(fnos))
+	if useRoot {
+		rootXs = mmath.GaussianSmoothedByFrames(fnos, xs, ROOT_SMOOTH_SIGMA)
+		rootZs = mmath.GaussianSmoothedByFrames(fnos, zs, ROOT_SMOOTH_SIGMA)
+	}
+
+	centerXs := make([]float64, len(fnos))
+	centerZs := make([]float64, len(fnos))
+	for i := range fnos {
+		centerXs[i] = xs[i] - rootXs[i]
+		centerZs[i] = zs[i] - rootZs[i]
+	}
+
+	// チャンネルごとに、フレーム番号を時間軸として個別に平滑化する
+	centerXs = mmath.GaussianSmoothedByFrames(fnos, centerXs, CENTER_SMOOTH_SIGMA)
+	centerZs = mmath.GaussianSmoothedByFrames(fnos, centerZs, CENTER_SMOOTH_SIGMA)
+	grooveYs := mmath.GaussianSmoothedByFrames(fnos, ys, GROOVE_SMOOTH_SIGMA)
+
+	rootMotion.BoneFrames.Delete(pmx.CENTER.String())
+
+	for i, bf := range centerBfs {
+		fno := bf.Index()
+
+		centerBf := bf.Copy().(*vmd.BoneFrame)
+		centerBf.Position = &mmath.MVec3{X: centerXs[i], Y: 0, Z: centerZs[i]}
+		if useGroove {
+			grooveBf := vmd.NewBoneFrame(fno)
+			grooveBf.Position = &mmath.MVec3{X: 0, Y: grooveYs[i], Z: 0}
+			rootMotion.AppendBoneFrame(pmx.GROOVE.String(), grooveBf)
+		} else {
+			centerBf.Position.Y = grooveYs[i]
+		}
+		rootMotion.AppendBoneFrame(pmx.CENTER.String(), centerBf)
+
+		if useRoot {
+			rootBf := vmd.NewBoneFrame(fno)
+			rootBf.Position = &mmath.MVec3{X: rootXs[i], Y: 0, Z: rootZs[i]}
+			rootMotion.AppendBoneFrame(pmx.ROOT.String(), rootBf)
+		}
+	}
+
+	return rootMotion
+}
@@ -0,0 +1,83 @@
+package usecase
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+)
+
+// LoadRetargetOverrides ボーン対応の上書き指定を読み込む
+// 形式: {"標準ボーン名": "モデルのボーン名", ...}
+func LoadRetargetOverrides(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[string]string)
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, err
+	}
+
+	return overrides, nil
+}
+
+// NewRetarget モデルのボーンを標準ボーン名に対応付け、標準ボーン名に置き換えたモデルを返す
+func NewRetarget(model *pmx.PmxModel, overrides map[string]string) (*pmx.BoneRetarget, *pmx.PmxModel, error) {
+	mlog.I("Retarget Bones ...")
+
+	retarget := pmx.NewBoneRetarget(model, overrides)
+	for standardName, modelName := range retarget.Names() {
+		if standardName != modelName {
+			mlog.I("Retarget %s -> %s", standardName, modelName)
+		}
+	}
+
+	retargetedModel, err := retarget.RetargetedModel(model)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return retarget, retargetedModel, nil
+}
+
+// RetargetMotion 標準ボーン名のモーションを、モデルのボーン名のモーションに置き換える
+func RetargetMotion(motion *vmd.VmdMotion, retarget *pmx.BoneRetarget, motionNum, allNum int) *vmd.VmdMotion {
+	mlog.I("[%d/%d] Retarget Motion ...", motionNum, allNum)
+
+	retargetMotion, err := motion.Copy()
+	if err != nil {
+		mlog.E("Failed to copy motion", err)
+		return motion
+	}
+	retargetMotion.SetPath(strings.Replace(motion.Path(), "_root.vmd", "_retarget.vmd", -1))
+
+	boneFrames := vmd.NewBoneFrames()
+	for _, boneName := range retargetMotion.BoneFrames.Names() {
+		boneNameFrames := retargetMotion.BoneFrames.Get(boneName)
+		if modelName, ok := retarget.ModelName(boneName); ok {
+			boneNameFrames.Name = modelName
+		}
+		boneFrames.Update(boneNameFrames)
+	}
+	retargetMotion.BoneFrames = boneFrames
+
+	retargetMotion.IkFrames.ForEach(func(fno float32, ikFrame *vmd.IkFrame) bool {
+		for _, ikEnabledFrame := range ikFrame.IkList {
+			if modelName, ok := retarget.ModelName(ikEnabledFrame.BoneName); ok {
+				ikEnabledFrame.BoneName = modelName
+			}
+		}
+		return true
+	})
+
+	return retargetMotion
+}
@@ -0,0 +1,70 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/usecase/deform"
+)
+
+func TestNewRetarget(t *testing.T) {
+	model := loadTestModel(t)
+	if model.RigidBodies.Length() == 0 || model.Joints.Length() == 0 {
+		t.Fatalf("Expected test model to have rigid bodies and joints")
+	}
+
+	// VRoid 風のボーン名に置き換えたモデル
+	renames := map[string]string{"下半身": "J_Bip_C_Hips", "左ひじ": "J_Bip_L_LowerArm", "首": "J_Bip_C_Neck"}
+	for standardName, modelName := range renames {
+		bone, err := model.Bones.GetByName(standardName)
+		if err != nil {
+			t.Fatalf("Expected %s to exist, got %q", standardName, err)
+		}
+		bone.SetName(modelName)
+	}
+	model.Bones.UpdateNameIndexes()
+	model.Bones.Setup()
+
+	retarget, retargetedModel, err := NewRetarget(model, nil)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	if retargetedModel.Bones.Length() != model.Bones.Length() {
+		t.Errorf("Expected %d bones, got %d", model.Bones.Length(), retargetedModel.Bones.Length())
+	}
+	for standardName, modelName := range renames {
+		if actual, ok := retarget.ModelName(standardName); !ok || actual != modelName {
+			t.Errorf("Expected %s to be %s, got %s", standardName, modelName, actual)
+		}
+		if !retargetedModel.Bones.ContainsByName(standardName) {
+			t.Errorf("Expected retargeted model to have %s", standardName)
+		}
+	}
+	if !model.Bones.ContainsByName("J_Bip_C_Hips") {
+		t.Errorf("Expected original model to be kept")
+	}
+	if retargetedModel.RigidBodies.Length() != model.RigidBodies.Length() ||
+		retargetedModel.Vertices.Length() != model.Vertices.Length() {
+		t.Errorf("Expected rigid bodies and vertices to be kept")
+	}
+
+	// 標準ボーン名のモーションで変形した結果が、モデルのボーン名に戻したモーションと同じ
+	motion := vmd.NewVmdMotion("")
+	bf := vmd.NewBoneFrame(0)
+	bf.Rotation = mmath.NewMQuaternionFromDegrees(0, -60, 0)
+	motion.AppendBoneFrame("左ひじ", bf)
+	retargetMotion := RetargetMotion(motion, retarget, 1, 1)
+	if !retargetMotion.BoneFrames.Contains("J_Bip_L_LowerArm") {
+		t.Fatalf("Expected motion to be retargeted, got %v", retargetMotion.BoneFrames.Names())
+	}
+
+	expected := deform.DeformBone(retargetedModel, motion, motion, false, 0, []string{"左手首"})
+	actual := deform.DeformBone(model, retargetMotion, retargetMotion, false, 0, []string{"左手首"})
+	expectedPos := expected.Bones.GetByName("左手首").FilledGlobalPosition()
+	actualPos := actual.Bones.GetByName("左手首").FilledGlobalPosition()
+	if !actualPos.NearEquals(expectedPos, 1e-4) {
+		t.Errorf("Expected wrist position %v, got %v", expectedPos, actualPos)
+	}
+}
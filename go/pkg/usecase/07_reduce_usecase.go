@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+)
+
+// REDUCE_POSITION_ERROR 間引き後の位置の許容差
+const REDUCE_POSITION_ERROR = 0.05
+
+// REDUCE_DEGREE_ERROR 間引き後の回転の許容差(度)
+const REDUCE_DEGREE_ERROR = 1.0
+
+// REDUCE_RATIO_ERROR 間引き後のモーフの割合の許容差
+const REDUCE_RATIO_ERROR = 0.01
+
+// Reduce ボーン・カメラのキーフレームを補間曲線で、モーフのキーフレームを線形補間で近似して間引いたモーション
+func Reduce(motion *vmd.VmdMotion, maxPositionError, maxDegreeError float64, motionNum, allNum int) *vmd.VmdMotion {
+	mlog.I("[%d/%d] Reduce ...", motionNum, allNum)
+
+	reduceMotion, err := motion.Copy()
+	if err != nil {
+		mlog.E("Failed to copy motion", err)
+		return motion
+	}
+
+	reduceMotion.BoneFrames = motion.BoneFrames.Reduce(maxPositionError, maxDegreeError)
+	reduceMotion.MorphFrames = motion.MorphFrames.Reduce(REDUCE_RATIO_ERROR)
+	reduceMotion.CameraFrames = motion.CameraFrames.Reduce(maxPositionError, maxDegreeError)
+
+	mlog.I("[%d/%d] Reduce bone frames: %d -> %d", motionNum, allNum,
+		countBoneFrames(motion), countBoneFrames(reduceMotion))
+	mlog.I("[%d/%d] Reduce morph frames: %d -> %d", motionNum, allNum,
+		countMorphFrames(motion), countMorphFrames(reduceMotion))
+	mlog.I("[%d/%d] Reduce camera frames: %d -> %d", motionNum, allNum,
+		motion.CameraFrames.Length(), reduceMotion.CameraFrames.Length())
+
+	return reduceMotion
+}
+
+// countBoneFrames ボーンのキーフレーム数の合計
+func countBoneFrames(motion *vmd.VmdMotion) int {
+	count := 0
+	motion.BoneFrames.ForEach(func(boneName string, boneNameFrames *vmd.BoneNameFrames) {
+		count += boneNameFrames.Length()
+	})
+	return count
+}
+
+// countMorphFrames モーフのキーフレーム数の合計
+func countMorphFrames(motion *vmd.VmdMotion) int {
+	count := 0
+	motion.MorphFrames.ForEach(func(morphName string, morphNameFrames *vmd.MorphNameFrames) {
+		count += morphNameFrames.Length()
+	})
+	return count
+}
@@ -0,0 +1,406 @@
+package usecase
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mcsv"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mjson"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/infrastructure/repository"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/usecase/deform"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/utils"
+)
+
+// EVALUATE_ERROR_THRESHOLD 手直しが必要なフレームとする、関節位置の平均誤差(MPJPE)の閾値
+const EVALUATE_ERROR_THRESHOLD = 1.5
+
+// EVALUATE_REPROJECTION_THRESHOLD 手直しが必要なフレームとする、2D関節位置との平均誤差(ピクセル)の閾値
+const EVALUATE_REPROJECTION_THRESHOLD = 20.0
+
+// TrackingError 変形したボーン位置と、トラッキングした関節位置の誤差
+type TrackingError struct {
+	JointNames         []string    // 評価した関節名
+	BoneNames          []string    // 関節に対応するボーン名
+	Frames             []int       // 評価したフレーム
+	Errors             [][]float64 // フレームごと・関節ごとの3D誤差(関節が無いフレームは NaN)
+	ReprojectionErrors [][]float64 // フレームごと・関節ごとの2D誤差(ピクセル、関節が無いフレームは NaN)
+	ReprojectionFailed []bool      // フレームごとの、2D関節があるのに画像に投影できなかったか
+}
+
+// reprojectionPoint 変形したボーン位置を画像に投影した位置と、対応する2D関節位置
+type reprojectionPoint struct {
+	jointIndex int
+	projected  *mmath.MVec2
+	keypoint   *mmath.MVec2
+}
+
+// EvaluateTracking モーションをモデルで変形し、関節に対応するボーンの位置とトラッキングした関節位置の誤差を求める
+// 3D誤差は、どちらも下半身を原点に揃えて比べる(MPJPE)。関節位置は Joint3D(無い場合は GlobalJoint3D)を使う
+// 2D誤差は、変形したボーンのグローバル位置を Move と逆の変換でトラッキングのカメラ座標に戻し、
+// 推定カメラで画像に投影して Joint2D と比べる(Joint3D・Joint2D がある場合のみ)
+// 画像への変換(焦点距離・切り出し位置)は、クリップ全体のトラッキングの3D関節と2D関節の組から1つ求める
+func EvaluateTracking(
+	frames *mjson.Frames, mapping *mjson.JointMapping, model *pmx.PmxModel, motion *vmd.VmdMotion,
+	scale, minY, maxZ float64, motionNum, allNum int,
+) *TrackingError {
+	mlog.I("[%d/%d] Evaluate Tracking ...", motionNum, allNum)
+
+	rootName := pmx.LOWER.String()
+	trackingError := &TrackingError{
+		JointNames:         make([]string, 0),
+		BoneNames:          make([]string, 0),
+		Frames:             make([]int, 0, len(frames.Frames)),
+		Errors:             make([][]float64, 0, len(frames.Frames)),
+		ReprojectionErrors: make([][]float64, 0, len(frames.Frames)),
+		ReprojectionFailed: make([]bool, 0, len(frames.Frames)),
+	}
+	for _, joint := range mapping.JointsByHierarchy() {
+		if joint.Bone != "" && joint.Bone != rootName && model.Bones.ContainsByName(joint.Bone) {
+			trackingError.JointNames = append(trackingError.JointNames, joint.Name)
+			trackingError.BoneNames = append(trackingError.BoneNames, joint.Bone)
+		}
+	}
+	deformBoneNames := append(slices.Clone(trackingError.BoneNames), rootName)
+
+	// センターの移動はトラッキングの下半身の位置なので、変形した位置にはモデルの下半身の位置が加わっている
+	restRoot := mmath.NewMVec3()
+	if rootBone, err := model.Bones.GetByName(rootName); err == nil {
+		restRoot = rootBone.Position
+	}
+	// Move で引いた基準位置
+	moveOffset := &mmath.MVec3{X: 0, Y: minY * scale, Z: maxZ * scale}
+
+	fnos := make([]int, 0, len(frames.Frames))
+	for fno := range frames.Frames {
+		fnos = append(fnos, fno)
+	}
+	slices.Sort(fnos)
+
+	// 画像への変換を求めるための、クリップ全体のトラッキングの3D関節を投影した位置と2D関節位置
+	trackedPoints := make([]*mmath.MVec2, 0)
+	imagePoints := make([]*mmath.MVec2, 0)
+	// フレームごとの、変形したボーン位置を投影した位置(2D関節が無いフレームは nil)
+	framePoints := make([][]*reprojectionPoint, 0, len(fnos))
+
+	bar := utils.NewProgressBar(len(fnos))
+
+	for _, fno := range fnos {
+		bar.Increment()
+
+		frame := frames.Frames[fno]
+		joints := frame.Joint3D
+		if len(joints) == 0 {
+			joints = frame.GlobalJoint3D
+		}
+		trackedPositions := mapping.BonePositions(joints, func(pos mjson.Position) *mmath.MVec3 {
+			return mapping.Flipped(pos).MulScalar(scale)
+		})
+		trackedRoot, ok := trackedPositions[rootName]
+		if !ok {
+			continue
+		}
+
+		deltas := deform.DeformBone(model, motion, motion, true, fno, deformBoneNames)
+		rootDelta := deltas.Bones.GetByName(rootName)
+		if rootDelta == nil {
+			continue
+		}
+		deformedRoot := rootDelta.FilledGlobalPosition()
+
+		errors := make([]float64, len(trackingError.BoneNames))
+		for i, boneName := range trackingError.BoneNames {
+			errors[i] = math.NaN()
+
+			boneDelta := deltas.Bones.GetByName(boneName)
+			trackedPos, ok := trackedPositions[boneName]
+			if boneDelta == nil || !ok {
+				continue
+			}
+			errors[i] = boneDelta.FilledGlobalPosition().Subed(deformedRoot).Distance(trackedPos.Subed(trackedRoot))
+		}
+
+		var points []*reprojectionPoint
+		if len(frame.Joint3D) > 0 && len(frame.Joint2D) > 0 {
+			// 推定カメラは Joint3D と同じ座標系なので、スケール・軸反転を戻してから投影する
+			camera := &mmath.MVec3{X: frame.Camera.X, Y: frame.Camera.Y, Z: frame.Camera.Z}
+			project := func(pos *mmath.MVec3) *mmath.MVec2 {
+				raw := mapping.Flipped(mjson.Position{X: pos.X / scale, Y: pos.Y / scale, Z: pos.Z / scale})
+				return projectPerspective(raw.Added(camera))
+			}
+			keypoints := mapping.BonePositions(frame.Joint2D, func(pos mjson.Position) *mmath.MVec3 {
+				return &mmath.MVec3{X: pos.X, Y: pos.Y}
+			})
+
+			points = make([]*reprojectionPoint, 0, len(trackingError.BoneNames))
+			for i, boneName := range trackingError.BoneNames {
+				keypoint, ok := keypoints[boneName]
+				if !ok {
+					continue
+				}
+				imagePoint := &mmath.MVec2{X: keypoint.X, Y: keypoint.Y}
+
+				if trackedPos, ok := trackedPositions[boneName]; ok {
+					if point := project(trackedPos); point != nil {
+						trackedPoints = append(trackedPoints, point)
+						imagePoints = append(imagePoints, imagePoint)
+					}
+				}
+
+				if boneDelta := deltas.Bones.GetByName(boneName); boneDelta != nil {
+					// 下半身を揃え直さず、グローバル位置をそのまま Move の前の位置に戻す
+					deformedPos := boneDelta.FilledGlobalPosition().Subed(restRoot).Added(moveOffset)
+					if point := project(deformedPos); point != nil {
+						points = append(points, &reprojectionPoint{jointIndex: i, projected: point, keypoint: imagePoint})
+					}
+				}
+			}
+		}
+
+		trackingError.Frames = append(trackingError.Frames, fno)
+		trackingError.Errors = append(trackingError.Errors, errors)
+		framePoints = append(framePoints, points)
+	}
+
+	bar.Finish()
+
+	imageScale, imageOffset, fitted := fitImageTransform(trackedPoints, imagePoints)
+	if !fitted && len(trackedPoints) > 0 {
+		mlog.W("[%d/%d] Failed to fit image transform, all frames with 2D joints are flagged", motionNum, allNum)
+	}
+
+	for _, points := range framePoints {
+		reprojectionErrors := make([]float64, len(trackingError.BoneNames))
+		for i := range reprojectionErrors {
+			reprojectionErrors[i] = math.NaN()
+		}
+		if fitted {
+			for _, point := range points {
+				reprojectionErrors[point.jointIndex] =
+					point.projected.MuledScalar(imageScale).Added(imageOffset).Distance(point.keypoint)
+			}
+		}
+
+		trackingError.ReprojectionErrors = append(trackingError.ReprojectionErrors, reprojectionErrors)
+		trackingError.ReprojectionFailed = append(trackingError.ReprojectionFailed,
+			points != nil && (!fitted || len(points) == 0))
+	}
+
+	return trackingError
+}
+
+// projectPerspective カメラ座標の位置を透視投影する(カメラの後ろにある場合は nil)
+func projectPerspective(pos *mmath.MVec3) *mmath.MVec2 {
+	if pos.Z < 1e-6 {
+		return nil
+	}
+	return &mmath.MVec2{X: pos.X / pos.Z, Y: pos.Y / pos.Z}
+}
+
+// fitImageTransform 投影した位置を画像の位置に合わせる拡大率と平行移動(最小二乗)
+func fitImageTransform(projected, image []*mmath.MVec2) (float64, *mmath.MVec2, bool) {
+	if len(projected) < 2 {
+		return 0, nil, false
+	}
+
+	projectedMean := mmath.NewMVec2()
+	imageMean := mmath.NewMVec2()
+	for i := range projected {
+		projectedMean.Add(projected[i])
+		imageMean.Add(image[i])
+	}
+	projectedMean.DivScalar(float64(len(projected)))
+	imageMean.DivScalar(float64(len(image)))
+
+	numerator := 0.0
+	denominator := 0.0
+	for i := range projected {
+		p := projected[i].Subed(projectedMean)
+		numerator += p.Dot(image[i].Subed(imageMean))
+		denominator += p.LengthSqr()
+	}
+	if denominator < 1e-12 || numerator <= 0 {
+		return 0, nil, false
+	}
+
+	imageScale := numerator / denominator
+	return imageScale, imageMean.Subed(projectedMean.MuledScalar(imageScale)), true
+}
+
+// FrameMpjpe フレームの関節位置の平均誤差(関節が無い場合は NaN)
+func (trackingError *TrackingError) FrameMpjpe(frameIndex int) float64 {
+	return nanMean(trackingError.Errors[frameIndex])
+}
+
+// JointMean 関節の全フレームの平均誤差(関節が無い場合は NaN)
+func (trackingError *TrackingError) JointMean(jointIndex int) float64 {
+	return jointNanMean(trackingError.Errors, jointIndex)
+}
+
+// Mpjpe 全フレーム・全関節の平均誤差
+func (trackingError *TrackingError) Mpjpe() float64 {
+	return allNanMean(trackingError.Errors)
+}
+
+// FlaggedFrames 平均誤差が threshold を超えたフレーム
+func (trackingError *TrackingError) FlaggedFrames(threshold float64) []int {
+	flagged := make([]int, 0)
+	for i, fno := range trackingError.Frames {
+		if trackingError.FrameMpjpe(i) > threshold {
+			flagged = append(flagged, fno)
+		}
+	}
+	return flagged
+}
+
+// FrameReprojection フレームの2D関節位置の平均誤差(関節が無い場合は NaN)
+func (trackingError *TrackingError) FrameReprojection(frameIndex int) float64 {
+	return nanMean(trackingError.ReprojectionErrors[frameIndex])
+}
+
+// JointReprojectionMean 関節の全フレームの2D平均誤差(関節が無い場合は NaN)
+func (trackingError *TrackingError) JointReprojectionMean(jointIndex int) float64 {
+	return jointNanMean(trackingError.ReprojectionErrors, jointIndex)
+}
+
+// Reprojection 全フレーム・全関節の2D平均誤差
+func (trackingError *TrackingError) Reprojection() float64 {
+	return allNanMean(trackingError.ReprojectionErrors)
+}
+
+// ReprojectionFlaggedFrames 2D平均誤差が threshold を超えたか、画像に投影できなかったフレーム
+func (trackingError *TrackingError) ReprojectionFlaggedFrames(threshold float64) []int {
+	flagged := make([]int, 0)
+	for i, fno := range trackingError.Frames {
+		if trackingError.isReprojectionFlagged(i, threshold) {
+			flagged = append(flagged, fno)
+		}
+	}
+	return flagged
+}
+
+// isReprojectionFlagged フレームの2D平均誤差が threshold を超えたか、画像に投影できなかったか
+func (trackingError *TrackingError) isReprojectionFlagged(frameIndex int, threshold float64) bool {
+	return trackingError.ReprojectionFailed[frameIndex] || trackingError.FrameReprojection(frameIndex) > threshold
+}
+
+// LogTrackingError 全体と関節ごとの平均誤差、閾値を超えたフレームの範囲を出力する
+func LogTrackingError(
+	trackingError *TrackingError, threshold, reprojectionThreshold float64, motionNum, allNum int,
+) {
+	mlog.I("[%d/%d] MPJPE: %.4f, Reprojection: %.4f px (%d frames)", motionNum, allNum,
+		trackingError.Mpjpe(), trackingError.Reprojection(), len(trackingError.Frames))
+	for i, jointName := range trackingError.JointNames {
+		mlog.I("[%d/%d] %10.4f %10.4f  %s (%s)", motionNum, allNum,
+			trackingError.JointMean(i), trackingError.JointReprojectionMean(i), jointName, trackingError.BoneNames[i])
+	}
+
+	if flagged := trackingError.FlaggedFrames(threshold); len(flagged) > 0 {
+		mlog.W("[%d/%d] %d frames exceed MPJPE %.2f: %s", motionNum, allNum, len(flagged), threshold,
+			formatFrameRanges(flagged))
+	}
+	if flagged := trackingError.ReprojectionFlaggedFrames(reprojectionThreshold); len(flagged) > 0 {
+		mlog.W("[%d/%d] %d frames exceed reprojection error %.2f px: %s", motionNum, allNum, len(flagged),
+			reprojectionThreshold, formatFrameRanges(flagged))
+	}
+}
+
+// SaveTrackingReport フレームごとの平均誤差・閾値を超えたか・関節ごとの誤差をCSVに保存する
+// 2D誤差の列は、関節名に _2d を付ける。保存先は、モーションと同じ名前の _eval.csv
+func SaveTrackingReport(
+	trackingError *TrackingError, frames *mjson.Frames, threshold, reprojectionThreshold float64, dirPath string,
+) (string, error) {
+	header := []string{"frame", "mpjpe", "flagged", "reprojection", "reprojection_flagged"}
+	header = append(header, trackingError.JointNames...)
+	for _, jointName := range trackingError.JointNames {
+		header = append(header, jointName+"_2d")
+	}
+	records := [][]string{header}
+	for i, fno := range trackingError.Frames {
+		mpjpe := trackingError.FrameMpjpe(i)
+		reprojection := trackingError.FrameReprojection(i)
+		record := []string{strconv.Itoa(fno),
+			formatError(mpjpe), strconv.FormatBool(mpjpe > threshold),
+			formatError(reprojection), strconv.FormatBool(trackingError.isReprojectionFlagged(i, reprojectionThreshold))}
+		for _, value := range trackingError.Errors[i] {
+			record = append(record, formatError(value))
+		}
+		for _, value := range trackingError.ReprojectionErrors[i] {
+			record = append(record, formatError(value))
+		}
+		records = append(records, record)
+	}
+
+	path := filepath.Join(dirPath, strings.Replace(filepath.Base(frames.Path), ".json", "_eval.csv", -1))
+	model := mcsv.NewCsvModel(records)
+	model.SetPath(path)
+
+	return path, repository.NewCsvRepository().Save(path, model, false)
+}
+
+// nanMean NaN を除いた平均(全て NaN の場合は NaN)
+func nanMean(values []float64) float64 {
+	sum := 0.0
+	count := 0
+	for _, value := range values {
+		if !math.IsNaN(value) {
+			sum += value
+			count++
+		}
+	}
+	if count == 0 {
+		return math.NaN()
+	}
+	return sum / float64(count)
+}
+
+// jointNanMean 関節の全フレームの、NaN を除いた平均
+func jointNanMean(errors [][]float64, jointIndex int) float64 {
+	values := make([]float64, len(errors))
+	for i, frameErrors := range errors {
+		values[i] = frameErrors[jointIndex]
+	}
+	return nanMean(values)
+}
+
+// allNanMean 全フレーム・全関節の、NaN を除いた平均
+func allNanMean(errors [][]float64) float64 {
+	values := make([]float64, 0)
+	for _, frameErrors := range errors {
+		values = append(values, frameErrors...)
+	}
+	return nanMean(values)
+}
+
+// formatError CSVに出力する誤差の値(NaN は空欄)
+func formatError(value float64) string {
+	if math.IsNaN(value) {
+		return ""
+	}
+	return formatDiff(value)
+}
+
+// formatFrameRanges 連続したフレームをまとめた文字列(例: 10-15, 20)
+func formatFrameRanges(fnos []int) string {
+	ranges := make([]string, 0)
+	for i := 0; i < len(fnos); {
+		j := i
+		for j+1 < len(fnos) && fnos[j+1] == fnos[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(fnos[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", fnos[i], fnos[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(ranges, ", ")
+}
@@ -0,0 +1,129 @@
+package usecase
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+)
+
+func TestNanMean(t *testing.T) {
+	tests := []struct {
+		values   []float64
+		expected float64
+	}{
+		{[]float64{1, 2, 3}, 2},
+		{[]float64{1, math.NaN(), 3}, 2},
+		{[]float64{math.NaN(), math.NaN()}, math.NaN()},
+		{[]float64{}, math.NaN()},
+	}
+	for _, test := range tests {
+		actual := nanMean(test.values)
+		if math.IsNaN(test.expected) {
+			if !math.IsNaN(actual) {
+				t.Errorf("Expected mean of %v to be NaN, got %v", test.values, actual)
+			}
+		} else if math.Abs(actual-test.expected) > 1e-8 {
+			t.Errorf("Expected mean of %v to be %v, got %v", test.values, test.expected, actual)
+		}
+	}
+}
+
+func TestTrackingError_FlaggedFrames(t *testing.T) {
+	nan := math.NaN()
+	trackingError := &TrackingError{
+		JointNames: []string{"left_knee", "right_knee"},
+		BoneNames:  []string{"左ひざ", "右ひざ"},
+		Frames:     []int{0, 1, 2, 5},
+		Errors: [][]float64{
+			{1.0, 1.0},
+			{2.0, 1.5},
+			{nan, 3.0},
+			{nan, nan},
+		},
+		ReprojectionErrors: [][]float64{
+			{10, 40},
+			{nan, nan},
+			{5, 5},
+			{30, nan},
+		},
+		ReprojectionFailed: []bool{false, true, false, false},
+	}
+
+	// 平均が閾値を超えたフレームだけ(関節が無いフレームは超えない)
+	if flagged := trackingError.FlaggedFrames(1.5); !slices.Equal(flagged, []int{1, 2}) {
+		t.Errorf("Expected flagged frames [1 2], got %v", flagged)
+	}
+	// 画像に投影できなかったフレームも含む
+	if flagged := trackingError.ReprojectionFlaggedFrames(20); !slices.Equal(flagged, []int{0, 1, 5}) {
+		t.Errorf("Expected reprojection flagged frames [0 1 5], got %v", flagged)
+	}
+
+	if mpjpe := trackingError.Mpjpe(); math.Abs(mpjpe-8.5/5) > 1e-8 {
+		t.Errorf("Expected MPJPE to be %v, got %v", 8.5/5, mpjpe)
+	}
+	if mean := trackingError.JointMean(0); math.Abs(mean-1.5) > 1e-8 {
+		t.Errorf("Expected joint mean to be 1.5, got %v", mean)
+	}
+}
+
+func TestFormatFrameRanges(t *testing.T) {
+	tests := []struct {
+		fnos     []int
+		expected string
+	}{
+		{[]int{}, ""},
+		{[]int{3}, "3"},
+		{[]int{10, 11, 12, 13, 14, 15, 20}, "10-15, 20"},
+		{[]int{1, 3, 4, 6}, "1, 3-4, 6"},
+	}
+	for _, test := range tests {
+		if actual := formatFrameRanges(test.fnos); actual != test.expected {
+			t.Errorf("Expected %v to be formatted as %q, got %q", test.fnos, test.expected, actual)
+		}
+	}
+}
+
+func TestProjectPerspective(t *testing.T) {
+	actual := projectPerspective(&mmath.MVec3{X: 2, Y: -4, Z: 4})
+	if actual == nil || math.Abs(actual.X-0.5) > 1e-8 || math.Abs(actual.Y+1) > 1e-8 {
+		t.Errorf("Expected projection to be (0.5, -1), got %v", actual)
+	}
+
+	// カメラの後ろにある位置は投影しない
+	if actual := projectPerspective(&mmath.MVec3{X: 2, Y: -4, Z: -4}); actual != nil {
+		t.Errorf("Expected point behind camera not to be projected, got %v", actual)
+	}
+}
+
+func TestFitImageTransform(t *testing.T) {
+	projected := []*mmath.MVec2{{X: -0.2, Y: 0.1}, {X: 0.3, Y: -0.4}, {X: 0.05, Y: 0.25}}
+	image := func(imageScale float64, imageOffset *mmath.MVec2) []*mmath.MVec2 {
+		points := make([]*mmath.MVec2, 0, len(projected))
+		for _, p := range projected {
+			points = append(points, p.MuledScalar(imageScale).Added(imageOffset))
+		}
+		return points
+	}
+
+	offset := &mmath.MVec2{X: 320, Y: 240}
+	imageScale, imageOffset, ok := fitImageTransform(projected, image(800, offset))
+	if !ok || math.Abs(imageScale-800) > 1e-6 || imageOffset.Distance(offset) > 1e-6 {
+		t.Errorf("Expected scale 800 and offset %v, got %v, %v, %v", offset, imageScale, imageOffset, ok)
+	}
+
+	// 左右上下が反転している(スケールが負になる)組は求めない
+	if _, _, ok := fitImageTransform(projected, image(-800, offset)); ok {
+		t.Errorf("Expected mirrored points not to be fitted")
+	}
+
+	// 1点だけ・全て同じ位置の組は求まらない
+	if _, _, ok := fitImageTransform(projected[:1], image(800, offset)[:1]); ok {
+		t.Errorf("Expected single point not to be fitted")
+	}
+	same := []*mmath.MVec2{{X: 0.1, Y: 0.1}, {X: 0.1, Y: 0.1}}
+	if _, _, ok := fitImageTransform(same, image(800, offset)[:2]); ok {
+		t.Errorf("Expected degenerate points not to be fitted")
+	}
+}
@@ -0,0 +1,237 @@
+package usecase
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mcsv"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/infrastructure/repository"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/usecase/deform"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/utils"
+)
+
+// COMPARE_WORST_FRAMES 比較結果に表示する、差の大きいフレーム数
+const COMPARE_WORST_FRAMES = 10
+
+// MotionDiff 2つのモーションで変形したボーンの、グローバル位置・回転の差
+type MotionDiff struct {
+	BoneNames     []string    // 比較したボーン名
+	PositionDiffs [][]float64 // フレームごと・ボーンごとのグローバル位置の差
+	DegreeDiffs   [][]float64 // フレームごと・ボーンごとのグローバル回転の差(度)
+}
+
+// BoneDiffStat ボーンごとの差の統計
+type BoneDiffStat struct {
+	BoneName         string
+	MeanPosition     float64
+	MaxPosition      float64
+	MaxPositionFrame int
+	MeanDegree       float64
+	MaxDegree        float64
+	MaxDegreeFrame   int
+}
+
+// FrameDiffStat フレームごとの差の統計
+type FrameDiffStat struct {
+	Frame           int
+	MeanPosition    float64
+	MaxPosition     float64
+	MaxPositionBone string
+	MeanDegree      float64
+	MaxDegree       float64
+	MaxDegreeBone   string
+}
+
+// CompareMotions 2つのモーションを全フレームIKありで変形し、ボーンのグローバル位置・回転の差を求める
+// 比較するのは、準標準ボーンと、IKで動くボーン(IKリンク・IKターゲット)と、どちらかのモーションにキーフレームがあるボーン
+// IKで動くボーンはキーフレームが無くても、IKボーンのキーフレームで動く
+func CompareMotions(model *pmx.PmxModel, motionA, motionB *vmd.VmdMotion) *MotionDiff {
+	mlog.I("Compare motions ...")
+
+	diff := &MotionDiff{BoneNames: make([]string, 0)}
+	model.Bones.ForEach(func(index int, bone *pmx.Bone) bool {
+		if bone.Config() != nil || len(bone.IkLinkBoneIndexes) > 0 || len(bone.IkTargetBoneIndexes) > 0 ||
+			motionA.BoneFrames.Contains(bone.Name()) || motionB.BoneFrames.Contains(bone.Name()) {
+			diff.BoneNames = append(diff.BoneNames, bone.Name())
+		}
+		return true
+	})
+
+	maxFrame := int(max(motionA.MaxFrame(), motionB.MaxFrame()))
+	diff.PositionDiffs = make([][]float64, maxFrame+1)
+	diff.DegreeDiffs = make([][]float64, maxFrame+1)
+
+	bar := utils.NewProgressBar(maxFrame + 1)
+	for fno := 0; fno <= maxFrame; fno++ {
+		bar.Increment()
+
+		deltasA := deform.DeformBone(model, motionA, motionA, true, fno, diff.BoneNames)
+		deltasB := deform.DeformBone(model, motionB, motionB, true, fno, diff.BoneNames)
+
+		diff.PositionDiffs[fno] = make([]float64, len(diff.BoneNames))
+		diff.DegreeDiffs[fno] = make([]float64, len(diff.BoneNames))
+		for i, boneName := range diff.BoneNames {
+			deltaA := deltasA.Bones.GetByName(boneName)
+			deltaB := deltasB.Bones.GetByName(boneName)
+			if deltaA == nil || deltaB == nil {
+				continue
+			}
+
+			diff.PositionDiffs[fno][i] = deltaA.FilledGlobalPosition().Distance(deltaB.FilledGlobalPosition())
+
+			rotationA := deltaA.FilledGlobalMatrix().Quaternion()
+			rotationB := deltaB.FilledGlobalMatrix().Quaternion()
+			diff.DegreeDiffs[fno][i] = mmath.RadToDeg(
+				2 * math.Acos(mmath.Clamped(math.Abs(rotationA.Normalized().Dot(rotationB.Normalized())), 0, 1)))
+		}
+	}
+
+	bar.Finish()
+
+	return diff
+}
+
+// BoneStats ボーンごとの差の平均・最大
+func (diff *MotionDiff) BoneStats() []*BoneDiffStat {
+	stats := make([]*BoneDiffStat, len(diff.BoneNames))
+	for i, boneName := range diff.BoneNames {
+		stat := &BoneDiffStat{BoneName: boneName}
+		for fno := range diff.PositionDiffs {
+			position := diff.PositionDiffs[fno][i]
+			degree := diff.DegreeDiffs[fno][i]
+			stat.MeanPosition += position
+			stat.MeanDegree += degree
+			if position > stat.MaxPosition {
+				stat.MaxPosition = position
+				stat.MaxPositionFrame = fno
+			}
+			if degree > stat.MaxDegree {
+				stat.MaxDegree = degree
+				stat.MaxDegreeFrame = fno
+			}
+		}
+		if frameCount := len(diff.PositionDiffs); frameCount > 0 {
+			stat.MeanPosition /= float64(frameCount)
+			stat.MeanDegree /= float64(frameCount)
+		}
+		stats[i] = stat
+	}
+	return stats
+}
+
+// FrameStats フレームごとの差の平均・最大
+func (diff *MotionDiff) FrameStats() []*FrameDiffStat {
+	stats := make([]*FrameDiffStat, len(diff.PositionDiffs))
+	for fno := range diff.PositionDiffs {
+		stat := &FrameDiffStat{Frame: fno}
+		for i, boneName := range diff.BoneNames {
+			position := diff.PositionDiffs[fno][i]
+			degree := diff.DegreeDiffs[fno][i]
+			stat.MeanPosition += position
+			stat.MeanDegree += degree
+			if position > stat.MaxPosition {
+				stat.MaxPosition = position
+				stat.MaxPositionBone = boneName
+			}
+			if degree > stat.MaxDegree {
+				stat.MaxDegree = degree
+				stat.MaxDegreeBone = boneName
+			}
+		}
+		if boneCount := len(diff.BoneNames); boneCount > 0 {
+			stat.MeanPosition /= float64(boneCount)
+			stat.MeanDegree /= float64(boneCount)
+		}
+		stats[fno] = stat
+	}
+	return stats
+}
+
+// WorstFrames 位置の差の平均が大きい順(同じ場合は回転の差の平均が大きい順)に count フレーム
+func (diff *MotionDiff) WorstFrames(count int) []*FrameDiffStat {
+	stats := diff.FrameStats()
+	slices.SortStableFunc(stats, func(a, b *FrameDiffStat) int {
+		return cmp.Or(cmp.Compare(b.MeanPosition, a.MeanPosition), cmp.Compare(b.MeanDegree, a.MeanDegree))
+	})
+	return stats[:min(count, len(stats))]
+}
+
+// LogMotionDiff ボーンごとの差と、差の大きいフレームを表で出力する
+func LogMotionDiff(diff *MotionDiff, worstCount int) {
+	boneStats := diff.BoneStats()
+
+	mlog.I("%10s %10s %6s %10s %10s %6s  %s",
+		"mean pos", "max pos", "frame", "mean deg", "max deg", "frame", "bone")
+	meanPosition := 0.0
+	meanDegree := 0.0
+	for _, stat := range boneStats {
+		mlog.I("%10.4f %10.4f %6d %10.4f %10.4f %6d  %s",
+			stat.MeanPosition, stat.MaxPosition, stat.MaxPositionFrame,
+			stat.MeanDegree, stat.MaxDegree, stat.MaxDegreeFrame, stat.BoneName)
+		meanPosition += stat.MeanPosition
+		meanDegree += stat.MeanDegree
+	}
+	if len(boneStats) > 0 {
+		mlog.I("%10.4f %10s %6s %10.4f %10s %6s  %s",
+			meanPosition/float64(len(boneStats)), "", "", meanDegree/float64(len(boneStats)), "", "", "(all)")
+	}
+
+	mlog.I("")
+	mlog.I("%6s %10s %10s %10s %10s  %s", "frame", "mean pos", "max pos", "mean deg", "max deg", "max pos bone")
+	for _, stat := range diff.WorstFrames(worstCount) {
+		mlog.I("%6d %10.4f %10.4f %10.4f %10.4f  %s",
+			stat.Frame, stat.MeanPosition, stat.MaxPosition, stat.MeanDegree, stat.MaxDegree, stat.MaxPositionBone)
+	}
+}
+
+// SaveMotionDiffCsv ボーンごと・フレームごとの差を、それぞれ <outputPath>_bones.csv と <outputPath>_frames.csv に保存する
+func SaveMotionDiffCsv(diff *MotionDiff, outputPath string) ([]string, error) {
+	boneRecords := [][]string{{"bone", "mean_position", "max_position", "max_position_frame",
+		"mean_degree", "max_degree", "max_degree_frame"}}
+	for _, stat := range diff.BoneStats() {
+		boneRecords = append(boneRecords, []string{
+			stat.BoneName,
+			formatDiff(stat.MeanPosition), formatDiff(stat.MaxPosition), strconv.Itoa(stat.MaxPositionFrame),
+			formatDiff(stat.MeanDegree), formatDiff(stat.MaxDegree), strconv.Itoa(stat.MaxDegreeFrame),
+		})
+	}
+
+	frameRecords := [][]string{{"frame", "mean_position", "max_position", "max_position_bone",
+		"mean_degree", "max_degree", "max_degree_bone"}}
+	for _, stat := range diff.FrameStats() {
+		frameRecords = append(frameRecords, []string{
+			strconv.Itoa(stat.Frame),
+			formatDiff(stat.MeanPosition), formatDiff(stat.MaxPosition), stat.MaxPositionBone,
+			formatDiff(stat.MeanDegree), formatDiff(stat.MaxDegree), stat.MaxDegreeBone,
+		})
+	}
+
+	rep := repository.NewCsvRepository()
+	paths := make([]string, 0, 2)
+	for _, output := range []struct {
+		suffix  string
+		records [][]string
+	}{{"_bones.csv", boneRecords}, {"_frames.csv", frameRecords}} {
+		path := fmt.Sprintf("%s%s", outputPath, output.suffix)
+		model := mcsv.NewCsvModel(output.records)
+		model.SetPath(path)
+		if err := rep.Save(path, model, false); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// formatDiff CSVに出力する差の値
+func formatDiff(value float64) string {
+	return strconv.FormatFloat(value, 'f', 6, 64)
+}
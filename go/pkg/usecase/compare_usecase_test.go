@@ -0,0 +1,126 @@
+package usecase
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+)
+
+// newTestMotionDiff 3フレーム・2ボーンの差
+func newTestMotionDiff() *MotionDiff {
+	return &MotionDiff{
+		BoneNames: []string{"センター", "左足"},
+		PositionDiffs: [][]float64{
+			{0.0, 0.2},
+			{0.3, 0.1},
+			{0.3, 0.5},
+		},
+		DegreeDiffs: [][]float64{
+			{1.0, 4.0},
+			{2.0, 0.0},
+			{6.0, 2.0},
+		},
+	}
+}
+
+func TestMotionDiff_BoneStats(t *testing.T) {
+	stats := newTestMotionDiff().BoneStats()
+
+	expected := []*BoneDiffStat{
+		{BoneName: "センター", MeanPosition: 0.2, MaxPosition: 0.3, MaxPositionFrame: 1,
+			MeanDegree: 3.0, MaxDegree: 6.0, MaxDegreeFrame: 2},
+		{BoneName: "左足", MeanPosition: 0.8 / 3, MaxPosition: 0.5, MaxPositionFrame: 2,
+			MeanDegree: 2.0, MaxDegree: 4.0, MaxDegreeFrame: 0},
+	}
+
+	if len(stats) != len(expected) {
+		t.Fatalf("Expected %d stats, got %d", len(expected), len(stats))
+	}
+	for i, stat := range stats {
+		e := expected[i]
+		if stat.BoneName != e.BoneName || math.Abs(stat.MeanPosition-e.MeanPosition) > 1e-8 ||
+			stat.MaxPosition != e.MaxPosition || stat.MaxPositionFrame != e.MaxPositionFrame ||
+			math.Abs(stat.MeanDegree-e.MeanDegree) > 1e-8 ||
+			stat.MaxDegree != e.MaxDegree || stat.MaxDegreeFrame != e.MaxDegreeFrame {
+			t.Errorf("Expected %+v, got %+v", e, stat)
+		}
+	}
+}
+
+func TestMotionDiff_FrameStats(t *testing.T) {
+	stats := newTestMotionDiff().FrameStats()
+
+	expected := []*FrameDiffStat{
+		{Frame: 0, MeanPosition: 0.1, MaxPosition: 0.2, MaxPositionBone: "左足",
+			MeanDegree: 2.5, MaxDegree: 4.0, MaxDegreeBone: "左足"},
+		{Frame: 1, MeanPosition: 0.2, MaxPosition: 0.3, MaxPositionBone: "センター",
+			MeanDegree: 1.0, MaxDegree: 2.0, MaxDegreeBone: "センター"},
+		{Frame: 2, MeanPosition: 0.4, MaxPosition: 0.5, MaxPositionBone: "左足",
+			MeanDegree: 4.0, MaxDegree: 6.0, MaxDegreeBone: "センター"},
+	}
+
+	if len(stats) != len(expected) {
+		t.Fatalf("Expected %d stats, got %d", len(expected), len(stats))
+	}
+	for i, stat := range stats {
+		e := expected[i]
+		if stat.Frame != e.Frame || math.Abs(stat.MeanPosition-e.MeanPosition) > 1e-8 ||
+			stat.MaxPosition != e.MaxPosition || stat.MaxPositionBone != e.MaxPositionBone ||
+			math.Abs(stat.MeanDegree-e.MeanDegree) > 1e-8 ||
+			stat.MaxDegree != e.MaxDegree || stat.MaxDegreeBone != e.MaxDegreeBone {
+			t.Errorf("Expected %+v, got %+v", e, stat)
+		}
+	}
+}
+
+func TestMotionDiff_WorstFrames(t *testing.T) {
+	diff := newTestMotionDiff()
+
+	frames := make([]int, 0)
+	for _, stat := range diff.WorstFrames(2) {
+		frames = append(frames, stat.Frame)
+	}
+	if expected := []int{2, 1}; !slices.Equal(frames, expected) {
+		t.Errorf("Expected worst frames %v, got %v", expected, frames)
+	}
+
+	// 位置の差の平均が同じ場合は、回転の差の平均が大きい順
+	diff.PositionDiffs[0] = []float64{0.3, 0.5}
+	frames = frames[:0]
+	for _, stat := range diff.WorstFrames(COMPARE_WORST_FRAMES) {
+		frames = append(frames, stat.Frame)
+	}
+	if expected := []int{2, 0, 1}; !slices.Equal(frames, expected) {
+		t.Errorf("Expected worst frames %v, got %v", expected, frames)
+	}
+}
+
+func TestCompareMotions_Ik(t *testing.T) {
+	model := loadTestModel(t)
+
+	// 足IKだけを動かしたモーション
+	motionA := vmd.NewVmdMotion("")
+	ikBf := vmd.NewBoneFrame(0)
+	ikBf.Position = &mmath.MVec3{X: 0, Y: 3, Z: -2}
+	motionA.AppendBoneFrame("左足ＩＫ", ikBf)
+
+	motionB := vmd.NewVmdMotion("")
+
+	diff := CompareMotions(model, motionA, motionB)
+
+	// キーフレームの無い足・ひざも、IKで動くので比較する
+	stats := diff.BoneStats()
+	for _, boneName := range []string{"左足", "左ひざ", "左足首"} {
+		i := slices.Index(diff.BoneNames, boneName)
+		if i < 0 {
+			t.Errorf("Expected %s to be compared, got %v", boneName, diff.BoneNames)
+			continue
+		}
+		if stats[i].MaxPosition < 0.1 && stats[i].MaxDegree < 1 {
+			t.Errorf("Expected %s to differ by IK, got %v, %v degrees", boneName, stats[i].MaxPosition, stats[i].MaxDegree)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package usecase
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+)
+
+// DEFAULT_EASING クロスフェードの既定の切り替え方
+const DEFAULT_EASING = "ease-in-out"
+
+// easingCurves クロスフェードの切り替え方と、その補間曲線
+var easingCurves = map[string]*mmath.Curve{
+	"linear":      mmath.NewCurve(),
+	"ease-in":     mmath.NewCurveByValues(64, 0, 127, 127),
+	"ease-out":    mmath.NewCurveByValues(0, 0, 64, 127),
+	"ease-in-out": mmath.NewCurveByValues(64, 0, 64, 127),
+}
+
+// Crossfade from から to へ、startFrame〜endFrame の間で easing の切り替え方で切り替えたモーション
+func Crossfade(from, to *vmd.VmdMotion, startFrame, endFrame int, easing string) (*vmd.VmdMotion, error) {
+	curve, ok := easingCurves[strings.ToLower(easing)]
+	if !ok {
+		names := make([]string, 0, len(easingCurves))
+		for name := range easingCurves {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return nil, fmt.Errorf("unknown easing: %s (%s)", easing, strings.Join(names, ", "))
+	}
+
+	blendMotion, err := vmd.Crossfade(from, to, startFrame, endFrame, curve)
+	if err != nil {
+		return nil, err
+	}
+
+	mlog.I("Crossfade %d -> %d (%s): %.0f frames", startFrame, endFrame, easing, blendMotion.MaxFrame())
+
+	return blendMotion, nil
+}
@@ -0,0 +1,34 @@
+package usecase
+
+import (
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/delta"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/pmx"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/infrastructure/repository"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/usecase/deform"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/utils"
+)
+
+// ExportGltf モデルをglTF/GLBで出力する。モーションがあれば全フレームをボーン変形してアニメーションとして焼き込む
+func ExportGltf(model *pmx.PmxModel, motion *vmd.VmdMotion, outputPath string, scale float64) error {
+	rep := repository.NewGltfRepository(scale)
+
+	if motion != nil {
+		mlog.I("Bake gltf animation ...")
+
+		maxFrame := int(motion.MaxFrame())
+		deltas := make([]*delta.VmdDeltas, maxFrame+1)
+
+		bar := utils.NewProgressBar(maxFrame + 1)
+		for iFrame := 0; iFrame <= maxFrame; iFrame++ {
+			bar.Increment()
+			deltas[iFrame] = deform.DeformBone(model, motion, motion, true, iFrame, nil)
+		}
+		bar.Finish()
+
+		rep.SetAnimation(motion.Name(), 30, deltas)
+	}
+
+	return rep.Save(outputPath, model, false)
+}
@@ -0,0 +1,133 @@
+package usecase
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/mmath"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/infrastructure/repository"
+)
+
+// KEY_POSE_INTERVAL キーポーズ同士の最小間隔(フレーム)
+const KEY_POSE_INTERVAL = 30
+
+// ExportVpd 指定フレームのポーズを1フレームずつVPDで出力し、出力したパスを返す
+// フレームの指定が無い場合は、キーポーズ(動きが止まったフレーム)を出力する
+func ExportVpd(motion *vmd.VmdMotion, frames []int, minInterval int, outputDir string) ([]string, error) {
+	if len(frames) == 0 {
+		frames = KeyPoseFrames(motion, minInterval)
+		mlog.I("Detect %d key poses", len(frames))
+	}
+
+	fileName := filepath.Base(motion.Path())
+	baseName := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+
+	rep := repository.NewVpdRepository()
+	paths := make([]string, 0, len(frames))
+	for _, fno := range frames {
+		path := filepath.Join(outputDir, fmt.Sprintf("%s_%05d.vpd", baseName, fno))
+		if err := rep.Save(path, PoseMotion(motion, fno), false); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// PoseMotion 指定フレームのボーン・モーフの値を0フレーム目に持つモーション
+func PoseMotion(motion *vmd.VmdMotion, fno int) *vmd.VmdMotion {
+	poseMotion := vmd.NewVmdMotion(motion.Path())
+	poseMotion.SetName(motion.Name())
+
+	motion.BoneFrames.ForEach(func(name string, boneNameFrames *vmd.BoneNameFrames) {
+		if boneNameFrames.Length() == 0 {
+			return
+		}
+		bf := boneNameFrames.Get(float32(fno))
+
+		poseBf := vmd.NewBoneFrame(0)
+		if bf.Position != nil {
+			poseBf.Position = bf.Position.Copy()
+		}
+		if bf.Rotation != nil {
+			poseBf.Rotation = bf.Rotation.Copy()
+		}
+		poseMotion.AppendBoneFrame(name, poseBf)
+	})
+
+	motion.MorphFrames.ForEach(func(name string, morphNameFrames *vmd.MorphNameFrames) {
+		if morphNameFrames.Length() == 0 {
+			return
+		}
+
+		poseMf := vmd.NewMorphFrame(0)
+		poseMf.Ratio = morphNameFrames.Get(float32(fno)).Ratio
+		poseMotion.AppendMorphFrame(name, poseMf)
+	})
+
+	return poseMotion
+}
+
+// KeyPoseFrames 全ボーンの回転速度の合計が極小となるフレーム(動きが止まったフレーム)
+// 止まっている度合いが大きい順に、minInterval フレーム以上離れたものを選ぶ
+func KeyPoseFrames(motion *vmd.VmdMotion, minInterval int) []int {
+	maxFrame := int(motion.MaxFrame())
+	if maxFrame < 2 {
+		return []int{0}
+	}
+
+	speeds := make([]float64, maxFrame)
+	motion.BoneFrames.ForEach(func(name string, boneNameFrames *vmd.BoneNameFrames) {
+		if boneNameFrames.Length() == 0 {
+			return
+		}
+
+		prevRotation := filledRotation(boneNameFrames.Get(0))
+		for fno := 1; fno <= maxFrame; fno++ {
+			rotation := filledRotation(boneNameFrames.Get(float32(fno)))
+			speeds[fno-1] += 2 * math.Acos(mmath.Clamped(math.Abs(prevRotation.Dot(rotation)), 0, 1))
+			prevRotation = rotation
+		}
+	})
+
+	// speeds[i] は i〜i+1 フレーム間の速度なので、前後の区間の平均をフレームの速度とする
+	candidates := make([]int, 0)
+	frameSpeed := func(fno int) float64 {
+		return (speeds[fno-1] + speeds[fno]) * 0.5
+	}
+	for fno := 1; fno < maxFrame; fno++ {
+		if (fno == 1 || frameSpeed(fno) <= frameSpeed(fno-1)) &&
+			(fno == maxFrame-1 || frameSpeed(fno) <= frameSpeed(fno+1)) {
+			candidates = append(candidates, fno)
+		}
+	}
+
+	slices.SortStableFunc(candidates, func(a, b int) int {
+		return cmp.Compare(frameSpeed(a), frameSpeed(b))
+	})
+
+	keyFrames := make([]int, 0)
+	for _, fno := range candidates {
+		if !slices.ContainsFunc(keyFrames, func(keyFno int) bool { return math.Abs(float64(keyFno-fno)) < float64(minInterval) }) {
+			keyFrames = append(keyFrames, fno)
+		}
+	}
+	slices.Sort(keyFrames)
+
+	return keyFrames
+}
+
+// filledRotation 回転(無い場合は単位回転)
+func filledRotation(bf *vmd.BoneFrame) *mmath.MQuaternion {
+	if bf.Rotation == nil {
+		return mmath.NewMQuaternion()
+	}
+	return bf.Rotation
+}
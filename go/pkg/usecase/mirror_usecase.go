@@ -0,0 +1,18 @@
+package usecase
+
+import (
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+)
+
+// Mirror 左右反転したモーション
+func Mirror(motion *vmd.VmdMotion) (*vmd.VmdMotion, error) {
+	mirrorMotion, err := motion.Mirrored()
+	if err != nil {
+		return nil, err
+	}
+
+	mlog.I("Mirror: %d bones, %d morphs", len(mirrorMotion.BoneFrames.Names()), len(mirrorMotion.MorphFrames.Names()))
+
+	return mirrorMotion, nil
+}
@@ -0,0 +1,19 @@
+package usecase
+
+import (
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+)
+
+// Retime sourceFps で作られたモーションを、MMDのフレームレートで同じ速さで再生されるモーションにする
+func Retime(motion *vmd.VmdMotion, sourceFps float64) (*vmd.VmdMotion, error) {
+	retimeMotion, err := motion.Warped(vmd.NewRateTimeWarp(sourceFps / vmd.VMD_FPS))
+	if err != nil {
+		return nil, err
+	}
+
+	mlog.I("Retime %.2ffps -> %.0ffps: %.0f -> %.0f frames", sourceFps, vmd.VMD_FPS,
+		motion.MaxFrame(), retimeMotion.MaxFrame())
+
+	return retimeMotion, nil
+}
@@ -0,0 +1,18 @@
+package usecase
+
+import (
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/config/mlog"
+	"github.com/miu200521358/mmd-auto-trace-5/pkg/domain/vmd"
+)
+
+// Warp キーフレームの時間を warp で移したモーション(速さの変更・タイムワープ)
+func Warp(motion *vmd.VmdMotion, warp *vmd.TimeWarp) (*vmd.VmdMotion, error) {
+	warpMotion, err := motion.Warped(warp)
+	if err != nil {
+		return nil, err
+	}
+
+	mlog.I("Warp: %.0f -> %.0f frames", motion.MaxFrame(), warpMotion.MaxFrame())
+
+	return warpMotion, nil
+}
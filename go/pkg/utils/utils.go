@@ -72,7 +72,7 @@ func WriteVmdMotions(frames *mjson.Frames, motion *vmd.VmdMotion, dirPath, fileS
 	rep := repository.NewVmdRepository(true)
 	err := rep.Save(path, motion, true)
 	if err != nil {
-		mlog.E(fmt.Sprintf("Failed to write %s vmd %d: %v", logPrefix, motionNum), err)
+		mlog.E(fmt.Sprintf("Failed to write %s vmd %d", logPrefix, motionNum), err)
 	}
 	return nil
 }